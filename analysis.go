@@ -0,0 +1,1453 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/awalterschulze/gographviz"
+	fi "github.com/numbleroot/nemo/faultinjectors"
+	gr "github.com/numbleroot/nemo/graphing"
+)
+
+// Stages of the pipeline, in the order they run.
+var allStages = []string{"load", "simplify", "hazard", "prototypes", "provenance", "tables", "diff", "divergence", "ranking", "corrections", "verify", "extensions", "report"}
+
+// Stages analyzing the provenance.
+var analysisStages = []string{"hazard", "prototypes", "provenance", "tables", "diff", "divergence", "ranking", "corrections", "verify", "extensions"}
+
+// Stages reading the provenance of all runs rather
+// than only of the good run and the failed runs.
+var allRunsStages = []string{"prototypes", "provenance", "extensions"}
+
+// Stages working on results of earlier stages
+// only, without the graph database.
+var offlineStages = map[string]bool{"tables": true, "divergence": true, "ranking": true, "verify": true, "report": true}
+
+// Structs.
+
+// storedResult is the result of a stage as stored,
+// along with the input it was computed for.
+type storedResult struct {
+	Input  string          `json:"input"`
+	Result json.RawMessage `json:"result"`
+}
+
+// outputResult
+type outputResult struct {
+	Runs []*fi.Run `json:"runs"`
+}
+
+// loadResult
+type loadResult struct {
+	Iters []uint `json:"iters"`
+}
+
+// simplifyResult
+type simplifyResult struct {
+	SimplifiedBy map[uint]map[string][]string `json:"simplifiedBy"`
+}
+
+// hazardResult
+type hazardResult struct {
+	Dots []string `json:"dots"`
+}
+
+// prototypesResult
+type prototypesResult struct {
+	InterProto        []string   `json:"interProto"`
+	InterProtoMissing [][]string `json:"interProtoMissing"`
+	UnionProto        []string   `json:"unionProto"`
+	UnionProtoMissing [][]string `json:"unionProtoMissing"`
+	InterDot          string     `json:"interDot"`
+	UnionDot          string     `json:"unionDot"`
+	InterMissingDots  []string   `json:"interMissingDots"`
+	UnionMissingDots  []string   `json:"unionMissingDots"`
+}
+
+// provenanceResult
+type provenanceResult struct {
+	PreDots         []string        `json:"preDots"`
+	PostDots        []string        `json:"postDots"`
+	PreCleanDots    []string        `json:"preCleanDots"`
+	PostCleanDots   []string        `json:"postCleanDots"`
+	PreGraphs       []*fi.ProvGraph `json:"preGraphs"`
+	PostGraphs      []*fi.ProvGraph `json:"postGraphs"`
+	PreCleanGraphs  []*fi.ProvGraph `json:"preCleanGraphs"`
+	PostCleanGraphs []*fi.ProvGraph `json:"postCleanGraphs"`
+}
+
+// diffResult
+type diffResult struct {
+	GoodRun       uint            `json:"goodRun"`
+	DiffDots      []string        `json:"diffDots"`
+	FailedDots    []string        `json:"failedDots"`
+	DiffGraphs    []*fi.ProvGraph `json:"diffGraphs"`
+	MissingEvents [][]*fi.Missing `json:"missingEvents"`
+}
+
+// divergenceResult
+type divergenceResult struct {
+	Dots        []string         `json:"dots"`
+	Divergences []*fi.Divergence `json:"divergences"`
+}
+
+// rankingResult
+type rankingResult struct {
+	RootCauses [][]*fi.RootCause `json:"rootCauses"`
+}
+
+// correctionsResult
+type correctionsResult struct {
+	Corrections []string `json:"corrections"`
+}
+
+// tablesResult
+type tablesResult struct {
+	PreDots    []string         `json:"preDots"`
+	PostDots   []string         `json:"postDots"`
+	AcrossDots []string         `json:"acrossDots"`
+	Graphs     []*gr.TableGraph `json:"graphs"`
+}
+
+// extensionsResult
+type extensionsResult struct {
+	AllRunsAchievedPre bool     `json:"allRunsAchievedPre"`
+	Extensions         []string `json:"extensions"`
+}
+
+// stageResults collects the results of all analysis
+// stages, either computed in this invocation or stored
+// by an earlier one.
+type stageResults struct {
+	simplify    *simplifyResult
+	hazard      *hazardResult
+	prototypes  *prototypesResult
+	provenance  *provenanceResult
+	tables      *tablesResult
+	diff        *diffResult
+	divergence  *divergenceResult
+	ranking     *rankingResult
+	corrections *correctionsResult
+	verify      *verifyResult
+	extensions  *extensionsResult
+}
+
+// Functions.
+
+// parseStages returns the stages in the comma-separated
+// list raw that are among allowed, in pipeline order.
+func parseStages(raw string, allowed []string) ([]string, error) {
+
+	if raw == "" {
+		return allowed, nil
+	}
+
+	isAllowed := make(map[string]bool, len(allowed))
+	for _, stage := range allowed {
+		isAllowed[stage] = true
+	}
+
+	selected := make(map[string]bool)
+	for _, stage := range strings.Split(raw, ",") {
+
+		stage = strings.TrimSpace(stage)
+		if !isAllowed[stage] {
+			return nil, fmt.Errorf("Unknown stage '%s', choose from: %s", stage, strings.Join(allowed, ", "))
+		}
+
+		selected[stage] = true
+	}
+
+	stages := make([]string, 0, len(selected))
+	for _, stage := range allowed {
+
+		if selected[stage] {
+			stages = append(stages, stage)
+		}
+	}
+
+	return stages, nil
+}
+
+// dotStrings serializes DOT graphs for storage.
+func dotStrings(dots []*gographviz.Graph) []string {
+
+	strs := make([]string, len(dots))
+	for i := range dots {
+
+		if dots[i] != nil {
+			strs[i] = dots[i].String()
+		}
+	}
+
+	return strs
+}
+
+// readDots parses stored DOT graphs.
+func readDots(strs []string) ([]*gographviz.Graph, error) {
+
+	dots := make([]*gographviz.Graph, len(strs))
+	for i := range strs {
+
+		if strs[i] == "" {
+			continue
+		}
+
+		dot, err := gographviz.Read([]byte(strs[i]))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read stored DOT graph: %v", err)
+		}
+		dots[i] = dot
+	}
+
+	return dots, nil
+}
+
+// stagesDir returns the directory the results
+// of analysis stages are stored in.
+func (debugRun *DebugRun) stagesDir() string {
+	return filepath.Join(debugRun.thisResultsDir, "stages")
+}
+
+// storeResult writes the result of stage to
+// <results>/stages/<stage>.json for later stages.
+func (debugRun *DebugRun) storeResult(stage string, result interface{}) error {
+
+	err := os.MkdirAll(debugRun.stagesDir(), 0755)
+	if err != nil {
+		return fmt.Errorf("Could not create directory for stage results: %v", err)
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("Failed to marshal result of stage %s: %v", stage, err)
+	}
+
+	storedJSON, err := json.Marshal(&storedResult{Input: debugRun.input, Result: resultJSON})
+	if err != nil {
+		return fmt.Errorf("Failed to marshal result of stage %s: %v", stage, err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(debugRun.stagesDir(), fmt.Sprintf("%s.json", stage)), storedJSON, 0644)
+	if err != nil {
+		return fmt.Errorf("Failed to write result of stage %s: %v", stage, err)
+	}
+
+	return nil
+}
+
+// readStored reads the stored result of stage. It
+// reports false if the stage has not run yet.
+func (debugRun *DebugRun) readStored(stage string) (*storedResult, bool, error) {
+
+	storedJSON, err := ioutil.ReadFile(filepath.Join(debugRun.stagesDir(), fmt.Sprintf("%s.json", stage)))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, fmt.Errorf("Failed to read result of stage %s: %v", stage, err)
+	}
+
+	stored := &storedResult{}
+
+	err = json.Unmarshal(storedJSON, stored)
+	if err != nil {
+		return nil, false, fmt.Errorf("Failed to unmarshal result of stage %s: %v", stage, err)
+	}
+
+	return stored, true, nil
+}
+
+// restoreResult reads the stored result of stage into
+// result. It reports false if the stage has not run yet
+// or ran on another fault injector output or invariant.
+func (debugRun *DebugRun) restoreResult(stage string, result interface{}) (bool, error) {
+
+	stored, found, err := debugRun.readStored(stage)
+	if (err != nil) || !found {
+		return false, err
+	}
+
+	// Leave out results of earlier invocations on other input.
+	if stored.Input != debugRun.input {
+		fmt.Printf("Ignoring stored result of stage %s, it was recorded for another fault injector output or invariant. Rerun that stage to include it.\n", stage)
+		return false, nil
+	}
+
+	err = json.Unmarshal(stored.Result, result)
+	if err != nil {
+		return false, fmt.Errorf("Failed to unmarshal result of stage %s: %v", stage, err)
+	}
+
+	return true, nil
+}
+
+// restoreOutput reads the stored runs of the fault
+// injector output and takes on their input, so that
+// results of stages are restored for the same one.
+func (debugRun *DebugRun) restoreOutput() (*outputResult, bool, error) {
+
+	stored, found, err := debugRun.readStored("output")
+	if (err != nil) || !found {
+		return nil, false, err
+	}
+
+	debugRun.input = stored.Input
+
+	output := &outputResult{}
+
+	err = json.Unmarshal(stored.Result, output)
+	if err != nil {
+		return nil, false, fmt.Errorf("Failed to unmarshal result of stage output: %v", err)
+	}
+
+	return output, true, nil
+}
+
+// restoreAll fills in the results of all stages
+// that did not run in this invocation from storage.
+func (debugRun *DebugRun) restoreAll(results *stageResults) error {
+
+	if results.simplify == nil {
+
+		r := &simplifyResult{}
+		found, err := debugRun.restoreResult("simplify", r)
+		if err != nil {
+			return err
+		} else if found {
+			results.simplify = r
+		}
+	}
+
+	if results.hazard == nil {
+
+		r := &hazardResult{}
+		found, err := debugRun.restoreResult("hazard", r)
+		if err != nil {
+			return err
+		} else if found {
+			results.hazard = r
+		}
+	}
+
+	if results.prototypes == nil {
+
+		r := &prototypesResult{}
+		found, err := debugRun.restoreResult("prototypes", r)
+		if err != nil {
+			return err
+		} else if found {
+			results.prototypes = r
+		}
+	}
+
+	if results.provenance == nil {
+
+		r := &provenanceResult{}
+		found, err := debugRun.restoreResult("provenance", r)
+		if err != nil {
+			return err
+		} else if found {
+			results.provenance = r
+		}
+	}
+
+	if results.tables == nil {
+
+		r := &tablesResult{}
+		found, err := debugRun.restoreResult("tables", r)
+		if err != nil {
+			return err
+		} else if found {
+			results.tables = r
+		}
+	}
+
+	if results.diff == nil {
+
+		r := &diffResult{}
+		found, err := debugRun.restoreResult("diff", r)
+		if err != nil {
+			return err
+		} else if found {
+			results.diff = r
+		}
+	}
+
+	if results.divergence == nil {
+
+		r := &divergenceResult{}
+		found, err := debugRun.restoreResult("divergence", r)
+		if err != nil {
+			return err
+		} else if found {
+			results.divergence = r
+		}
+	}
+
+	if results.ranking == nil {
+
+		r := &rankingResult{}
+		found, err := debugRun.restoreResult("ranking", r)
+		if err != nil {
+			return err
+		} else if found {
+			results.ranking = r
+		}
+	}
+
+	if results.corrections == nil {
+
+		r := &correctionsResult{}
+		found, err := debugRun.restoreResult("corrections", r)
+		if err != nil {
+			return err
+		} else if found {
+			results.corrections = r
+		}
+	}
+
+	if results.verify == nil {
+
+		r := &verifyResult{}
+		found, err := debugRun.restoreResult("verify", r)
+		if err != nil {
+			return err
+		} else if found {
+			results.verify = r
+		}
+	}
+
+	if results.extensions == nil {
+
+		r := &extensionsResult{}
+		found, err := debugRun.restoreResult("extensions", r)
+		if err != nil {
+			return err
+		} else if found {
+			results.extensions = r
+		}
+	}
+
+	return nil
+}
+
+// loadOutput reads the fault injector output.
+func (debugRun *DebugRun) loadOutput() error {
+
+	// Extract, transform, and load fault injector output.
+	err := debugRun.faultInj.LoadOutput()
+	if err != nil {
+		return fmt.Errorf("Failed to load output from Molly: %v", err)
+	}
+
+	return nil
+}
+
+// storeOutput stores the runs of the fault injector
+// output, without their provenance, for the server to
+// enrich with the stored results of all stages. These
+// runs and the invariant identify the input all results
+// stored in this invocation are recorded for.
+func (debugRun *DebugRun) storeOutput() error {
+
+	runs := debugRun.faultInj.GetOutput()
+
+	output := &outputResult{Runs: make([]*fi.Run, len(runs))}
+	for i := range runs {
+
+		if runs[i] == nil {
+			continue
+		}
+
+		run := *runs[i]
+		run.PreProv = nil
+		run.PostProv = nil
+		run.Provenance = nil
+		output.Runs[i] = &run
+	}
+
+	outputJSON, err := json.Marshal(output)
+	if err != nil {
+		return fmt.Errorf("Failed to marshal runs of fault injector output: %v", err)
+	}
+
+	input := sha256.New()
+	input.Write(outputJSON)
+	fmt.Fprintf(input, "\n%s => %s", debugRun.preTable, debugRun.postTable)
+	debugRun.input = hex.EncodeToString(input.Sum(nil))
+
+	return debugRun.storeResult("output", output)
+}
+
+// provIters returns the runs whose provenance the selected
+// stages read. That is all runs if any of them reads all
+// runs or none analyzes provenance, as later invocations
+// may, and otherwise the good run and the failed runs.
+func (debugRun *DebugRun) provIters(selected map[string]bool) []uint {
+
+	analyzes := false
+	for _, stage := range analysisStages {
+		analyzes = analyzes || selected[stage]
+	}
+
+	for _, stage := range allRunsStages {
+
+		if selected[stage] {
+			analyzes = false
+		}
+	}
+
+	if !analyzes {
+		return debugRun.faultInj.GetRunsIters()
+	}
+
+	iters := make([]uint, 0, (len(debugRun.faultInj.GetFailedRunsIters()) + 1))
+	for _, iter := range debugRun.faultInj.GetRunsIters() {
+
+		if iter == debugRun.goodRun {
+			iters = append(iters, iter)
+		}
+	}
+
+	for _, iter := range debugRun.faultInj.GetFailedRunsIters() {
+
+		if iter != debugRun.goodRun {
+			iters = append(iters, iter)
+		}
+	}
+
+	return iters
+}
+
+// connect connects to the graph database for the loaded
+// fault injector output. The provenance of the runs iters,
+// if any, is read first, for import into the database.
+func (debugRun *DebugRun) connect(iters []uint) error {
+
+	var err error
+
+	if len(iters) > 0 {
+
+		err = debugRun.faultInj.LoadProvenance(iters)
+		if err != nil {
+			return fmt.Errorf("Failed to load provenance from fault injector output: %v", err)
+		}
+	}
+
+	// Connect to graph database docker container.
+	err = debugRun.graphDB.InitGraphDB(debugRun.graphDBConn, debugRun.faultInj.GetOutput())
+	if err != nil {
+		return fmt.Errorf("Failed to initialize connection to graph database: %v", err)
+	}
+
+	return nil
+}
+
+// loadProv imports the raw provenance of
+// the runs iters into the graph database.
+func (debugRun *DebugRun) loadProv(iters []uint) error {
+
+	// Load initial (naive) version of provenance
+	// graphs for antecedent and consequent.
+	err := debugRun.graphDB.LoadRawProvenance(iters)
+	if err != nil {
+		return fmt.Errorf("Failed to import provenance (naive) into graph database: %v", err)
+	}
+
+	return nil
+}
+
+// simplifyProv imports cleaned-up versions of the
+// provenance of the runs iters loaded into the
+// graph database.
+func (debugRun *DebugRun) simplifyProv(iters []uint) (*simplifyResult, error) {
+
+	// Clean-up loaded provenance data and
+	// re-import in reduced versions.
+	simplifiedBy, err := debugRun.graphDB.SimplifyProv(iters)
+	if err != nil {
+		return nil, fmt.Errorf("Could not clean-up initial provenance data: %v", err)
+	}
+
+	return &simplifyResult{SimplifiedBy: simplifiedBy}, nil
+}
+
+// load reads the fault injector output and imports
+// raw and cleaned-up provenance of all runs into the
+// graph database. On success, the connection to the
+// graph database is left open for further queries.
+func (debugRun *DebugRun) load() error {
+
+	err := debugRun.loadOutput()
+	if err != nil {
+		return err
+	}
+
+	iters := debugRun.faultInj.GetRunsIters()
+
+	err = debugRun.connect(iters)
+	if err != nil {
+		return err
+	}
+
+	err = debugRun.loadProv(iters)
+	if err != nil {
+		debugRun.graphDB.CloseDB()
+		return err
+	}
+
+	_, err = debugRun.simplifyProv(iters)
+	if err != nil {
+		debugRun.graphDB.CloseDB()
+		return err
+	}
+
+	return nil
+}
+
+// hazard creates the hazard analysis figures.
+func (debugRun *DebugRun) hazard() (*hazardResult, error) {
+
+	// Create hazard analysis DOT figure.
+	hazardDots, err := debugRun.graphDB.CreateHazardAnalysis(debugRun.faultInjFS)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to perform hazard analysis of simulation: %v", err)
+	}
+
+	return &hazardResult{Dots: dotStrings(hazardDots)}, nil
+}
+
+// prototypes extracts the prototypes of successful runs.
+func (debugRun *DebugRun) prototypes() (*prototypesResult, error) {
+
+	// Extract prototypes of successful and
+	// failed runs (skeletons) and import.
+	interProto, interProtoMiss, unionProto, unionProtoMiss, err := debugRun.graphDB.CreatePrototypes(debugRun.faultInj.GetSuccessRunsIters(), debugRun.faultInj.GetFailedRunsIters())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create prototypes of successful executions: %v", err)
+	}
+
+	// Draw both prototypes as dependency graphs, with
+	// the parts missing in each failed run overlaid.
+	interDot, unionDot, interMissDots, unionMissDots, err := debugRun.graphDB.CreatePrototypeGraphs(debugRun.faultInj.GetSuccessRunsIters(), debugRun.faultInj.GetFailedRunsIters())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to draw prototypes of successful executions: %v", err)
+	}
+
+	return &prototypesResult{
+		InterProto:        interProto,
+		InterProtoMissing: interProtoMiss,
+		UnionProto:        unionProto,
+		UnionProtoMissing: unionProtoMiss,
+		InterDot:          dotStrings([]*gographviz.Graph{interDot})[0],
+		UnionDot:          dotStrings([]*gographviz.Graph{unionDot})[0],
+		InterMissingDots:  dotStrings(interMissDots),
+		UnionMissingDots:  dotStrings(unionMissDots),
+	}, nil
+}
+
+// provenance pulls the antecedent and consequent
+// provenance of all runs from the graph database.
+func (debugRun *DebugRun) provenance() (*provenanceResult, error) {
+
+	// Pull antecedent and consequent provenance
+	// and create DOT diagram strings.
+	preProvDots, postProvDots, preCleanProvDots, postCleanProvDots, err := debugRun.graphDB.PullPrePostProv()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to pull and generate antecedent and consequent provenance DOT: %v", err)
+	}
+
+	// Pull the same provenance as graph structures
+	// for the interactive viewer in the report.
+	preProvGraphs, postProvGraphs, preCleanProvGraphs, postCleanProvGraphs, err := debugRun.graphDB.PullProvGraphs()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to pull antecedent and consequent provenance graphs: %v", err)
+	}
+
+	return &provenanceResult{
+		PreDots:         dotStrings(preProvDots),
+		PostDots:        dotStrings(postProvDots),
+		PreCleanDots:    dotStrings(preCleanProvDots),
+		PostCleanDots:   dotStrings(postCleanProvDots),
+		PreGraphs:       preProvGraphs,
+		PostGraphs:      postProvGraphs,
+		PreCleanGraphs:  preCleanProvGraphs,
+		PostCleanGraphs: postCleanProvGraphs,
+	}, nil
+}
+
+// checkGoodRun ensures the run analyses compare
+// against exists and did not violate the specification.
+func (debugRun *DebugRun) checkGoodRun() error {
+
+	for _, run := range debugRun.faultInj.GetOutput() {
+
+		if run.Iteration != debugRun.goodRun {
+			continue
+		}
+
+		if run.Status != "success" {
+			return fmt.Errorf("Good run %d violated the specification, choose a successful run as good_run", debugRun.goodRun)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("Good run %d does not exist", debugRun.goodRun)
+}
+
+// goodRunIndex returns the position of the good run
+// among all runs, which provenance of all runs is in
+// order of, or -1 if it does not exist.
+func (debugRun *DebugRun) goodRunIndex() int {
+
+	for i, iter := range debugRun.faultInj.GetRunsIters() {
+
+		if iter == debugRun.goodRun {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// tables folds the provenance of each run into
+// a graph over tables and merges these across runs.
+func (debugRun *DebugRun) tables(prov *provenanceResult) (*tablesResult, error) {
+
+	preDots, postDots, acrossDots, graphs, err := debugRun.graphDB.CreateTableAbstraction(prov.PreGraphs, prov.PostGraphs, debugRun.faultInj.GetFailedRunsIters())
+	if err != nil {
+		return nil, fmt.Errorf("Could not create table-level provenance: %v", err)
+	}
+
+	return &tablesResult{
+		PreDots:    dotStrings(preDots),
+		PostDots:   dotStrings(postDots),
+		AcrossDots: dotStrings(acrossDots),
+		Graphs:     graphs,
+	}, nil
+}
+
+// diff creates differential provenance of all failed runs
+// against the consequent provenance of the good run.
+func (debugRun *DebugRun) diff(prov *provenanceResult) (*diffResult, error) {
+
+	// Find the consequent provenance of the good run.
+	goodPostDot := ""
+	if i := debugRun.goodRunIndex(); (i >= 0) && (i < len(prov.PostDots)) {
+		goodPostDot = prov.PostDots[i]
+	}
+
+	postProvDots, err := readDots([]string{goodPostDot})
+	if err != nil {
+		return nil, err
+	}
+
+	if postProvDots[0] == nil {
+		return nil, fmt.Errorf("Missing consequent provenance of good run %d", debugRun.goodRun)
+	}
+
+	// Create differential provenance graphs for
+	// consequent provenance.
+	naiveDiffDots, naiveFailedDots, naiveDiffGraphs, missingEvents, err := debugRun.graphDB.CreateNaiveDiffProv(false, debugRun.faultInj.GetFailedRunsIters(), postProvDots[0])
+	if err != nil {
+		return nil, fmt.Errorf("Could not create differential provenance between successful and failed provenance: %v", err)
+	}
+
+	return &diffResult{
+		GoodRun:       debugRun.goodRun,
+		DiffDots:      dotStrings(naiveDiffDots),
+		FailedDots:    dotStrings(naiveFailedDots),
+		DiffGraphs:    naiveDiffGraphs,
+		MissingEvents: missingEvents,
+	}, nil
+}
+
+// divergence finds when each failed run first derived
+// different facts than the good run, and which faults
+// preceded that point.
+func (debugRun *DebugRun) divergence(prov *provenanceResult) (*divergenceResult, error) {
+
+	dots, divs, err := debugRun.graphDB.CreateTemporalDiff(prov.PreGraphs, prov.PostGraphs, debugRun.faultInj.GetOutput(), debugRun.goodRun, debugRun.faultInj.GetFailedRunsIters())
+	if err != nil {
+		return nil, fmt.Errorf("Could not align failed runs with good run by time: %v", err)
+	}
+
+	return &divergenceResult{
+		Dots:        dotStrings(dots),
+		Divergences: divs,
+	}, nil
+}
+
+// ranking scores the events missing from each failed
+// run as candidate root causes of its failure.
+func (debugRun *DebugRun) ranking(prov *provenanceResult, diff *diffResult) (*rankingResult, error) {
+
+	// Find the consequent provenance of the good run.
+	var goodPost *fi.ProvGraph
+	for _, graph := range prov.PostGraphs {
+
+		if (graph != nil) && (graph.Iteration == debugRun.goodRun) {
+			goodPost = graph
+		}
+	}
+
+	if goodPost == nil {
+		return nil, fmt.Errorf("Missing consequent provenance of good run %d", debugRun.goodRun)
+	}
+
+	runs := debugRun.faultInj.GetOutput()
+	failedIters := debugRun.faultInj.GetFailedRunsIters()
+
+	failedRuns := make([]*fi.Run, len(failedIters))
+	for i := range failedIters {
+		failedRuns[i] = runs[failedIters[i]]
+	}
+
+	return &rankingResult{
+		RootCauses: gr.RankRootCauses(goodPost, diff.MissingEvents, failedRuns),
+	}, nil
+}
+
+// corrections generates correction suggestions
+// in case any run violated the specification.
+func (debugRun *DebugRun) corrections() (*correctionsResult, error) {
+
+	if len(debugRun.faultInj.GetFailedRunsIters()) == 0 {
+		return &correctionsResult{}, nil
+	}
+
+	// Generate correction suggestions for moving towards correctness.
+	corrections, err := debugRun.graphDB.GenerateCorrections()
+	if err != nil {
+		return nil, fmt.Errorf("Error while generating corrections: %v", err)
+	}
+
+	return &correctionsResult{Corrections: corrections}, nil
+}
+
+// extensions generates extension proposals.
+func (debugRun *DebugRun) extensions() (*extensionsResult, error) {
+
+	// Attempt to create extension proposals in case
+	// the antecedent depends on network events.
+	allRunsAchievedPre, extensions, err := debugRun.graphDB.GenerateExtensions()
+	if err != nil {
+		return nil, fmt.Errorf("Error while generating extensions: %v", err)
+	}
+
+	return &extensionsResult{
+		AllRunsAchievedPre: allRunsAchievedPre,
+		Extensions:         extensions,
+	}, nil
+}
+
+// generateFigures hands stored DOT graphs to the reporter.
+func (debugRun *DebugRun) generateFigures(iters []uint, name string, strs []string) error {
+
+	dots, err := readDots(strs)
+	if err != nil {
+		return err
+	}
+
+	return debugRun.reporter.GenerateFigures(iters, name, dots)
+}
+
+// enrichRuns adds the results of all analysis stages
+// that ran to the runs iters, of which failedIters
+// violated the specification. At most topCauses root
+// causes are kept per failed run, all if zero.
+func enrichRuns(runs []*fi.Run, iters []uint, failedIters []uint, results *stageResults, topCauses int) {
+
+	if results.simplify != nil {
+
+		// Note which passes shaped each simplified graph.
+		for i := range iters {
+			runs[iters[i]].SimplifiedBy = results.simplify.SimplifiedBy[iters[i]]
+		}
+	}
+
+	if (results.corrections != nil) && (results.extensions != nil) {
+
+		corrections := results.corrections.Corrections
+		extensions := results.extensions.Extensions
+
+		for i := range iters {
+
+			// Progressively formulate one top-level recommendation
+			// for programmers to focus on first.
+			if len(corrections) > 0 {
+
+				// We observed an specification violation. Suggest corrections first.
+				runs[iters[i]].Recommendation = append(runs[iters[i]].Recommendation, "A fault occurred. Let's try making the protocol correct first.")
+				runs[iters[i]].Recommendation = append(runs[iters[i]].Recommendation, corrections...)
+			} else if len(extensions) > 0 {
+
+				// In case there exist runs in this execution where the
+				// antecedent was not achieved (not per se a problem!)
+				// and communication had to be performed for the successful
+				// run to establish the antecedent, it might be a good
+				// idea for the system designers to make sure these rules
+				// are maximum fault-tolerant.
+				runs[iters[i]].Recommendation = append(runs[iters[i]].Recommendation, "Good job, no specification violation. At least one run did not establish the antecedent, though. Maybe double-check the fault tolerance of the following rules:")
+				runs[iters[i]].Recommendation = append(runs[iters[i]].Recommendation, extensions...)
+			} else if !results.extensions.AllRunsAchievedPre {
+
+				// We saw a bug, but we don't find corrections or extensions
+				// to suggest. This must be a bug outside our capabilities
+				// (e.g., local-logic).
+				runs[iters[i]].Recommendation = append(runs[iters[i]].Recommendation, "Nemo can't help with this type of bug. Please use the graphs below regarding differential provenance for guidance to root cause.")
+			} else {
+
+				// No specification violation happened, no more fault tolerance to add.
+				runs[iters[i]].Recommendation = append(runs[iters[i]].Recommendation, "Well done! No faults, no missing fault tolerance.")
+			}
+		}
+	}
+
+	for i := range iters {
+
+		if results.extensions != nil {
+			runs[iters[i]].Extensions = results.extensions.Extensions
+		}
+
+		if results.prototypes != nil {
+			runs[iters[i]].InterProto = results.prototypes.InterProto
+			runs[iters[i]].UnionProto = results.prototypes.UnionProto
+		}
+	}
+
+	for i := range failedIters {
+
+		if results.corrections != nil {
+			runs[failedIters[i]].Corrections = results.corrections.Corrections
+		}
+
+		if (results.diff != nil) && (i < len(results.diff.MissingEvents)) {
+			runs[failedIters[i]].MissingEvents = results.diff.MissingEvents[i]
+		}
+
+		if results.diff != nil {
+			runs[failedIters[i]].GoodRun = results.diff.GoodRun
+		}
+
+		if results.verify != nil {
+			runs[failedIters[i]].Verifications = results.verify.Verifications
+		}
+
+		if (results.ranking != nil) && (i < len(results.ranking.RootCauses)) {
+
+			// Only report the most likely root causes.
+			causes := results.ranking.RootCauses[i]
+			if (topCauses > 0) && (len(causes) > topCauses) {
+				causes = causes[:topCauses]
+			}
+			runs[failedIters[i]].RootCauses = causes
+		}
+
+		if (results.divergence != nil) && (i < len(results.divergence.Divergences)) {
+			runs[failedIters[i]].Divergence = results.divergence.Divergences[i]
+		}
+
+		if (results.prototypes != nil) && (i < len(results.prototypes.InterProtoMissing)) {
+			runs[failedIters[i]].InterProtoMissing = results.prototypes.InterProtoMissing[i]
+			runs[failedIters[i]].UnionProtoMissing = results.prototypes.UnionProtoMissing[i]
+		}
+	}
+}
+
+// report enriches all runs with the results of the
+// analysis stages and hands insights and figures to
+// the reporter. Parts of the report whose stages did
+// not run are left out. It returns all runs and the
+// path of the written report.
+func (debugRun *DebugRun) report(results *stageResults) ([]*fi.Run, string, error) {
+
+	// Determine the IDs of all and all failed executions.
+	iters := debugRun.faultInj.GetRunsIters()
+	failedIters := debugRun.faultInj.GetFailedRunsIters()
+
+	// Retrieve current state of run output.
+	// Enrich with the results of all stages.
+	runs := debugRun.faultInj.GetOutput()
+	enrichRuns(runs, iters, failedIters, results, debugRun.topCauses)
+
+	// Prepare report webpage containing all insights and suggestions.
+	err := debugRun.reporter.Prepare(debugRun.thisResultsDir)
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to prepare debugging report: %v", err)
+	}
+
+	if results.hazard != nil {
+
+		// Generate and write-out hazard analysis figures.
+		err = debugRun.generateFigures(iters, "spacetime", results.hazard.Dots)
+		if err != nil {
+			return nil, "", fmt.Errorf("Could not generate hazard analysis figures for report: %v", err)
+		}
+	}
+
+	if results.provenance != nil {
+
+		prov := results.provenance
+
+		// Generate and write-out antecedent provenance figures.
+		err = debugRun.generateFigures(iters, "pre_prov", prov.PreDots)
+		if err != nil {
+			return nil, "", fmt.Errorf("Could not generate antecedent provenance figures for report: %v", err)
+		}
+
+		// Generate and write-out consequent provenance figures.
+		err = debugRun.generateFigures(iters, "post_prov", prov.PostDots)
+		if err != nil {
+			return nil, "", fmt.Errorf("Could not generate consequent provenance figures for report: %v", err)
+		}
+
+		// Generate and write-out cleaned-up antecedent provenance figures.
+		err = debugRun.generateFigures(iters, "pre_prov_clean", prov.PreCleanDots)
+		if err != nil {
+			return nil, "", fmt.Errorf("Could not generate cleaned-up antecedent provenance figures for report: %v", err)
+		}
+
+		// Generate and write-out cleaned-up consequent provenance figures.
+		err = debugRun.generateFigures(iters, "post_prov_clean", prov.PostCleanDots)
+		if err != nil {
+			return nil, "", fmt.Errorf("Could not generate cleaned-up consequent provenance figures for report: %v", err)
+		}
+
+		// Write-out all provenance graphs for the interactive
+		// viewer and for export to external graph tools.
+		provGraphs := map[string][]*fi.ProvGraph{
+			"pre_prov":        prov.PreGraphs,
+			"post_prov":       prov.PostGraphs,
+			"pre_prov_clean":  prov.PreCleanGraphs,
+			"post_prov_clean": prov.PostCleanGraphs,
+		}
+
+		for _, name := range []string{"pre_prov", "post_prov", "pre_prov_clean", "post_prov_clean"} {
+
+			err = debugRun.reporter.GenerateGraphs(iters, name, provGraphs[name])
+			if err != nil {
+				return nil, "", fmt.Errorf("Could not generate interactive provenance graphs for report: %v", err)
+			}
+		}
+	}
+
+	if results.prototypes != nil {
+
+		// Generate and write-out both prototypes and,
+		// per failed run, their missing parts overlaid.
+		protoDots, err := readDots([]string{results.prototypes.InterDot, results.prototypes.UnionDot})
+		if err != nil {
+			return nil, "", err
+		}
+
+		for i, name := range []string{"proto_inter", "proto_union"} {
+
+			if protoDots[i] != nil {
+
+				err = debugRun.reporter.GenerateFigure(name, protoDots[i])
+				if err != nil {
+					return nil, "", fmt.Errorf("Could not generate prototype figures for report: %v", err)
+				}
+			}
+		}
+
+		// Results stored before prototypes were drawn lack these.
+		if len(results.prototypes.InterMissingDots) > 0 {
+
+			err = debugRun.generateFigures(failedIters, "proto_inter_missing", results.prototypes.InterMissingDots)
+			if err != nil {
+				return nil, "", fmt.Errorf("Could not generate missing intersection-prototype figures for report: %v", err)
+			}
+
+			err = debugRun.generateFigures(failedIters, "proto_union_missing", results.prototypes.UnionMissingDots)
+			if err != nil {
+				return nil, "", fmt.Errorf("Could not generate missing union-prototype figures for report: %v", err)
+			}
+		}
+	}
+
+	if results.tables != nil {
+
+		// Generate and write-out table-level provenance
+		// figures of each run and across all runs.
+		err = debugRun.generateFigures(iters, "tables_pre", results.tables.PreDots)
+		if err != nil {
+			return nil, "", fmt.Errorf("Could not generate table-level antecedent figures for report: %v", err)
+		}
+
+		err = debugRun.generateFigures(iters, "tables_post", results.tables.PostDots)
+		if err != nil {
+			return nil, "", fmt.Errorf("Could not generate table-level consequent figures for report: %v", err)
+		}
+
+		acrossDots, err := readDots(results.tables.AcrossDots)
+		if err != nil {
+			return nil, "", err
+		}
+
+		for i, name := range []string{"tables_pre", "tables_post"} {
+
+			if (i < len(acrossDots)) && (acrossDots[i] != nil) {
+
+				err = debugRun.reporter.GenerateFigure(name, acrossDots[i])
+				if err != nil {
+					return nil, "", fmt.Errorf("Could not generate table-level figures across runs for report: %v", err)
+				}
+			}
+		}
+	}
+
+	if results.diff != nil {
+
+		// Generate and write-out naive differential provenance (diff) figures.
+		err = debugRun.generateFigures(failedIters, "diff_post_prov-diff", results.diff.DiffDots)
+		if err != nil {
+			return nil, "", fmt.Errorf("Could not generate naive differential provenance (diff) figures for report: %v", err)
+		}
+
+		// Generate and write-out naive differential provenance (failed) figures.
+		err = debugRun.generateFigures(failedIters, "diff_post_prov-failed", results.diff.FailedDots)
+		if err != nil {
+			return nil, "", fmt.Errorf("Could not generate naive differential provenance (failed) figures for report: %v", err)
+		}
+
+		// Differential provenance only exists for failed runs.
+		err = debugRun.reporter.GenerateGraphs(failedIters, "diff_post_prov-diff", results.diff.DiffGraphs)
+		if err != nil {
+			return nil, "", fmt.Errorf("Could not generate differential provenance graphs for report: %v", err)
+		}
+	}
+
+	if results.divergence != nil {
+
+		// Generate and write-out divergence timeline figures.
+		err = debugRun.generateFigures(failedIters, "timeline", results.divergence.Dots)
+		if err != nil {
+			return nil, "", fmt.Errorf("Could not generate divergence timeline figures for report: %v", err)
+		}
+	}
+
+	// Assemble all insights and figures into the report.
+	reportPath, err := debugRun.reporter.Finalize(runs)
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to finalize debugging report: %v", err)
+	}
+
+	return runs, reportPath, nil
+}
+
+// runStages runs the given stages of the pipeline. Each
+// analysis stage stores its result below the results
+// directory, where later stages, possibly of another
+// invocation, pick it up. The graph database keeps loaded
+// provenance across invocations. It returns all runs and,
+// if the report stage ran, the path of the written report.
+func (debugRun *DebugRun) runStages(stages []string) ([]*fi.Run, string, error) {
+
+	// Ensure the results directory for this debug run exists.
+	err := os.MkdirAll(debugRun.allResultsDir, 0755)
+	if err != nil {
+		return nil, "", fmt.Errorf("Could not ensure resDir exists: %v", err)
+	}
+
+	selected := make(map[string]bool, len(stages))
+	needsDB := false
+	for _, stage := range stages {
+		selected[stage] = true
+		needsDB = needsDB || !offlineStages[stage]
+	}
+
+	// Verification replays failed runs on the program,
+	// which is optional for all other stages.
+	if selected["verify"] && (debugRun.program == nil) {
+		fmt.Printf("Skipping stage verify, pass the analyzed Dedalus program via -program to verify corrections.\n\n")
+		delete(selected, "verify")
+	}
+
+	err = debugRun.loadOutput()
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Results of earlier invocations are only picked
+	// up if recorded for this output and invariant.
+	err = debugRun.storeOutput()
+	if err != nil {
+		return nil, "", err
+	}
+
+	results := &stageResults{}
+
+	// Table graphs, differential provenance, divergence
+	// timelines, and root causes build on the provenance
+	// of all runs.
+	for _, stage := range []string{"tables", "diff", "divergence", "ranking"} {
+
+		if !selected[stage] || selected["provenance"] || (results.provenance != nil) {
+			continue
+		}
+
+		results.provenance = &provenanceResult{}
+		found, err := debugRun.restoreResult("provenance", results.provenance)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if !found || (len(results.provenance.PostDots) == 0) {
+			return nil, "", fmt.Errorf("Stage %s needs the result of stage provenance, please run that one first", stage)
+		}
+	}
+
+	// Ranking root causes scores the missing events
+	// differential provenance found.
+	if selected["ranking"] && !selected["diff"] {
+
+		results.diff = &diffResult{}
+		found, err := debugRun.restoreResult("diff", results.diff)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if !found {
+			return nil, "", fmt.Errorf("Stage ranking needs the result of stage diff, please run that one first")
+		}
+	}
+
+	// Verification applies the suggested corrections.
+	if selected["verify"] && !selected["corrections"] {
+
+		results.corrections = &correctionsResult{}
+		found, err := debugRun.restoreResult("corrections", results.corrections)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if !found && (debugRun.patch == nil) {
+			return nil, "", fmt.Errorf("Stage verify needs the result of stage corrections or a patch, please run that one first or pass -patch")
+		}
+	}
+
+	// Analyses compare runs against the good run.
+	for _, stage := range analysisStages {
+
+		if selected[stage] {
+
+			err = debugRun.checkGoodRun()
+			if err != nil {
+				return nil, "", err
+			}
+
+			break
+		}
+	}
+
+	// The graph database holds the provenance of the runs
+	// stage load imported, possibly in an earlier invocation.
+	loaded := &loadResult{Iters: debugRun.faultInj.GetRunsIters()}
+	if selected["load"] {
+		loaded.Iters = debugRun.provIters(selected)
+	} else if needsDB {
+
+		_, err = debugRun.restoreResult("load", loaded)
+		if err != nil {
+			return nil, "", err
+		}
+
+		for _, stage := range allRunsStages {
+
+			if selected[stage] && (len(loaded.Iters) < len(debugRun.faultInj.GetRunsIters())) {
+				return nil, "", fmt.Errorf("Stage %s needs the provenance of all runs, but stage load imported only some, please run load along with it", stage)
+			}
+		}
+	}
+
+	if needsDB {
+
+		var iters []uint
+		if selected["load"] {
+			iters = loaded.Iters
+		}
+
+		err = debugRun.connect(iters)
+		if err != nil {
+			return nil, "", err
+		}
+		defer debugRun.graphDB.CloseDB()
+	}
+
+	if selected["load"] {
+
+		err = debugRun.loadProv(loaded.Iters)
+		if err != nil {
+			return nil, "", err
+		}
+
+		err = debugRun.storeResult("load", loaded)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	if selected["simplify"] {
+
+		results.simplify, err = debugRun.simplifyProv(loaded.Iters)
+		if err != nil {
+			return nil, "", err
+		}
+
+		err = debugRun.storeResult("simplify", results.simplify)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	if selected["hazard"] {
+
+		results.hazard, err = debugRun.hazard()
+		if err != nil {
+			return nil, "", err
+		}
+
+		err = debugRun.storeResult("hazard", results.hazard)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	if selected["prototypes"] {
+
+		results.prototypes, err = debugRun.prototypes()
+		if err != nil {
+			return nil, "", err
+		}
+
+		err = debugRun.storeResult("prototypes", results.prototypes)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	if selected["provenance"] {
+
+		results.provenance, err = debugRun.provenance()
+		if err != nil {
+			return nil, "", err
+		}
+
+		err = debugRun.storeResult("provenance", results.provenance)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	if selected["tables"] {
+
+		results.tables, err = debugRun.tables(results.provenance)
+		if err != nil {
+			return nil, "", err
+		}
+
+		err = debugRun.storeResult("tables", results.tables)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	if selected["diff"] {
+
+		results.diff, err = debugRun.diff(results.provenance)
+		if err != nil {
+			return nil, "", err
+		}
+
+		err = debugRun.storeResult("diff", results.diff)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	if selected["divergence"] {
+
+		results.divergence, err = debugRun.divergence(results.provenance)
+		if err != nil {
+			return nil, "", err
+		}
+
+		err = debugRun.storeResult("divergence", results.divergence)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	if selected["ranking"] {
+
+		results.ranking, err = debugRun.ranking(results.provenance, results.diff)
+		if err != nil {
+			return nil, "", err
+		}
+
+		err = debugRun.storeResult("ranking", results.ranking)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	if selected["corrections"] {
+
+		results.corrections, err = debugRun.corrections()
+		if err != nil {
+			return nil, "", err
+		}
+
+		err = debugRun.storeResult("corrections", results.corrections)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	if selected["verify"] {
+
+		results.verify, err = debugRun.verify(results.corrections)
+		if err != nil {
+			return nil, "", err
+		}
+
+		err = debugRun.storeResult("verify", results.verify)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	if selected["extensions"] {
+
+		results.extensions, err = debugRun.extensions()
+		if err != nil {
+			return nil, "", err
+		}
+
+		err = debugRun.storeResult("extensions", results.extensions)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	if !selected["report"] {
+		return debugRun.faultInj.GetOutput(), "", nil
+	}
+
+	err = debugRun.restoreAll(results)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return debugRun.report(results)
+}
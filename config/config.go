@@ -0,0 +1,312 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+
+	gr "github.com/numbleroot/nemo/graphing"
+)
+
+// Names of config files, in the order they are looked for.
+var fileNames = []string{"nemo.yaml", "nemo.yml", "nemo.toml"}
+
+// Functions.
+
+// Default returns the settings Nemo uses
+// if no config file is present.
+func Default() *Config {
+
+	return &Config{
+		Backend:    "neo4j",
+		URI:        "bolt://127.0.0.1:7687",
+		ResultsDir: "results",
+		PreTable:   "pre",
+		PostTable:  "post",
+		TopCauses:  5,
+		Styles:     gr.DefaultStyles(),
+	}
+}
+
+// Find returns the path of the first config
+// file in dirs, or the empty string if there
+// is none in any of them.
+func Find(dirs ...string) string {
+
+	for _, dir := range dirs {
+
+		for _, name := range fileNames {
+
+			path := filepath.Join(dir, name)
+
+			info, err := os.Stat(path)
+			if (err == nil) && !info.IsDir() {
+				return path
+			}
+		}
+	}
+
+	return ""
+}
+
+// scalar returns the single value of e.
+func scalar(key string, e *entry) (string, error) {
+
+	if e.list {
+		return "", fmt.Errorf("setting '%s' takes a single value, not a list", key)
+	}
+
+	return e.values[0], nil
+}
+
+// apply sets the setting key of c to e.
+func (c *Config) apply(key string, e *entry) error {
+
+	// Colors and shapes of figures.
+	styles := map[string]*string{
+		"styles.background":   &c.Styles.Background,
+		"styles.fill":         &c.Styles.Fill,
+		"styles.line":         &c.Styles.Line,
+		"styles.async":        &c.Styles.Async,
+		"styles.next":         &c.Styles.Next,
+		"styles.pre_holds":    &c.Styles.PreHolds,
+		"styles.post_holds":   &c.Styles.PostHolds,
+		"styles.missing":      &c.Styles.Missing,
+		"styles.inactive":     &c.Styles.Inactive,
+		"styles.success_only": &c.Styles.SuccessOnly,
+		"styles.failure_only": &c.Styles.FailureOnly,
+		"styles.rule_shape":   &c.Styles.RuleShape,
+		"styles.goal_shape":   &c.Styles.GoalShape,
+	}
+
+	// Settings taking a list.
+	if key == "analyses" {
+
+		c.Analyses = make([]string, 0, len(e.values))
+		for _, value := range e.values {
+
+			// Allow a comma-separated string as well.
+			for _, analysis := range strings.Split(value, ",") {
+
+				if strings.TrimSpace(analysis) != "" {
+					c.Analyses = append(c.Analyses, strings.TrimSpace(analysis))
+				}
+			}
+		}
+
+		return nil
+	}
+
+	if key == "passes" {
+
+		passes, err := gr.ParsePasses(strings.Join(e.values, ","))
+		if err != nil {
+			return err
+		}
+		c.Passes = passes
+
+		return nil
+	}
+
+	value, err := scalar(key, e)
+	if err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(key, "invariants.") {
+		return c.applyInvariant(key, value, e.line)
+	}
+
+	if style, found := styles[key]; found {
+		*style = value
+		return nil
+	}
+
+	switch key {
+	case "graph_db.backend":
+
+		if value != "neo4j" {
+			return fmt.Errorf("unsupported graph database backend '%s', only 'neo4j' is available", value)
+		}
+		c.Backend = value
+
+	case "graph_db.uri":
+		c.URI = value
+	case "graph_db.user":
+		c.User = value
+	case "graph_db.password":
+		c.Password = value
+	case "graph_db.password_env":
+		c.Password = os.Getenv(value)
+	case "results_dir":
+		c.ResultsDir = value
+	case "good_run":
+
+		goodRun, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("good_run has to be the iteration of a run, not '%s'", value)
+		}
+		c.GoodRun = uint(goodRun)
+
+	case "top_causes":
+
+		topCauses, err := strconv.Atoi(value)
+		if (err != nil) || (topCauses < 1) {
+			return fmt.Errorf("top_causes has to be a positive number, not '%s'", value)
+		}
+		c.TopCauses = topCauses
+
+	case "conditions.pre":
+		c.PreTable = value
+	case "conditions.post":
+		c.PostTable = value
+	default:
+		return fmt.Errorf("unknown setting '%s'", key)
+	}
+
+	return nil
+}
+
+// applyInvariant sets one table of the invariant
+// named in key, adding the invariant if it is new.
+func (c *Config) applyInvariant(key string, value string, line int) error {
+
+	parts := strings.Split(key, ".")
+	if (len(parts) != 3) || ((parts[2] != "pre") && (parts[2] != "post")) {
+		return fmt.Errorf("unknown setting '%s', invariants take 'pre' and 'post' tables", key)
+	}
+
+	var inv *Invariant
+	for i := range c.Invariants {
+
+		if c.Invariants[i].Name == parts[1] {
+			inv = c.Invariants[i]
+		}
+	}
+
+	if inv == nil {
+		inv = &Invariant{Name: parts[1], line: line}
+		c.Invariants = append(c.Invariants, inv)
+	}
+
+	if line < inv.line {
+		inv.line = line
+	}
+
+	if parts[2] == "pre" {
+		inv.Pre = value
+	} else {
+		inv.Post = value
+	}
+
+	return nil
+}
+
+// Parse reads the settings in src on top of the
+// defaults. Files ending in .toml are read as TOML,
+// all others as YAML.
+func Parse(file string, src string) (*Config, error) {
+
+	var entries map[string]*entry
+	var err error
+
+	if strings.HasSuffix(file, ".toml") {
+		entries, err = parseTOML(file, src)
+	} else {
+		entries, err = parseYAML(file, src)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := Default()
+	c.File = file
+
+	// Apply settings in a fixed order.
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+
+		e := entries[key]
+
+		err := c.apply(key, e)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", file, e.line, err)
+		}
+	}
+
+	// Keep invariants in the order they are defined.
+	sort.SliceStable(c.Invariants, func(i, j int) bool {
+		return c.Invariants[i].line < c.Invariants[j].line
+	})
+
+	for _, inv := range c.Invariants {
+
+		if (inv.Pre == "") || (inv.Post == "") {
+			return nil, fmt.Errorf("%s:%d: invariant '%s' needs both a 'pre' and a 'post' table", file, inv.line, inv.Name)
+		}
+	}
+
+	return c, nil
+}
+
+// Load reads the config file at path.
+func Load(path string) (*Config, error) {
+
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read config file: %v", err)
+	}
+
+	return Parse(path, string(src))
+}
+
+// AllInvariants returns the configured invariants or,
+// if there are none, the single unnamed invariant
+// defined by the condition tables.
+func (c *Config) AllInvariants() []*Invariant {
+
+	if len(c.Invariants) > 0 {
+		return c.Invariants
+	}
+
+	return []*Invariant{{Pre: c.PreTable, Post: c.PostTable}}
+}
+
+// ForInvariant returns a copy of c that analyzes
+// invariant inv through the condition tables.
+func (c *Config) ForInvariant(inv *Invariant) *Config {
+
+	invCfg := *c
+	invCfg.PreTable = inv.Pre
+	invCfg.PostTable = inv.Post
+
+	return &invCfg
+}
+
+// GraphDBConn returns the connection URI of the
+// graph database, including configured credentials.
+func (c *Config) GraphDBConn() (string, error) {
+
+	if (c.User == "") && (c.Password == "") {
+		return c.URI, nil
+	}
+
+	uri, err := url.Parse(c.URI)
+	if err != nil {
+		return "", fmt.Errorf("Invalid graph database URI '%s': %v", c.URI, err)
+	}
+	uri.User = url.UserPassword(c.User, c.Password)
+
+	return uri.String(), nil
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	gr "github.com/numbleroot/nemo/graphing"
+)
+
+// Structs.
+
+// Config holds the analysis settings of one protocol,
+// usually committed as nemo.yaml or nemo.toml next to
+// its Dedalus program. Flags given on the command line
+// take precedence over all settings.
+type Config struct {
+	File       string
+	Backend    string
+	URI        string
+	User       string
+	Password   string
+	ResultsDir string
+	Analyses   []string
+	GoodRun    uint
+	PreTable   string
+	PostTable  string
+	Invariants []*Invariant
+	Passes     []string
+	TopCauses  int
+	Styles     *gr.Styles
+}
+
+// Invariant is a named safety property: whenever a
+// row of table Pre holds at the end of a run, the
+// matching row of table Post has to hold as well.
+type Invariant struct {
+	Name string
+	Pre  string
+	Post string
+	line int
+}
+
+// entry is the value of one setting, a scalar
+// or a list, and the line it was set on.
+type entry struct {
+	values []string
+	list   bool
+	line   int
+}
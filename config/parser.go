@@ -0,0 +1,265 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Regular expressions of the supported subsets
+// of YAML and TOML.
+var (
+	keyRegex     = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	sectionRegex = regexp.MustCompile(`^\[\s*([A-Za-z0-9_.-]+)\s*\]$`)
+)
+
+// Functions.
+
+// stripComment removes a comment starting
+// with '#' outside of quotes from line.
+func stripComment(line string) string {
+
+	quote := rune(0)
+
+	for i, c := range line {
+
+		switch {
+		case (quote != 0) && (c == quote):
+			quote = 0
+		case (quote == 0) && ((c == '"') || (c == '\'')):
+			quote = c
+		case (quote == 0) && (c == '#'):
+			return line[:i]
+		}
+	}
+
+	return line
+}
+
+// unquote returns the value of a scalar,
+// removing surrounding quotes if present.
+func unquote(raw string) (string, error) {
+
+	raw = strings.TrimSpace(raw)
+
+	if (len(raw) >= 2) && strings.HasPrefix(raw, "\"") && strings.HasSuffix(raw, "\"") {
+		return strconv.Unquote(raw)
+	}
+
+	if (len(raw) >= 2) && strings.HasPrefix(raw, "'") && strings.HasSuffix(raw, "'") {
+		return strings.Replace(raw[1:(len(raw)-1)], "''", "'", -1), nil
+	}
+
+	return raw, nil
+}
+
+// splitList splits the elements of an inline
+// list at commas outside of quotes.
+func splitList(inner string) []string {
+
+	elems := make([]string, 0, 4)
+	quote := rune(0)
+	start := 0
+
+	for i, c := range inner {
+
+		switch {
+		case (quote != 0) && (c == quote):
+			quote = 0
+		case (quote == 0) && ((c == '"') || (c == '\'')):
+			quote = c
+		case (quote == 0) && (c == ','):
+			elems = append(elems, inner[start:i])
+			start = i + 1
+		}
+	}
+
+	if strings.TrimSpace(inner[start:]) != "" {
+		elems = append(elems, inner[start:])
+	}
+
+	return elems
+}
+
+// parseValue parses a scalar or an inline list
+// such as [hazard, diff] set on line.
+func parseValue(raw string, line int) (*entry, error) {
+
+	raw = strings.TrimSpace(raw)
+	e := &entry{line: line}
+
+	if strings.HasPrefix(raw, "[") {
+
+		if !strings.HasSuffix(raw, "]") {
+			return nil, fmt.Errorf("list is not closed on the same line")
+		}
+
+		e.list = true
+		e.values = make([]string, 0, 4)
+
+		for _, elem := range splitList(raw[1:(len(raw) - 1)]) {
+
+			value, err := unquote(elem)
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s", strings.TrimSpace(elem))
+			}
+			e.values = append(e.values, value)
+		}
+
+		return e, nil
+	}
+
+	value, err := unquote(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid string %s", raw)
+	}
+	e.values = []string{value}
+
+	return e, nil
+}
+
+// parseYAML parses the subset of YAML used by configs:
+// nested mappings of keys to scalars, inline lists, and
+// block lists. Keys of nested mappings are joined by dots.
+func parseYAML(file string, src string) (map[string]*entry, error) {
+
+	type level struct {
+		indent int
+		key    string
+	}
+
+	entries := make(map[string]*entry)
+	levels := make([]*level, 0, 4)
+
+	// The last key without value, which
+	// block list items are added to.
+	openKey := ""
+	openIndent := -1
+
+	for i, rawLine := range strings.Split(src, "\n") {
+
+		lineNum := i + 1
+		line := strings.TrimRight(stripComment(rawLine), " \t\r")
+
+		content := strings.TrimLeft(line, " ")
+		if (content == "") || (content == "---") {
+			continue
+		}
+
+		if strings.HasPrefix(content, "\t") {
+			return nil, fmt.Errorf("%s:%d: tabs are not allowed for indentation", file, lineNum)
+		}
+
+		indent := len(line) - len(content)
+
+		// Items of a block list.
+		if (content == "-") || strings.HasPrefix(content, "- ") {
+
+			if (openKey == "") || (indent < openIndent) {
+				return nil, fmt.Errorf("%s:%d: list item without a key", file, lineNum)
+			}
+
+			value, err := unquote(strings.TrimPrefix(content, "-"))
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid string %s", file, lineNum, strings.TrimSpace(content[1:]))
+			}
+
+			e, found := entries[openKey]
+			if !found {
+				e = &entry{list: true, line: lineNum}
+				entries[openKey] = e
+			}
+			e.values = append(e.values, value)
+
+			continue
+		}
+
+		for (len(levels) > 0) && (levels[(len(levels)-1)].indent >= indent) {
+			levels = levels[:(len(levels) - 1)]
+		}
+
+		colon := strings.Index(content, ":")
+		if colon < 0 {
+			return nil, fmt.Errorf("%s:%d: expected 'key: value'", file, lineNum)
+		}
+
+		key := strings.TrimSpace(content[:colon])
+		if !keyRegex.MatchString(key) {
+			return nil, fmt.Errorf("%s:%d: invalid key '%s'", file, lineNum, key)
+		}
+
+		fullKey := key
+		if len(levels) > 0 {
+			fullKey = fmt.Sprintf("%s.%s", levels[(len(levels)-1)].key, key)
+		}
+
+		raw := strings.TrimSpace(content[(colon + 1):])
+		if raw == "" {
+
+			// A nested mapping or a block list follows.
+			levels = append(levels, &level{indent: indent, key: fullKey})
+			openKey = fullKey
+			openIndent = indent
+
+			continue
+		}
+
+		e, err := parseValue(raw, lineNum)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", file, lineNum, err)
+		}
+		entries[fullKey] = e
+
+		openKey = ""
+		openIndent = -1
+	}
+
+	return entries, nil
+}
+
+// parseTOML parses the subset of TOML used by configs:
+// sections, possibly dotted, and key-value pairs with
+// strings, numbers, booleans, and single-line arrays.
+func parseTOML(file string, src string) (map[string]*entry, error) {
+
+	entries := make(map[string]*entry)
+	section := ""
+
+	for i, rawLine := range strings.Split(src, "\n") {
+
+		lineNum := i + 1
+		line := strings.TrimSpace(stripComment(rawLine))
+
+		if line == "" {
+			continue
+		}
+
+		if m := sectionRegex.FindStringSubmatch(line); m != nil {
+			section = m[1]
+			continue
+		}
+
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("%s:%d: expected 'key = value' or '[section]'", file, lineNum)
+		}
+
+		key, err := unquote(line[:eq])
+		if err != nil || (key == "") {
+			return nil, fmt.Errorf("%s:%d: invalid key '%s'", file, lineNum, strings.TrimSpace(line[:eq]))
+		}
+
+		if section != "" {
+			key = fmt.Sprintf("%s.%s", section, key)
+		}
+
+		e, err := parseValue(line[(eq+1):], lineNum)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", file, lineNum, err)
+		}
+		entries[key] = e
+	}
+
+	return entries, nil
+}
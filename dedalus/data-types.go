@@ -0,0 +1,73 @@
+package dedalus
+
+// Constants.
+
+// TermKind distinguishes the kinds of
+// terms appearing in arguments of atoms.
+type TermKind int
+
+const (
+	Variable TermKind = iota
+	Constant
+	Wildcard
+	Aggregate
+	Arithmetic
+)
+
+// Structs.
+
+// Term is one argument of an atom or one side
+// of a comparison. Aggregates (count<X>) and
+// arithmetic (X+1) refer to variable Name.
+type Term struct {
+	Kind    TermKind
+	Name    string
+	Value   string
+	Quoted  bool
+	Func    string
+	Op      string
+	Operand int
+}
+
+// Atom is a reference to a table, e.g., log(Node, Pload).
+// Negated atoms appear in bodies as notin log(Node, Pload).
+type Atom struct {
+	Table   string
+	Args    []*Term
+	Negated bool
+}
+
+// Comparison is a condition in a rule body, e.g., Cnt > 1.
+type Comparison struct {
+	Left  *Term
+	Op    string
+	Right *Term
+}
+
+// Rule derives tuples of its head from its body.
+// Time is empty for deductive rules, "next" for
+// inductive ones, and "async" for message sends.
+// Rules of a patch rewriting a rule of the program
+// point at it via Replaces.
+type Rule struct {
+	Head       *Atom
+	Time       string
+	Body       []*Atom
+	Conditions []*Comparison
+	Line       int
+	Replaces   *Rule
+}
+
+// Fact is a tuple that holds at the given time.
+type Fact struct {
+	Atom *Atom
+	Time uint
+	Line int
+}
+
+// Program is a parsed Dedalus program.
+type Program struct {
+	File  string
+	Facts []*Fact
+	Rules []*Rule
+}
@@ -0,0 +1,860 @@
+package dedalus
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Structs.
+
+// Crash stops Node from deriving anything
+// from Time on.
+type Crash struct {
+	Node string
+	Time uint
+}
+
+// Omission drops all messages From sends To at Time.
+type Omission struct {
+	From string
+	To   string
+	Time uint
+}
+
+// Scenario describes one execution of a program: it runs
+// from time 1 to EOT, messages may only be omitted before
+// EFF, and the listed faults are injected.
+type Scenario struct {
+	EOT       uint
+	EFF       uint
+	Nodes     []string
+	Crashes   []*Crash
+	Omissions []*Omission
+}
+
+// Tuple is a row of a table holding at a time.
+type Tuple struct {
+	Table string
+	Args  []string
+	Time  uint
+}
+
+// Derivation is one firing of Rule that derived Head
+// from the tuples in Body. Asynchronous rules also use
+// the clock tuple of the message they send.
+type Derivation struct {
+	Rule *Rule
+	Head *Tuple
+	Body []*Tuple
+}
+
+// Message is a tuple sent by an asynchronous rule from
+// one node to another, which may have been dropped.
+type Message struct {
+	Table     string
+	From      string
+	To        string
+	Time      uint
+	Delivered bool
+}
+
+// Model holds all tuples derived in one execution,
+// by time and table, and how they were derived.
+type Model struct {
+	EOT         uint
+	tuples      map[uint]map[string]map[string]*Tuple
+	derivations map[*Tuple][]*Derivation
+	fired       map[string]bool
+	messages    []*Message
+	sent        map[string]bool
+}
+
+// evaluator runs a program for one scenario.
+type evaluator struct {
+	prog      *Program
+	scenario  *Scenario
+	model     *Model
+	strata    [][]*Rule
+	crashedAt map[string]uint
+	omitted   map[string]bool
+}
+
+// Functions.
+
+// String returns the tuple as labeled in provenance,
+// with the time as last argument.
+func (t *Tuple) String() string {
+	return fmt.Sprintf("%s(%s)", t.Table, strings.Join(append(append([]string{}, t.Args...), fmt.Sprintf("%d", t.Time)), ", "))
+}
+
+// key identifies the tuple within a model.
+func (t *Tuple) key() string {
+	return fmt.Sprintf("%s\x00%d\x00%s", t.Table, t.Time, strings.Join(t.Args, "\x00"))
+}
+
+// newModel returns an empty model ending at eot.
+func newModel(eot uint) *Model {
+
+	return &Model{
+		EOT:         eot,
+		tuples:      make(map[uint]map[string]map[string]*Tuple),
+		derivations: make(map[*Tuple][]*Derivation),
+		fired:       make(map[string]bool),
+		messages:    make([]*Message, 0, 8),
+		sent:        make(map[string]bool),
+	}
+}
+
+// add inserts tuple into m and returns the tuple
+// held in m, reporting whether it did not hold before.
+func (m *Model) add(tuple *Tuple) (*Tuple, bool) {
+
+	if m.tuples[tuple.Time] == nil {
+		m.tuples[tuple.Time] = make(map[string]map[string]*Tuple)
+	}
+
+	if m.tuples[tuple.Time][tuple.Table] == nil {
+		m.tuples[tuple.Time][tuple.Table] = make(map[string]*Tuple)
+	}
+
+	key := strings.Join(tuple.Args, "\x00")
+	if held, found := m.tuples[tuple.Time][tuple.Table][key]; found {
+		return held, false
+	}
+
+	m.tuples[tuple.Time][tuple.Table][key] = tuple
+
+	return tuple, true
+}
+
+// derive records that rule derived head from body,
+// unless the same firing was recorded before.
+func (m *Model) derive(rule *Rule, head *Tuple, body []*Tuple) {
+
+	keys := make([]string, len(body))
+	for i := range body {
+		keys[i] = body[i].key()
+	}
+
+	firing := fmt.Sprintf("%p\x01%s\x01%s", rule, head.key(), strings.Join(keys, "\x01"))
+	if m.fired[firing] {
+		return
+	}
+	m.fired[firing] = true
+
+	m.derivations[head] = append(m.derivations[head], &Derivation{Rule: rule, Head: head, Body: body})
+}
+
+// send records that tuple was sent from one node
+// to another at time, unless it was recorded before.
+func (m *Model) send(tuple *Tuple, from string, to string, time uint, delivered bool) {
+
+	key := fmt.Sprintf("%s\x01%s\x01%s", tuple.key(), from, to)
+	if m.sent[key] {
+		return
+	}
+	m.sent[key] = true
+
+	m.messages = append(m.messages, &Message{
+		Table:     tuple.Table,
+		From:      from,
+		To:        to,
+		Time:      time,
+		Delivered: delivered,
+	})
+}
+
+// Derivations returns all firings deriving tuple,
+// none if it is a fact or does not hold in m.
+func (m *Model) Derivations(tuple *Tuple) []*Derivation {
+	return m.derivations[tuple]
+}
+
+// Messages returns all messages sent between different
+// nodes, delivered or not, in order of sending.
+func (m *Model) Messages() []*Message {
+	return m.messages
+}
+
+// Tables returns the names of all tables
+// with rows in m, sorted.
+func (m *Model) Tables() []string {
+
+	seen := make(map[string]bool)
+	tables := make([]string, 0, 16)
+
+	for _, byTable := range m.tuples {
+
+		for table, rows := range byTable {
+
+			if !seen[table] && (len(rows) > 0) {
+				seen[table] = true
+				tables = append(tables, table)
+			}
+		}
+	}
+
+	sort.Strings(tables)
+
+	return tables
+}
+
+// Tuples returns the tuples of table holding at time,
+// sorted like their rows.
+func (m *Model) Tuples(table string, time uint) []*Tuple {
+
+	tuples := make([]*Tuple, 0, len(m.tuples[time][table]))
+	for _, tuple := range m.tuples[time][table] {
+		tuples = append(tuples, tuple)
+	}
+
+	sort.Slice(tuples, func(i, j int) bool {
+		return strings.Join(tuples[i].Args, "\x00") < strings.Join(tuples[j].Args, "\x00")
+	})
+
+	return tuples
+}
+
+// tuplesOf returns the tuples of table at time, in
+// no particular order.
+func (m *Model) tuplesOf(table string, time uint) map[string]*Tuple {
+	return m.tuples[time][table]
+}
+
+// Holds reports whether the row args of table holds at time.
+func (m *Model) Holds(table string, args []string, time uint) bool {
+
+	_, found := m.tuples[time][table][strings.Join(args, "\x00")]
+	return found
+}
+
+// Rows returns the rows of table holding at time, sorted.
+func (m *Model) Rows(table string, time uint) [][]string {
+
+	tuples := m.Tuples(table, time)
+
+	rows := make([][]string, len(tuples))
+	for i := range tuples {
+		rows[i] = tuples[i].Args
+	}
+
+	return rows
+}
+
+// Implies reports whether every row of pre holding at
+// time has an equal row of post holding at time.
+func (m *Model) Implies(pre string, post string, time uint) bool {
+
+	for _, row := range m.Rows(pre, time) {
+
+		if !m.Holds(post, row, time) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// stratify orders the deductive rules of p into strata,
+// such that tables are negated or aggregated only once
+// all their rows of a time are derived.
+func (p *Program) stratify() ([][]*Rule, error) {
+
+	deductive := make([]*Rule, 0, len(p.Rules))
+	for _, rule := range p.Rules {
+
+		if rule.Time == "" {
+			deductive = append(deductive, rule)
+		}
+	}
+
+	aggregates := func(rule *Rule) bool {
+
+		for _, arg := range rule.Head.Args {
+
+			if arg.Kind == Aggregate {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	strata := make(map[string]int)
+
+	for {
+
+		changed := false
+		for _, rule := range deductive {
+
+			for _, atom := range rule.Body {
+
+				least := strata[atom.Table]
+				if atom.Negated || aggregates(rule) {
+					least++
+				}
+
+				if strata[rule.Head.Table] < least {
+					strata[rule.Head.Table] = least
+					changed = true
+				}
+
+				// Strata only grow beyond the number of
+				// rules on cycles through negation.
+				if strata[rule.Head.Table] > len(deductive) {
+					return nil, fmt.Errorf("%s:%d: cannot stratify negation or aggregation of '%s' through recursion", p.File, rule.Line, atom.Table)
+				}
+			}
+		}
+
+		if !changed {
+			break
+		}
+	}
+
+	layers := make([][]*Rule, 0, 2)
+	for _, rule := range deductive {
+
+		s := strata[rule.Head.Table]
+		for len(layers) <= s {
+			layers = append(layers, make([]*Rule, 0, 4))
+		}
+		layers[s] = append(layers[s], rule)
+	}
+
+	return layers, nil
+}
+
+// check ensures all rules of p can be evaluated.
+func (p *Program) check() error {
+
+	for _, rule := range p.Rules {
+
+		for _, arg := range rule.Head.Args {
+
+			if arg.Kind == Wildcard {
+				return fmt.Errorf("%s:%d: wildcard in head of rule for '%s'", p.File, rule.Line, rule.Head.Table)
+			}
+		}
+
+		for _, atom := range rule.Body {
+
+			for _, arg := range atom.Args {
+
+				if arg.Kind == Aggregate {
+					return fmt.Errorf("%s:%d: aggregate in body of rule for '%s'", p.File, rule.Line, rule.Head.Table)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// termValue returns the value of term under bindings
+// and whether it is determined.
+func termValue(term *Term, bindings map[string]string) (string, bool) {
+
+	switch term.Kind {
+	case Constant:
+		return term.Value, true
+	case Variable:
+		v, found := bindings[term.Name]
+		return v, found
+	case Arithmetic:
+
+		v, err := strconv.Atoi(bindings[term.Name])
+		if err != nil {
+			return "", false
+		}
+
+		switch term.Op {
+		case "+":
+			return fmt.Sprintf("%d", (v + term.Operand)), true
+		case "-":
+			return fmt.Sprintf("%d", (v - term.Operand)), true
+		}
+
+		return fmt.Sprintf("%d", (v * term.Operand)), true
+	}
+
+	return "", false
+}
+
+// match extends bindings by unifying the terms of
+// atom with args. It reports false on conflicts.
+func match(atom *Atom, args []string, bindings map[string]string) (map[string]string, bool) {
+
+	if len(atom.Args) != len(args) {
+		return nil, false
+	}
+
+	extended := make(map[string]string, (len(bindings) + len(args)))
+	for k, v := range bindings {
+		extended[k] = v
+	}
+
+	for i, term := range atom.Args {
+
+		switch term.Kind {
+		case Wildcard:
+			continue
+		case Variable:
+
+			bound, found := extended[term.Name]
+			if found && (bound != args[i]) {
+				return nil, false
+			}
+			extended[term.Name] = args[i]
+
+		default:
+
+			v, found := termValue(term, extended)
+			if !found || (v != args[i]) {
+				return nil, false
+			}
+		}
+	}
+
+	return extended, true
+}
+
+// compareValues compares left and right numerically
+// if both are numbers, and as strings otherwise.
+func compareValues(left string, op string, right string) bool {
+
+	cmp := strings.Compare(left, right)
+
+	l, errL := strconv.Atoi(left)
+	r, errR := strconv.Atoi(right)
+	if (errL == nil) && (errR == nil) {
+
+		cmp = 0
+		if l < r {
+			cmp = -1
+		} else if l > r {
+			cmp = 1
+		}
+	}
+
+	switch op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case ">":
+		return cmp > 0
+	case "<=":
+		return cmp <= 0
+	}
+
+	return cmp >= 0
+}
+
+// crashed reports whether node has crashed by time.
+func (e *evaluator) crashed(node string, time uint) bool {
+
+	at, found := e.crashedAt[node]
+	return found && (at <= time)
+}
+
+// delivered reports whether a message sent from
+// sender at time arrives at receiver.
+func (e *evaluator) delivered(sender string, receiver string, time uint) bool {
+
+	if e.crashed(sender, time) || e.crashed(receiver, (time+1)) {
+		return false
+	}
+
+	return !e.omitted[fmt.Sprintf("%s\x00%s\x00%d", sender, receiver, time)]
+}
+
+// firings returns all bindings satisfying the body of
+// rule at time, each with the body tuples it used.
+func (e *evaluator) firings(rule *Rule, time uint) ([]map[string]string, [][]*Tuple) {
+
+	positive := make([]*Atom, 0, len(rule.Body))
+	negative := make([]*Atom, 0, len(rule.Body))
+	for _, atom := range rule.Body {
+
+		if atom.Negated {
+			negative = append(negative, atom)
+		} else {
+			positive = append(positive, atom)
+		}
+	}
+
+	allBindings := make([]map[string]string, 0, 4)
+	allUsed := make([][]*Tuple, 0, 4)
+
+	var join func(i int, bindings map[string]string, used []*Tuple)
+	join = func(i int, bindings map[string]string, used []*Tuple) {
+
+		if i < len(positive) {
+
+			// Join in order, so that derivations are
+			// recorded the same way in every evaluation.
+			for _, tuple := range e.model.Tuples(positive[i].Table, time) {
+
+				extended, ok := match(positive[i], tuple.Args, bindings)
+				if ok {
+					join((i + 1), extended, append(append([]*Tuple{}, used...), tuple))
+				}
+			}
+
+			return
+		}
+
+		for _, cmp := range rule.Conditions {
+
+			left, okL := termValue(cmp.Left, bindings)
+			right, okR := termValue(cmp.Right, bindings)
+			if !okL || !okR || !compareValues(left, cmp.Op, right) {
+				return
+			}
+		}
+
+		// Unbound variables of negated atoms
+		// match anything, like wildcards.
+		for _, atom := range negative {
+
+			for _, tuple := range e.model.tuplesOf(atom.Table, time) {
+
+				if _, ok := match(atom, tuple.Args, bindings); ok {
+					return
+				}
+			}
+		}
+
+		allBindings = append(allBindings, bindings)
+		allUsed = append(allUsed, used)
+	}
+
+	join(0, map[string]string{}, make([]*Tuple, 0, len(positive)))
+
+	return allBindings, allUsed
+}
+
+// location returns the node a firing of rule takes place
+// at, i.e., the first argument of its first body tuple.
+func location(used []*Tuple) string {
+
+	if (len(used) == 0) || (len(used[0].Args) == 0) {
+		return ""
+	}
+
+	return used[0].Args[0]
+}
+
+// heads returns the head tuples rule derives at time from
+// bindings, grouping them if the head aggregates, along
+// with the body tuples used to derive each of them.
+func (e *evaluator) heads(rule *Rule, allBindings []map[string]string, allUsed [][]*Tuple, time uint) ([]*Tuple, [][]*Tuple, error) {
+
+	aggregated := false
+	for _, arg := range rule.Head.Args {
+		aggregated = aggregated || (arg.Kind == Aggregate)
+	}
+
+	tuples := make([]*Tuple, 0, len(allBindings))
+	bodies := make([][]*Tuple, 0, len(allBindings))
+	groups := make(map[string][]map[string]string)
+	groupsUsed := make(map[string][]*Tuple)
+	order := make([]string, 0, 4)
+
+	for j, bindings := range allBindings {
+
+		args := make([]string, len(rule.Head.Args))
+		for i, term := range rule.Head.Args {
+
+			if term.Kind == Aggregate {
+				continue
+			}
+
+			v, found := termValue(term, bindings)
+			if !found {
+				return nil, nil, fmt.Errorf("%s:%d: variable %s in head of rule for '%s' is not bound in its body", e.prog.File, rule.Line, term.Name, rule.Head.Table)
+			}
+			args[i] = v
+		}
+
+		if !aggregated {
+			tuples = append(tuples, &Tuple{Table: rule.Head.Table, Args: args, Time: time})
+			bodies = append(bodies, allUsed[j])
+			continue
+		}
+
+		key := strings.Join(args, "\x00")
+		if _, found := groups[key]; !found {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], bindings)
+
+		// Aggregates are derived from all tuples of their group.
+		for _, tuple := range allUsed[j] {
+
+			if !containsTuple(groupsUsed[key], tuple) {
+				groupsUsed[key] = append(groupsUsed[key], tuple)
+			}
+		}
+	}
+
+	for _, key := range order {
+
+		group := groups[key]
+		args := make([]string, len(rule.Head.Args))
+
+		for i, term := range rule.Head.Args {
+
+			if term.Kind != Aggregate {
+				args[i], _ = termValue(term, group[0])
+				continue
+			}
+
+			args[i] = aggregate(term, group)
+		}
+
+		tuples = append(tuples, &Tuple{Table: rule.Head.Table, Args: args, Time: time})
+		bodies = append(bodies, groupsUsed[key])
+	}
+
+	return tuples, bodies, nil
+}
+
+// containsTuple reports whether tuples holds tuple.
+func containsTuple(tuples []*Tuple, tuple *Tuple) bool {
+
+	for i := range tuples {
+
+		if tuples[i] == tuple {
+			return true
+		}
+	}
+
+	return false
+}
+
+// aggregate computes term over the distinct
+// values its variable takes in group.
+func aggregate(term *Term, group []map[string]string) string {
+
+	seen := make(map[string]bool)
+	values := make([]string, 0, len(group))
+	for _, bindings := range group {
+
+		v := bindings[term.Name]
+		if !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+
+	if term.Func == "count" {
+		return fmt.Sprintf("%d", len(values))
+	}
+
+	result := values[0]
+	sum := 0
+	for _, v := range values {
+
+		n, _ := strconv.Atoi(v)
+		sum += n
+
+		if ((term.Func == "min") && compareValues(v, "<", result)) || ((term.Func == "max") && compareValues(v, ">", result)) {
+			result = v
+		}
+	}
+
+	if term.Func == "sum" {
+		return fmt.Sprintf("%d", sum)
+	}
+
+	return result
+}
+
+// step derives all tuples of time: first the deductive
+// rules stratum by stratum until nothing changes, then
+// the inductive and asynchronous rules for time+1.
+func (e *evaluator) step(time uint) error {
+
+	for _, stratum := range e.strata {
+
+		for changed := true; changed; {
+
+			changed = false
+			for _, rule := range stratum {
+
+				allBindings, allUsed := e.firings(rule, time)
+
+				fired := make([]map[string]string, 0, len(allBindings))
+				firedUsed := make([][]*Tuple, 0, len(allBindings))
+				for i := range allBindings {
+
+					if !e.crashed(location(allUsed[i]), time) {
+						fired = append(fired, allBindings[i])
+						firedUsed = append(firedUsed, allUsed[i])
+					}
+				}
+
+				tuples, bodies, err := e.heads(rule, fired, firedUsed, time)
+				if err != nil {
+					return err
+				}
+
+				for i, tuple := range tuples {
+
+					held, added := e.model.add(tuple)
+					e.model.derive(rule, held, bodies[i])
+					changed = added || changed
+				}
+			}
+		}
+	}
+
+	if time >= e.scenario.EOT {
+		return nil
+	}
+
+	for _, rule := range e.prog.Rules {
+
+		if rule.Time == "" {
+			continue
+		}
+
+		allBindings, allUsed := e.firings(rule, time)
+
+		for i := range allBindings {
+
+			sender := location(allUsed[i])
+			if e.crashed(sender, time) {
+				continue
+			}
+
+			tuples, bodies, err := e.heads(rule, allBindings[i:(i+1)], allUsed[i:(i+1)], (time + 1))
+			if err != nil {
+				return err
+			}
+
+			for j, tuple := range tuples {
+
+				receiver := ""
+				if len(tuple.Args) > 0 {
+					receiver = tuple.Args[0]
+				}
+
+				body := bodies[j]
+
+				if rule.Time == "async" {
+
+					delivered := e.delivered(sender, receiver, time)
+					if sender != receiver {
+						e.model.send(tuple, sender, receiver, time, delivered)
+					}
+
+					if !delivered {
+						continue
+					}
+
+					// Messages depend on the clock delivering them.
+					clock := &Tuple{Table: "clock", Args: []string{sender, receiver, fmt.Sprintf("%d", time), fmt.Sprintf("%d", (time + 1))}, Time: time}
+					if held, found := e.model.tuples[time]["clock"][strings.Join(clock.Args, "\x00")]; found {
+						clock = held
+					}
+					body = append(append([]*Tuple{}, body...), clock)
+				}
+
+				if (rule.Time == "next") && e.crashed(receiver, (time+1)) {
+					continue
+				}
+
+				held, _ := e.model.add(tuple)
+				e.model.derive(rule, held, body)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Evaluate runs p in scenario and returns all tuples
+// holding at each time. As in Molly, crash(Node, Node,
+// Time) holds for every crashed node at all times, and
+// clock(From, To, Time, Time+1) for every message
+// from From to To sent at Time that is delivered.
+func (p *Program) Evaluate(scenario *Scenario) (*Model, error) {
+
+	err := p.check()
+	if err != nil {
+		return nil, err
+	}
+
+	strata, err := p.stratify()
+	if err != nil {
+		return nil, err
+	}
+
+	e := &evaluator{
+		prog:      p,
+		scenario:  scenario,
+		model:     newModel(scenario.EOT),
+		strata:    strata,
+		crashedAt: make(map[string]uint),
+		omitted:   make(map[string]bool),
+	}
+
+	for _, c := range scenario.Crashes {
+
+		if at, found := e.crashedAt[c.Node]; !found || (c.Time < at) {
+			e.crashedAt[c.Node] = c.Time
+		}
+	}
+
+	for _, o := range scenario.Omissions {
+
+		if o.Time < scenario.EFF {
+			e.omitted[fmt.Sprintf("%s\x00%s\x00%d", o.From, o.To, o.Time)] = true
+		}
+	}
+
+	for time := uint(1); time <= scenario.EOT; time++ {
+
+		for _, fact := range p.Facts {
+
+			if fact.Time != time {
+				continue
+			}
+
+			args := make([]string, len(fact.Atom.Args))
+			for i, term := range fact.Atom.Args {
+				args[i], _ = termValue(term, nil)
+			}
+
+			e.model.add(&Tuple{Table: fact.Atom.Table, Args: args, Time: time})
+		}
+
+		for node, at := range e.crashedAt {
+			e.model.add(&Tuple{Table: "crash", Args: []string{node, node, fmt.Sprintf("%d", at)}, Time: time})
+		}
+
+		for _, from := range scenario.Nodes {
+
+			for _, to := range scenario.Nodes {
+
+				if e.delivered(from, to, time) {
+					e.model.add(&Tuple{Table: "clock", Args: []string{from, to, fmt.Sprintf("%d", time), fmt.Sprintf("%d", (time + 1))}, Time: time})
+				}
+			}
+		}
+
+		err := e.step(time)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return e.model, nil
+}
@@ -0,0 +1,460 @@
+package dedalus
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"io/ioutil"
+)
+
+// Structs.
+
+// token is a lexical unit of a Dedalus program.
+type token struct {
+	kind string
+	text string
+	line int
+}
+
+// parser turns the tokens of one
+// program into rules and facts.
+type parser struct {
+	file   string
+	tokens []*token
+	pos    int
+}
+
+// Aggregation functions supported in rule heads.
+var aggFuncs = map[string]bool{
+	"count": true,
+	"min":   true,
+	"max":   true,
+	"sum":   true,
+}
+
+// Functions.
+
+// lex splits a Dedalus program into tokens,
+// dropping whitespace and line comments.
+func lex(file string, src string) ([]*token, error) {
+
+	tokens := make([]*token, 0, (len(src) / 4))
+	runes := []rune(src)
+	line := 1
+
+	for i := 0; i < len(runes); {
+
+		r := runes[i]
+
+		switch {
+		case r == '\n':
+			line++
+			i++
+			continue
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case (r == '/') && ((i + 1) < len(runes)) && (runes[i+1] == '/'):
+
+			for (i < len(runes)) && (runes[i] != '\n') {
+				i++
+			}
+			continue
+		}
+
+		tok := &token{line: line}
+		start := i
+
+		switch {
+		case unicode.IsLetter(r) || (r == '_'):
+
+			for (i < len(runes)) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || (runes[i] == '_')) {
+				i++
+			}
+
+			tok.kind = "ident"
+			tok.text = string(runes[start:i])
+
+		case unicode.IsDigit(r):
+
+			for (i < len(runes)) && unicode.IsDigit(runes[i]) {
+				i++
+			}
+
+			tok.kind = "number"
+			tok.text = string(runes[start:i])
+
+		case r == '"':
+
+			i++
+			for (i < len(runes)) && (runes[i] != '"') {
+
+				if runes[i] == '\n' {
+					return nil, fmt.Errorf("%s:%d: unterminated string", file, line)
+				}
+				i++
+			}
+
+			if i >= len(runes) {
+				return nil, fmt.Errorf("%s:%d: unterminated string", file, line)
+			}
+			i++
+
+			tok.kind = "string"
+			tok.text = string(runes[(start + 1):(i - 1)])
+
+		default:
+
+			// Longest match of operators first.
+			end := i + 2
+			if end > len(runes) {
+				end = len(runes)
+			}
+			rest := string(runes[i:end])
+
+			for _, op := range []string{":-", "==", "!=", "<=", ">=", "(", ")", ",", ";", "@", "<", ">", "+", "-", "*"} {
+
+				if strings.HasPrefix(rest, op) {
+					tok.kind = op
+					tok.text = op
+					i += len([]rune(op))
+					break
+				}
+			}
+
+			if tok.kind == "" {
+				return nil, fmt.Errorf("%s:%d: unexpected character '%c'", file, line, r)
+			}
+		}
+
+		tokens = append(tokens, tok)
+	}
+
+	tokens = append(tokens, &token{kind: "eof", line: line})
+
+	return tokens, nil
+}
+
+// peek returns the current token.
+func (p *parser) peek() *token {
+	return p.tokens[p.pos]
+}
+
+// next consumes and returns the current token.
+func (p *parser) next() *token {
+
+	tok := p.tokens[p.pos]
+	if tok.kind != "eof" {
+		p.pos++
+	}
+
+	return tok
+}
+
+// unread steps back to the given, just consumed token.
+func (p *parser) unread(tok *token) {
+
+	if tok.kind != "eof" {
+		p.pos--
+	}
+}
+
+// errorf returns an error pointing at
+// the line of the current token.
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", p.file, p.peek().line, fmt.Sprintf(format, args...))
+}
+
+// expect consumes a token of the given kind.
+func (p *parser) expect(kind string) (*token, error) {
+
+	tok := p.peek()
+	if tok.kind != kind {
+
+		found := tok.text
+		if tok.kind == "eof" {
+			found = "end of file"
+		}
+
+		return nil, p.errorf("expected '%s', found '%s'", kind, found)
+	}
+
+	return p.next(), nil
+}
+
+// isVariable reports whether an identifier names a
+// variable, i.e., starts with an upper-case letter.
+func isVariable(ident string) bool {
+	return unicode.IsUpper([]rune(ident)[0])
+}
+
+// parseTerm parses one argument of an atom or
+// one side of a comparison.
+func (p *parser) parseTerm(inHead bool) (*Term, error) {
+
+	tok := p.next()
+
+	switch tok.kind {
+	case "string":
+		return &Term{Kind: Constant, Value: tok.text, Quoted: true}, nil
+	case "number":
+		return &Term{Kind: Constant, Value: tok.text}, nil
+	case "-":
+
+		num, err := p.expect("number")
+		if err != nil {
+			return nil, err
+		}
+
+		return &Term{Kind: Constant, Value: ("-" + num.text)}, nil
+
+	case "ident":
+
+		if tok.text == "_" {
+			return &Term{Kind: Wildcard}, nil
+		}
+
+		// Aggregates are only allowed in heads.
+		if inHead && aggFuncs[tok.text] && (p.peek().kind == "<") {
+
+			p.next()
+
+			variable, err := p.expect("ident")
+			if err != nil {
+				return nil, err
+			}
+
+			_, err = p.expect(">")
+			if err != nil {
+				return nil, err
+			}
+
+			return &Term{Kind: Aggregate, Func: tok.text, Name: variable.text}, nil
+		}
+
+		if !isVariable(tok.text) {
+			return &Term{Kind: Constant, Value: tok.text}, nil
+		}
+
+		// Arithmetic on a variable, e.g., N+1.
+		if (p.peek().kind == "+") || (p.peek().kind == "-") || (p.peek().kind == "*") {
+
+			op := p.next()
+
+			num, err := p.expect("number")
+			if err != nil {
+				return nil, err
+			}
+
+			operand, err := strconv.Atoi(num.text)
+			if err != nil {
+				return nil, p.errorf("invalid number '%s'", num.text)
+			}
+
+			return &Term{Kind: Arithmetic, Name: tok.text, Op: op.text, Operand: operand}, nil
+		}
+
+		return &Term{Kind: Variable, Name: tok.text}, nil
+	}
+
+	p.unread(tok)
+	return nil, p.errorf("unexpected '%s' in place of a term", tok.text)
+}
+
+// parseAtom parses a table name and its arguments.
+func (p *parser) parseAtom(inHead bool) (*Atom, error) {
+
+	table, err := p.expect("ident")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.expect("(")
+	if err != nil {
+		return nil, err
+	}
+
+	atom := &Atom{Table: table.text}
+
+	for p.peek().kind != ")" {
+
+		if len(atom.Args) > 0 {
+
+			_, err := p.expect(",")
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		term, err := p.parseTerm(inHead)
+		if err != nil {
+			return nil, err
+		}
+
+		atom.Args = append(atom.Args, term)
+	}
+	p.next()
+
+	return atom, nil
+}
+
+// parseBody parses the comma-separated atoms,
+// negated atoms, and comparisons of a rule body.
+func (p *parser) parseBody(rule *Rule) error {
+
+	for {
+
+		tok := p.peek()
+
+		switch {
+		case (tok.kind == "ident") && (tok.text == "notin"):
+
+			p.next()
+
+			atom, err := p.parseAtom(false)
+			if err != nil {
+				return err
+			}
+
+			atom.Negated = true
+			rule.Body = append(rule.Body, atom)
+
+		case (tok.kind == "ident") && (p.tokens[(p.pos+1)].kind == "("):
+
+			atom, err := p.parseAtom(false)
+			if err != nil {
+				return err
+			}
+
+			rule.Body = append(rule.Body, atom)
+
+		default:
+
+			left, err := p.parseTerm(false)
+			if err != nil {
+				return err
+			}
+
+			op := p.next()
+			switch op.kind {
+			case "==", "!=", "<", ">", "<=", ">=":
+			default:
+				p.unread(op)
+				return p.errorf("expected comparison operator, found '%s'", op.text)
+			}
+
+			right, err := p.parseTerm(false)
+			if err != nil {
+				return err
+			}
+
+			rule.Conditions = append(rule.Conditions, &Comparison{Left: left, Op: op.text, Right: right})
+		}
+
+		if p.peek().kind != "," {
+			return nil
+		}
+		p.next()
+	}
+}
+
+// parseStatement parses one fact or rule.
+func (p *parser) parseStatement(prog *Program) error {
+
+	line := p.peek().line
+
+	head, err := p.parseAtom(true)
+	if err != nil {
+		return err
+	}
+
+	time := ""
+
+	if p.peek().kind == "@" {
+
+		p.next()
+		tok := p.next()
+
+		switch {
+		case tok.kind == "number":
+
+			// Facts hold at a fixed time.
+			t, err := strconv.ParseUint(tok.text, 10, 64)
+			if err != nil {
+				return p.errorf("invalid time '%s'", tok.text)
+			}
+
+			_, err = p.expect(";")
+			if err != nil {
+				return err
+			}
+
+			prog.Facts = append(prog.Facts, &Fact{Atom: head, Time: uint(t), Line: line})
+
+			return nil
+
+		case (tok.kind == "ident") && ((tok.text == "next") || (tok.text == "async")):
+			time = tok.text
+		default:
+			p.unread(tok)
+			return p.errorf("expected 'next', 'async', or a time after '@', found '%s'", tok.text)
+		}
+	}
+
+	rule := &Rule{Head: head, Time: time, Line: line}
+
+	_, err = p.expect(":-")
+	if err != nil {
+		return err
+	}
+
+	err = p.parseBody(rule)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.expect(";")
+	if err != nil {
+		return err
+	}
+
+	prog.Rules = append(prog.Rules, rule)
+
+	return nil
+}
+
+// Parse parses the source of a Dedalus program.
+// The file name is only used in error messages.
+func Parse(file string, src string) (*Program, error) {
+
+	tokens, err := lex(file, src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{file: file, tokens: tokens}
+	prog := &Program{File: file}
+
+	for p.peek().kind != "eof" {
+
+		err := p.parseStatement(prog)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return prog, nil
+}
+
+// ParseFile reads and parses a Dedalus program.
+func ParseFile(file string) (*Program, error) {
+
+	src, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read Dedalus program: %v", err)
+	}
+
+	return Parse(file, string(src))
+}
@@ -0,0 +1,127 @@
+package dedalus
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Functions.
+
+// String returns the term in Dedalus syntax.
+func (t *Term) String() string {
+
+	switch t.Kind {
+	case Variable:
+		return t.Name
+	case Wildcard:
+		return "_"
+	case Aggregate:
+		return fmt.Sprintf("%s<%s>", t.Func, t.Name)
+	case Arithmetic:
+		return fmt.Sprintf("%s%s%d", t.Name, t.Op, t.Operand)
+	}
+
+	if t.Quoted {
+		return fmt.Sprintf("\"%s\"", t.Value)
+	}
+
+	return t.Value
+}
+
+// String returns the atom in Dedalus syntax.
+func (a *Atom) String() string {
+
+	args := make([]string, len(a.Args))
+	for i := range a.Args {
+		args[i] = a.Args[i].String()
+	}
+
+	atom := fmt.Sprintf("%s(%s)", a.Table, strings.Join(args, ", "))
+	if a.Negated {
+		return fmt.Sprintf("notin %s", atom)
+	}
+
+	return atom
+}
+
+// String returns the comparison in Dedalus syntax.
+func (c *Comparison) String() string {
+	return fmt.Sprintf("%s %s %s", c.Left, c.Op, c.Right)
+}
+
+// String returns the rule in Dedalus syntax.
+func (r *Rule) String() string {
+
+	head := r.Head.String()
+	if r.Time != "" {
+		head = fmt.Sprintf("%s@%s", head, r.Time)
+	}
+
+	body := make([]string, 0, (len(r.Body) + len(r.Conditions)))
+	for i := range r.Body {
+		body = append(body, r.Body[i].String())
+	}
+
+	for i := range r.Conditions {
+		body = append(body, r.Conditions[i].String())
+	}
+
+	return fmt.Sprintf("%s :- %s;", head, strings.Join(body, ", "))
+}
+
+// String returns the fact in Dedalus syntax.
+func (f *Fact) String() string {
+	return fmt.Sprintf("%s@%d;", f.Atom, f.Time)
+}
+
+// RulesFor returns all rules deriving the given
+// table, in order of appearance in the program.
+func (p *Program) RulesFor(table string) []*Rule {
+
+	rules := make([]*Rule, 0, 2)
+
+	for i := range p.Rules {
+
+		if p.Rules[i].Head.Table == table {
+			rules = append(rules, p.Rules[i])
+		}
+	}
+
+	return rules
+}
+
+// Apply returns a copy of p patched with the statements
+// of patch. Rules of patch rewriting a rule of p replace
+// only that rule, all others replace all rules of p
+// deriving the same table. Facts of patch are added to
+// those of p.
+func (p *Program) Apply(patch *Program) *Program {
+
+	replaced := make(map[string]bool, len(patch.Rules))
+	rewritten := make(map[*Rule]bool, len(patch.Rules))
+	for i := range patch.Rules {
+
+		if patch.Rules[i].Replaces != nil {
+			rewritten[patch.Rules[i].Replaces] = true
+		} else {
+			replaced[patch.Rules[i].Head.Table] = true
+		}
+	}
+
+	patched := &Program{
+		File:  p.File,
+		Facts: append(append([]*Fact{}, p.Facts...), patch.Facts...),
+		Rules: make([]*Rule, 0, (len(p.Rules) + len(patch.Rules))),
+	}
+
+	for i := range p.Rules {
+
+		if !replaced[p.Rules[i].Head.Table] && !rewritten[p.Rules[i]] {
+			patched.Rules = append(patched.Rules, p.Rules[i])
+		}
+	}
+
+	patched.Rules = append(patched.Rules, patch.Rules...)
+
+	return patched
+}
@@ -0,0 +1,441 @@
+package faultinjectors
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io/fs"
+	"path/filepath"
+)
+
+// Structs.
+
+// maxIdleStreams bounds the number of decompressed
+// streams a tarFS keeps open for reuse.
+const maxIdleStreams = 4
+
+// tarFS is a read-only file system over a gzip-compressed
+// tar archive. It indexes where each file starts within
+// the decompressed archive once and streams files from
+// there on Open, so that memory use does not grow with the
+// size of the archive. As gzip streams cannot seek, Open
+// continues an idle stream positioned before the file if
+// there is one and decompresses from the start otherwise.
+// Reading files in archive order is thus cheapest.
+type tarFS struct {
+	archive string
+	files   map[string]*tarEntry
+	modTime time.Time
+	mu      sync.Mutex
+	idle    []*tarStream
+}
+
+// tarEntry
+type tarEntry struct {
+	name    string
+	offset  int64
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// tarStream is the decompressed content of the
+// archive, read up to position pos.
+type tarStream struct {
+	file *os.File
+	gzip *gzip.Reader
+	pos  int64
+}
+
+// tarFile is an opened regular file of a tarFS.
+type tarFile struct {
+	t      *tarFS
+	entry  *tarEntry
+	stream *tarStream
+	reader io.Reader
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+// tarDir is an opened directory of a tarFS.
+type tarDir struct {
+	info    *tarEntry
+	entries []fs.DirEntry
+	offset  int
+}
+
+// noCloser is returned for outputs that
+// do not hold any resources to release.
+type noCloser struct{}
+
+// Functions.
+
+func (e *tarEntry) Name() string               { return filepath.Base(e.name) }
+func (e *tarEntry) Size() int64                { return e.size }
+func (e *tarEntry) Mode() fs.FileMode          { return e.mode }
+func (e *tarEntry) ModTime() time.Time         { return e.modTime }
+func (e *tarEntry) IsDir() bool                { return e.mode.IsDir() }
+func (e *tarEntry) Sys() interface{}           { return nil }
+func (e *tarEntry) Type() fs.FileMode          { return e.mode.Type() }
+func (e *tarEntry) Info() (fs.FileInfo, error) { return e, nil }
+
+func (f *tarFile) Stat() (fs.FileInfo, error) { return f.entry, nil }
+func (f *tarFile) Read(b []byte) (int, error) { return f.reader.Read(b) }
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// Read reads from the stream, keeping track of its position.
+func (s *tarStream) Read(b []byte) (int, error) {
+	n, err := s.gzip.Read(b)
+	s.pos += int64(n)
+	return n, err
+}
+
+// close releases the resources of the stream.
+func (s *tarStream) close() error {
+	s.gzip.Close()
+	return s.file.Close()
+}
+
+// Close hands the stream of the file back for reuse.
+func (f *tarFile) Close() error {
+
+	if f.stream == nil {
+		return &fs.PathError{Op: "close", Path: f.entry.name, Err: fs.ErrClosed}
+	}
+
+	f.t.release(f.stream)
+	f.stream = nil
+
+	return nil
+}
+
+func (d *tarDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *tarDir) Close() error               { return nil }
+
+func (d *tarDir) Read(b []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+// ReadDir implements fs.ReadDirFile.
+func (d *tarDir) ReadDir(n int) ([]fs.DirEntry, error) {
+
+	rest := d.entries[d.offset:]
+
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(rest) {
+		n = len(rest)
+	}
+	d.offset += n
+
+	return rest[:n], nil
+}
+
+// Open implements fs.FS.
+func (t *tarFS) Open(name string) (fs.File, error) {
+
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	entry, found := t.files[name]
+	if found && !entry.IsDir() {
+
+		stream, err := t.streamTo(entry.offset)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+
+		return &tarFile{t: t, entry: entry, stream: stream, reader: io.LimitReader(stream, entry.size)}, nil
+	}
+
+	// Directories are not necessarily stored in an
+	// archive, we derive them from the contained files.
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+
+	children := make(map[string]fs.DirEntry)
+
+	for path, child := range t.files {
+
+		if !strings.HasPrefix(path, prefix) || (path == name) {
+			continue
+		}
+
+		rest := strings.TrimPrefix(path, prefix)
+		parts := strings.SplitN(rest, "/", 2)
+
+		if len(parts) == 1 {
+			children[parts[0]] = child
+		} else if _, exists := children[parts[0]]; !exists {
+			children[parts[0]] = &tarEntry{name: (prefix + parts[0]), mode: (fs.ModeDir | 0755), modTime: t.modTime}
+		}
+	}
+
+	if !found && (len(children) == 0) && (name != ".") {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(children))
+	for _, child := range children {
+		entries = append(entries, child)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	info := entry
+	if info == nil {
+		info = &tarEntry{name: name, mode: (fs.ModeDir | 0755), modTime: t.modTime}
+	}
+
+	return &tarDir{info: info, entries: entries}, nil
+}
+
+// streamTo returns a stream positioned at offset, reusing
+// the idle stream closest before offset if there is one.
+func (t *tarFS) streamTo(offset int64) (*tarStream, error) {
+
+	t.mu.Lock()
+
+	best := -1
+	for i, s := range t.idle {
+
+		if (s.pos <= offset) && ((best < 0) || (s.pos > t.idle[best].pos)) {
+			best = i
+		}
+	}
+
+	var stream *tarStream
+	if best >= 0 {
+		stream = t.idle[best]
+		t.idle = append(t.idle[:best], t.idle[(best+1):]...)
+	}
+
+	t.mu.Unlock()
+
+	if stream == nil {
+
+		file, err := os.Open(t.archive)
+		if err != nil {
+			return nil, err
+		}
+
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		stream = &tarStream{file: file, gzip: gzipReader}
+	}
+
+	_, err := io.CopyN(io.Discard, stream, (offset - stream.pos))
+	if err != nil {
+		stream.close()
+		return nil, err
+	}
+
+	return stream, nil
+}
+
+// release keeps stream for reuse or closes it
+// if enough streams are kept already.
+func (t *tarFS) release(stream *tarStream) {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.idle) < maxIdleStreams {
+		t.idle = append(t.idle, stream)
+		return
+	}
+
+	stream.close()
+}
+
+// Close implements io.Closer, it closes all idle streams.
+func (t *tarFS) Close() error {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, s := range t.idle {
+		s.close()
+	}
+	t.idle = nil
+
+	return nil
+}
+
+// Close implements io.Closer.
+func (noCloser) Close() error { return nil }
+
+// readTarGz indexes the files of a gzip-compressed tar
+// archive by where their content starts in the
+// decompressed archive, without keeping any content.
+func readTarGz(archive string) (*tarFS, error) {
+
+	archiveFile, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer archiveFile.Close()
+
+	gzipReader, err := gzip.NewReader(archiveFile)
+	if err != nil {
+		return nil, err
+	}
+	defer gzipReader.Close()
+
+	t := &tarFS{
+		archive: archive,
+		files:   make(map[string]*tarEntry),
+		modTime: time.Now(),
+	}
+
+	// The tar reader reads exactly up to the content
+	// of each file, thus the count of bytes read marks
+	// where it starts.
+	counter := &countingReader{r: gzipReader}
+	tarReader := tar.NewReader(counter)
+
+	for {
+
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		name := strings.TrimPrefix(filepath.ToSlash(filepath.Clean(header.Name)), "./")
+		if !fs.ValidPath(name) || (name == ".") {
+			continue
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			t.files[name] = &tarEntry{name: name, mode: (fs.ModeDir | fs.FileMode(header.Mode&0777)), modTime: header.ModTime}
+		case tar.TypeReg:
+			t.files[name] = &tarEntry{name: name, offset: counter.n, size: header.Size, mode: fs.FileMode(header.Mode & 0777), modTime: header.ModTime}
+		}
+	}
+
+	return t, nil
+}
+
+// outputRoot descends into the single top-level
+// directory of an archive if the output files are
+// not located at the root of the archive.
+func outputRoot(fsys fs.FS) (fs.FS, error) {
+
+	for _, marker := range []string{"runs.json", "trace.json"} {
+
+		if _, err := fs.Stat(fsys, marker); err == nil {
+			return fsys, nil
+		}
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	if (len(entries) == 1) && entries[0].IsDir() {
+		return fs.Sub(fsys, entries[0].Name())
+	}
+
+	return fsys, nil
+}
+
+// OpenOutput returns a file system holding the output
+// of a fault injector. The output may be a directory,
+// a .tar.gz or .tgz archive, or a .zip archive. The
+// returned Closer has to be closed after use.
+func OpenOutput(output string) (fs.FS, io.Closer, error) {
+
+	var fsys fs.FS
+	var closer io.Closer = noCloser{}
+
+	switch {
+	case strings.HasSuffix(output, ".tar.gz") || strings.HasSuffix(output, ".tgz"):
+
+		t, err := readTarGz(output)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to read archive '%s': %v", output, err)
+		}
+		fsys = t
+		closer = t
+
+	case strings.HasSuffix(output, ".zip"):
+
+		z, err := zip.OpenReader(output)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to open archive '%s': %v", output, err)
+		}
+		fsys = z
+		closer = z
+
+	default:
+
+		info, err := os.Stat(output)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !info.IsDir() {
+			return nil, nil, fmt.Errorf("'%s' is neither a directory nor a .tar.gz, .tgz, or .zip archive", output)
+		}
+
+		fsys = os.DirFS(output)
+	}
+
+	root, err := outputRoot(fsys)
+	if err != nil {
+		closer.Close()
+		return nil, nil, err
+	}
+
+	return root, closer, nil
+}
+
+// OutputName returns the name of a fault injector
+// output, i.e., its base name without archive suffix.
+func OutputName(output string) string {
+
+	name := filepath.Base(filepath.Clean(output))
+
+	for _, suffix := range []string{".tar.gz", ".tgz", ".zip"} {
+
+		if strings.HasSuffix(name, suffix) {
+			return strings.TrimSuffix(name, suffix)
+		}
+	}
+
+	return name
+}
@@ -0,0 +1,147 @@
+package faultinjectors
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"archive/tar"
+	"compress/gzip"
+	"io/fs"
+	"path/filepath"
+	"testing/fstest"
+)
+
+// Functions.
+
+// writeTarGz writes files, keyed by name, to
+// a gzip-compressed tar archive in dir.
+func writeTarGz(t *testing.T, dir string, files map[string][]byte, order []string) string {
+
+	archive := filepath.Join(dir, "output.tar.gz")
+
+	archiveFile, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archiveFile.Close()
+
+	gzipWriter := gzip.NewWriter(archiveFile)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	err = tarWriter.WriteHeader(&tar.Header{Name: "output/", Typeflag: tar.TypeDir, Mode: 0755})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range order {
+
+		err := tarWriter.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(files[name]))})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = tarWriter.Write(files[name])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = tarWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = gzipWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return archive
+}
+
+func TestTarGzOutput(t *testing.T) {
+
+	files := make(map[string][]byte)
+	order := []string{"output/runs.json"}
+	files["output/runs.json"] = []byte("[]")
+
+	// Sizes around tar's block size, and a long
+	// name taking an extra header.
+	for i, size := range []int{0, 1, 511, 512, 513, 4096, 100000} {
+
+		name := fmt.Sprintf("output/run_%d_post_provenance.json", i)
+		if size == 513 {
+			name = fmt.Sprintf("output/%s/run_%d_post_provenance.json", strings.Repeat("nested", 20), i)
+		}
+
+		files[name] = bytes.Repeat([]byte{byte('a' + i)}, size)
+		order = append(order, name)
+	}
+
+	archive := writeTarGz(t, t.TempDir(), files, order)
+
+	fsys, closer, err := OpenOutput(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+
+	expected := make([]string, 0, len(order))
+	for _, name := range order {
+		expected = append(expected, strings.TrimPrefix(name, "output/"))
+	}
+
+	err = fstest.TestFS(fsys, expected...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Read files backwards, then all at once.
+	check := func(name string) error {
+
+		data, err := fs.ReadFile(fsys, strings.TrimPrefix(name, "output/"))
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(data, files[name]) {
+			return fmt.Errorf("Content of %s differs", name)
+		}
+
+		return nil
+	}
+
+	for i := (len(order) - 1); i >= 0; i-- {
+
+		err := check(order[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(order))
+
+	for _, name := range order {
+
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			errs <- check(name)
+		}(name)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -1,5 +1,9 @@
 package faultinjectors
 
+import (
+	"io/fs"
+)
+
 // Structs.
 
 // CrashFailure
@@ -71,38 +75,152 @@ type ProvData struct {
 	Edges []Edge `json:"edges"`
 }
 
+// ProvNode is a goal or rule of a provenance
+// graph as pulled from the graph database.
+type ProvNode struct {
+	ID        string `json:"id"`
+	Kind      string `json:"kind"`
+	Label     string `json:"label"`
+	Table     string `json:"table"`
+	Type      string `json:"type,omitempty"`
+	Time      string `json:"time,omitempty"`
+	CondHolds bool   `json:"condition_holds"`
+	Missing   bool   `json:"missing,omitempty"`
+}
+
+// ProvGraph is the provenance graph of one
+// condition in one run, either raw, cleaned-up,
+// or differential.
+type ProvGraph struct {
+	Iteration uint        `json:"iteration"`
+	Condition string      `json:"condition"`
+	Variant   string      `json:"variant"`
+	Nodes     []*ProvNode `json:"nodes"`
+	Edges     []*Edge     `json:"edges"`
+}
+
 // Missing
 type Missing struct {
 	Rule  *Rule
 	Goals []*Goal
 }
 
+// RootCause is a missing event that may have caused
+// a run to fail, scored by how likely it did and the
+// reasons that score is made up of.
+type RootCause struct {
+	Event   string   `json:"event"`
+	Table   string   `json:"table"`
+	Time    string   `json:"time"`
+	Rule    string   `json:"rule"`
+	Score   float64  `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+// Verification records how a correction, applied to the
+// program, fared when replaying the failure scenarios of
+// all failed runs it reproduced the failure of.
+type Verification struct {
+	Correction string `json:"correction"`
+	Patch      string `json:"patch,omitempty"`
+	Scenarios  int    `json:"scenarios"`
+	Reproduced int    `json:"reproduced"`
+	Holds      int    `json:"holds"`
+	Label      string `json:"label"`
+}
+
+// TimeStep holds the facts the good run derived at
+// Time but a failed run did not (Missing), the ones
+// only the failed run derived (Extra), and the faults
+// injected into the failed run at Time.
+type TimeStep struct {
+	Time    int      `json:"time"`
+	Shared  int      `json:"shared"`
+	Missing []string `json:"missing,omitempty"`
+	Extra   []string `json:"extra,omitempty"`
+	Faults  []string `json:"faults,omitempty"`
+}
+
+// Divergence describes when a failed run first derived
+// different facts than the good run and which faults
+// preceded that point. Time is -1 if it never did.
+type Divergence struct {
+	GoodRun  uint        `json:"goodRun"`
+	Time     int         `json:"time"`
+	Missing  []string    `json:"missing,omitempty"`
+	Extra    []string    `json:"extra,omitempty"`
+	Faults   []string    `json:"faults,omitempty"`
+	Timeline []*TimeStep `json:"timeline"`
+}
+
 // Run
 type Run struct {
-	Iteration         uint            `json:"iteration"`
-	Status            string          `json:"status"`
-	FailureSpec       *FailureSpec    `json:"failureSpec"`
-	Model             *Model          `json:"model"`
-	Messages          []*Message      `json:"messages"`
-	PreProv           *ProvData       `json:"preProv,omitempty"`
-	TimePreHolds      map[string]bool `json:"timePreHolds,omitempty"`
-	PostProv          *ProvData       `json:"postProv,omitempty"`
-	TimePostHolds     map[string]bool `json:"timePostHolds,omitempty"`
-	Recommendation    []string        `json:"recommendation,omitempty"`
-	Corrections       []string        `json:"corrections,omitempty"`
-	MissingEvents     []*Missing      `json:"missingEvents,omitempty"`
-	InterProto        []string        `json:"interProto,omitempty"`
-	InterProtoMissing []string        `json:"interProtoMissing,omitempty"`
-	UnionProto        []string        `json:"unionProto,omitempty"`
-	UnionProtoMissing []string        `json:"unionProtoMissing,omitempty"`
+	Iteration         uint                 `json:"iteration"`
+	Status            string               `json:"status"`
+	FailureSpec       *FailureSpec         `json:"failureSpec"`
+	Model             *Model               `json:"model"`
+	Messages          []*Message           `json:"messages"`
+	PreProv           *ProvData            `json:"preProv,omitempty"`
+	TimePreHolds      map[string]bool      `json:"timePreHolds,omitempty"`
+	PostProv          *ProvData            `json:"postProv,omitempty"`
+	Provenance        map[string]*ProvData `json:"provenance,omitempty"`
+	TimePostHolds     map[string]bool      `json:"timePostHolds,omitempty"`
+	SimplifiedBy      map[string][]string  `json:"simplifiedBy,omitempty"`
+	Recommendation    []string             `json:"recommendation,omitempty"`
+	Corrections       []string             `json:"corrections,omitempty"`
+	Verifications     []*Verification      `json:"verifications,omitempty"`
+	Extensions        []string             `json:"extensions,omitempty"`
+	MissingEvents     []*Missing           `json:"missingEvents,omitempty"`
+	GoodRun           uint                 `json:"goodRun,omitempty"`
+	RootCauses        []*RootCause         `json:"rootCauses,omitempty"`
+	Divergence        *Divergence          `json:"divergence,omitempty"`
+	InterProto        []string             `json:"interProto,omitempty"`
+	InterProtoMissing []string             `json:"interProtoMissing,omitempty"`
+	UnionProto        []string             `json:"unionProto,omitempty"`
+	UnionProtoMissing []string             `json:"unionProtoMissing,omitempty"`
+}
+
+// Problem describes one defect in the output
+// of a fault injector, located by the file and
+// the JSON path within that file.
+type Problem struct {
+	File    string `json:"file"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// Trace is the injector-independent input format
+// of Nemo, see docs/trace-format.md.
+type Trace struct {
+	Version  uint   `json:"version"`
+	Injector string `json:"injector,omitempty"`
+	Runs     []*Run `json:"runs"`
 }
 
 // Molly
 type Molly struct {
 	Run              string
 	OutputDir        string
+	FS               fs.FS
+	Workers          int
+	PreTable         string
+	PostTable        string
+	Runs             []*Run
+	RunsIters        []uint
+	SuccessRunsIters []uint
+	FailedRunsIters  []uint
+}
+
+// Generic
+type Generic struct {
+	Run              string
+	OutputDir        string
+	FS               fs.FS
+	PreTable         string
+	PostTable        string
 	Runs             []*Run
 	RunsIters        []uint
 	SuccessRunsIters []uint
 	FailedRunsIters  []uint
+	preparedProv     map[uint]bool
 }
@@ -0,0 +1,355 @@
+package faultinjectors
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+
+	"encoding/json"
+	"io/fs"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// TraceVersion is the version of the trace
+// format this version of Nemo reads and writes.
+const TraceVersion uint = 1
+
+// Functions.
+
+// fsys returns the file system holding the
+// trace, by default directory OutputDir.
+func (g *Generic) fsys() fs.FS {
+
+	if g.FS == nil {
+		g.FS = os.DirFS(g.OutputDir)
+	}
+
+	return g.FS
+}
+
+// decodeTrace decodes a trace object, streaming
+// the contained runs one at a time.
+func decodeTrace(dec *json.Decoder) (*Trace, error) {
+
+	trace := &Trace{}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	if delim, ok := tok.(json.Delim); !ok || (delim != '{') {
+		return nil, fmt.Errorf("expected trace object, found %v", tok)
+	}
+
+	for dec.More() {
+
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok {
+		case "version":
+			err = dec.Decode(&trace.Version)
+		case "injector":
+			err = dec.Decode(&trace.Injector)
+		case "runs":
+			trace.Runs, err = decodeRuns(dec)
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Consume closing brace.
+	_, err = dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	return trace, nil
+}
+
+// LoadOutput reads the runs contained in file
+// trace.json in the output directory. As the trace
+// holds all provenance in this one file, it is
+// decoded here and prepared by LoadProvenance.
+func (g *Generic) LoadOutput() error {
+
+	traceFile, err := g.fsys().Open("trace.json")
+	if err != nil {
+		return fmt.Errorf("Could not read trace.json file in faultInjOut directory: %v", err)
+	}
+	defer traceFile.Close()
+
+	trace, err := decodeTrace(json.NewDecoder(bufio.NewReader(traceFile)))
+	if err != nil {
+		return fmt.Errorf("Failed to unmarshal JSON content to trace structure: %v", err)
+	}
+
+	if trace.Version != TraceVersion {
+		return fmt.Errorf("Unsupported trace format version %d, expected %d", trace.Version, TraceVersion)
+	}
+
+	g.Runs = trace.Runs
+	g.RunsIters = make([]uint, len(g.Runs))
+	g.SuccessRunsIters = make([]uint, 0, len(g.Runs))
+	g.FailedRunsIters = make([]uint, 0, 3)
+	g.preparedProv = make(map[uint]bool)
+
+	for i := range g.Runs {
+
+		if g.Runs[i] == nil {
+			return fmt.Errorf("Run at index %d in trace.json is null", i)
+		}
+
+		if g.Runs[i].Iteration != uint(i) {
+			return fmt.Errorf("Run at index %d in trace.json has iteration %d", i, g.Runs[i].Iteration)
+		}
+
+		err := prepareRun(g.Runs[i], condTables(g.PreTable, g.PostTable))
+		if err != nil {
+			return err
+		}
+
+		// Note return status of fault injection
+		// run in separate structure.
+		g.RunsIters[i] = g.Runs[i].Iteration
+		if g.Runs[i].Status == "success" {
+			g.SuccessRunsIters = append(g.SuccessRunsIters, g.Runs[i].Iteration)
+		} else {
+			g.FailedRunsIters = append(g.FailedRunsIters, g.Runs[i].Iteration)
+		}
+	}
+
+	return nil
+}
+
+// LoadProvenance prepares antecedent and consequent
+// provenance of the specified runs for use in Nemo.
+func (g *Generic) LoadProvenance(iters []uint) error {
+
+	for _, iter := range iters {
+
+		if iter >= uint(len(g.Runs)) {
+			return fmt.Errorf("Run %d does not exist in trace.json", iter)
+		}
+
+		if g.preparedProv[iter] {
+			continue
+		}
+
+		run := g.Runs[iter]
+		tables := condTables(g.PreTable, g.PostTable)
+
+		// Provenance of tables listed separately takes
+		// the place of antecedent and consequent. Other
+		// tables than pre and post need their own.
+		for _, cond := range []string{"pre", "post"} {
+
+			prov, found := run.Provenance[tables[cond]]
+			if !found && (tables[cond] != cond) {
+				return fmt.Errorf("Run %d is missing provenance of table '%s' in trace.json", run.Iteration, tables[cond])
+			}
+
+			if found && (cond == "pre") {
+				run.PreProv = prov
+			} else if found {
+				run.PostProv = prov
+			}
+		}
+
+		if (run.PreProv == nil) || (run.PostProv == nil) {
+			return fmt.Errorf("Run %d is missing antecedent or consequent provenance", run.Iteration)
+		}
+
+		prepareProv(run.Iteration, "pre", run.PreProv)
+		prepareProv(run.Iteration, "post", run.PostProv)
+
+		g.preparedProv[iter] = true
+	}
+
+	return nil
+}
+
+// Validate checks trace.json in the output directory
+// and reports every problem it finds.
+func (g *Generic) Validate() []*Problem {
+
+	traceFile := filepath.Join(g.OutputDir, "trace.json")
+
+	rawTraceCont, err := fs.ReadFile(g.fsys(), "trace.json")
+	if err != nil {
+		return []*Problem{{traceFile, "$", fmt.Sprintf("could not read file: %v", err)}}
+	}
+
+	var trace Trace
+
+	err = json.Unmarshal(rawTraceCont, &trace)
+	if err != nil {
+		return []*Problem{{traceFile, "$", fmt.Sprintf("could not unmarshal trace: %v", err)}}
+	}
+
+	problems := make([]*Problem, 0)
+
+	if trace.Version != TraceVersion {
+		problems = append(problems, &Problem{traceFile, "$.version", fmt.Sprintf("unsupported version %d, expected %d", trace.Version, TraceVersion)})
+	}
+
+	problems = append(problems, validateRuns(traceFile, "$.runs", trace.Runs, condTables(g.PreTable, g.PostTable))...)
+
+	for i := range trace.Runs {
+
+		if trace.Runs[i] == nil {
+			continue
+		}
+
+		provs := map[string]*ProvData{
+			"pre":  trace.Runs[i].PreProv,
+			"post": trace.Runs[i].PostProv,
+		}
+
+		paths := map[string]string{
+			"pre":  fmt.Sprintf("$.runs[%d].preProv", i),
+			"post": fmt.Sprintf("$.runs[%d].postProv", i),
+		}
+
+		for cond, table := range condTables(g.PreTable, g.PostTable) {
+
+			if prov, found := trace.Runs[i].Provenance[table]; found {
+				provs[cond] = prov
+				paths[cond] = fmt.Sprintf("$.runs[%d].provenance.%s", i, table)
+			} else if table != cond {
+				provs[cond] = nil
+				paths[cond] = fmt.Sprintf("$.runs[%d].provenance.%s", i, table)
+			}
+		}
+
+		for _, cond := range []string{"pre", "post"} {
+
+			path := paths[cond]
+
+			if provs[cond] == nil {
+				problems = append(problems, &Problem{traceFile, path, fmt.Sprintf("run %d has no '%s' provenance", trace.Runs[i].Iteration, condTables(g.PreTable, g.PostTable)[cond])})
+				continue
+			}
+
+			problems = append(problems, validateProv(traceFile, path, provs[cond])...)
+		}
+	}
+
+	return problems
+}
+
+// GetFailureSpec returns the failure specification of this analysis.
+func (g *Generic) GetFailureSpec() *FailureSpec {
+	return g.Runs[0].FailureSpec
+}
+
+// GetMsgsFailedRuns returns the messages sent from all failed runs.
+func (g *Generic) GetMsgsFailedRuns() [][]*Message {
+
+	msgs := make([][]*Message, len(g.FailedRunsIters))
+	for i := range g.FailedRunsIters {
+		msgs[i] = g.Runs[g.FailedRunsIters[i]].Messages
+	}
+
+	return msgs
+}
+
+// GetOutput returns all parsed runs from the trace.
+func (g *Generic) GetOutput() []*Run {
+	return g.Runs
+}
+
+// GetRunsIters returns the iteration numbers
+// of all runs known in this struct.
+func (g *Generic) GetRunsIters() []uint {
+	return g.RunsIters
+}
+
+// GetSuccessRunsIters returns indexes of successful runs.
+func (g *Generic) GetSuccessRunsIters() []uint {
+	return g.SuccessRunsIters
+}
+
+// GetFailedRunsIters returns indexes of failed runs.
+func (g *Generic) GetFailedRunsIters() []uint {
+	return g.FailedRunsIters
+}
+
+// ConvertToTrace writes the output of Molly in the
+// generic trace format to directory traceDir. Space-time
+// diagrams are copied over next to trace.json.
+func (m *Molly) ConvertToTrace(traceDir string) error {
+
+	err := m.readRuns()
+	if err != nil {
+		return err
+	}
+
+	iters := make([]uint, len(m.Runs))
+	for i := range m.Runs {
+
+		if m.Runs[i] == nil {
+			return fmt.Errorf("Run at index %d in runs.json is null", i)
+		}
+
+		iters[i] = uint(i)
+	}
+
+	jobs, err := m.provJobs(iters)
+	if err != nil {
+		return err
+	}
+
+	// Provenance of a trace keeps the original IDs.
+	err = decodeProvs(m.fsys(), jobs, m.Workers, false)
+	if err != nil {
+		return err
+	}
+
+	traceJSON, err := json.MarshalIndent(&Trace{
+		Version:  TraceVersion,
+		Injector: "molly",
+		Runs:     m.Runs,
+	}, "", "\t")
+	if err != nil {
+		return fmt.Errorf("Failed to marshal trace to JSON: %v", err)
+	}
+
+	err = os.MkdirAll(traceDir, 0755)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(filepath.Join(traceDir, "trace.json"), traceJSON, 0644)
+	if err != nil {
+		return fmt.Errorf("Error writing out trace.json: %v", err)
+	}
+
+	for i := range m.Runs {
+
+		spaceTimeFile := fmt.Sprintf("run_%d_spacetime.dot", m.Runs[i].Iteration)
+
+		spaceTimeCont, err := fs.ReadFile(m.fsys(), spaceTimeFile)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		err = ioutil.WriteFile(filepath.Join(traceDir, spaceTimeFile), spaceTimeCont, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
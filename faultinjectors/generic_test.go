@@ -0,0 +1,46 @@
+package faultinjectors
+
+import (
+	"strings"
+	"testing"
+
+	"testing/fstest"
+)
+
+// Functions.
+
+// TestGenericCustomTableProvenance checks that runs of an
+// invariant over other tables than pre and post need the
+// provenance of these tables instead of falling back to
+// the antecedent and consequent provenance.
+func TestGenericCustomTableProvenance(t *testing.T) {
+
+	prov := `{"goals": [{"id": "g1", "label": "acked(a, 1)", "table": "acked", "time": "1"}], "rules": [], "edges": []}`
+	trace := `{"version": 1, "runs": [{"iteration": 0, "status": "success", "model": {"tables": {}},
+		"preProv": ` + prov + `, "postProv": ` + prov + `, "provenance": {"acked": ` + prov + `}}]}`
+
+	g := &Generic{
+		FS:        fstest.MapFS{"trace.json": {Data: []byte(trace)}},
+		PreTable:  "acked",
+		PostTable: "stored",
+	}
+
+	err := g.LoadOutput()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = g.LoadProvenance([]uint{0})
+	if (err == nil) || !strings.Contains(err.Error(), "'stored'") {
+		t.Errorf("Expected error on missing provenance of table stored, got: %v", err)
+	}
+
+	found := false
+	for _, problem := range g.Validate() {
+		found = found || (problem.Path == "$.runs[0].provenance.stored")
+	}
+
+	if !found {
+		t.Errorf("Expected problem at $.runs[0].provenance.stored, got: %v", g.Validate())
+	}
+}
@@ -0,0 +1,305 @@
+package faultinjectors
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+
+	"encoding/json"
+	"io/fs"
+)
+
+// Structs.
+
+// provJob describes one provenance file in
+// the output to decode and where to put its content.
+type provJob struct {
+	run       *Run
+	condition string
+	file      string
+}
+
+// Regular expressions extracting the time from
+// labels of clock goals, compiled only once.
+var (
+	clkTimeWildRegex = regexp.MustCompile(`, ([\d]+), __WILDCARD__\)`)
+	clkTimeTwoRegex  = regexp.MustCompile(`, ([\d]+), ([\d]+)\)`)
+)
+
+// Functions.
+
+// prepareProv fills in the time of clock goals and
+// prefixes all IDs of the supplied provenance with
+// run iteration and condition, so that they are
+// unique across all graphs of one execution.
+func prepareProv(iteration uint, condition string, provData *ProvData) {
+
+	for j := range provData.Goals {
+
+		if provData.Goals[j].Table == "clock" {
+
+			clkTimeWildMatches := clkTimeWildRegex.FindStringSubmatch(provData.Goals[j].Label)
+			clkTimeTwoMatches := clkTimeTwoRegex.FindStringSubmatch(provData.Goals[j].Label)
+
+			if len(clkTimeWildMatches) > 0 {
+				provData.Goals[j].Time = clkTimeWildMatches[1]
+			}
+
+			if len(clkTimeTwoMatches) > 0 {
+				provData.Goals[j].Time = clkTimeTwoMatches[1]
+			}
+		}
+
+		// Prefix goals with condition.
+		provData.Goals[j].ID = fmt.Sprintf("run_%d_%s_%s", iteration, condition, provData.Goals[j].ID)
+
+		// Tentative mark as condition not yet achieved
+		// until we can do graph operations on this provenance.
+		provData.Goals[j].CondHolds = false
+	}
+
+	// Prefix rules with condition.
+	for j := range provData.Rules {
+		provData.Rules[j].ID = fmt.Sprintf("run_%d_%s_%s", iteration, condition, provData.Rules[j].ID)
+	}
+
+	// Prefix edges with condition.
+	for j := range provData.Edges {
+		provData.Edges[j].From = fmt.Sprintf("run_%d_%s_%s", iteration, condition, provData.Edges[j].From)
+		provData.Edges[j].To = fmt.Sprintf("run_%d_%s_%s", iteration, condition, provData.Edges[j].To)
+	}
+}
+
+// condTables maps the conditions pre and post to
+// the names of the tables defining them in models,
+// which default to the names of the conditions.
+func condTables(preTable string, postTable string) map[string]string {
+
+	tables := map[string]string{
+		"pre":  "pre",
+		"post": "post",
+	}
+
+	if preTable != "" {
+		tables["pre"] = preTable
+	}
+
+	if postTable != "" {
+		tables["post"] = postTable
+	}
+
+	return tables
+}
+
+// isDefault returns whether tables are the
+// conditions pre and post as named by Molly.
+func isDefault(tables map[string]string) bool {
+	return (tables["pre"] == "pre") && (tables["post"] == "post")
+}
+
+// invariantStatus checks in the model of run whether
+// every row of the antecedent table holding at the end
+// of time has a matching row in the consequent table.
+func invariantStatus(run *Run, tables map[string]string) string {
+
+	eot := ""
+	if run.FailureSpec != nil {
+		eot = fmt.Sprintf("%d", run.FailureSpec.EOT)
+	}
+
+	// Collect consequent rows without their time.
+	post := make(map[string]bool)
+	for _, row := range run.Model.Tables[tables["post"]] {
+
+		if (len(row) > 0) && ((eot == "") || (row[(len(row)-1)] == eot)) {
+			post[strings.Join(row[:(len(row)-1)], "\x00")] = true
+		}
+	}
+
+	for _, row := range run.Model.Tables[tables["pre"]] {
+
+		if (len(row) > 0) && ((eot == "") || (row[(len(row)-1)] == eot)) && !post[strings.Join(row[:(len(row)-1)], "\x00")] {
+			return "failure"
+		}
+	}
+
+	return "success"
+}
+
+// prepareRun derives the lookup structures Nemo
+// requires from a run as read from a fault injector.
+// Provenance is prepared separately by prepareProv.
+func prepareRun(run *Run, tables map[string]string) error {
+
+	if run.Model == nil {
+		return fmt.Errorf("Run %d is missing its model", run.Iteration)
+	}
+
+	// Create lookup map for when the
+	// antecedent holds in this run.
+	run.TimePreHolds = make(map[string]bool)
+	for j, table := range run.Model.Tables[tables["pre"]] {
+
+		if len(table) == 0 {
+			return fmt.Errorf("Run %d: row %d of antecedent table is empty", run.Iteration, j)
+		}
+
+		run.TimePreHolds[table[(len(table)-1)]] = true
+	}
+
+	// Create lookup map for when the
+	// consequent holds in this run.
+	run.TimePostHolds = make(map[string]bool)
+	for j, table := range run.Model.Tables[tables["post"]] {
+
+		if len(table) == 0 {
+			return fmt.Errorf("Run %d: row %d of consequent table is empty", run.Iteration, j)
+		}
+
+		run.TimePostHolds[table[(len(table)-1)]] = true
+	}
+
+	// The status reported by the fault injector refers
+	// to pre and post. Check other invariants ourselves.
+	if !isDefault(tables) {
+		run.Status = invariantStatus(run, tables)
+	}
+
+	// Prepare slice for recommendations.
+	run.Recommendation = make([]string, 0, 5)
+
+	return nil
+}
+
+// decodeRuns decodes a JSON array of runs
+// element by element from the supplied decoder.
+func decodeRuns(dec *json.Decoder) ([]*Run, error) {
+
+	runs := make([]*Run, 0, 10)
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	if delim, ok := tok.(json.Delim); !ok || (delim != '[') {
+		return nil, fmt.Errorf("expected array of runs, found %v", tok)
+	}
+
+	for dec.More() {
+
+		var run *Run
+
+		err := dec.Decode(&run)
+		if err != nil {
+			return nil, err
+		}
+
+		runs = append(runs, run)
+	}
+
+	// Consume closing bracket.
+	_, err = dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+// decodeProvFile decodes one provenance file.
+func decodeProvFile(fsys fs.FS, file string) (*ProvData, error) {
+
+	provFile, err := fsys.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("Failed reading provenance of file '%v': %v", file, err)
+	}
+	defer provFile.Close()
+
+	var provData *ProvData
+
+	err = json.NewDecoder(bufio.NewReader(provFile)).Decode(&provData)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal JSON provenance data of file '%v': %v", file, err)
+	}
+
+	if provData == nil {
+		return nil, fmt.Errorf("Provenance file '%v' is empty", file)
+	}
+
+	return provData, nil
+}
+
+// decodeProvs decodes the provenance files of all
+// jobs with a pool of at most workers goroutines,
+// defaulting to the number of CPUs. If prepare is
+// set, provenance is prepared for use in Nemo.
+func decodeProvs(fsys fs.FS, jobs []*provJob, workers int, prepare bool) error {
+
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+
+	jobsChan := make(chan *provJob)
+	errs := make([]error, workers)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+
+	for w := 0; w < workers; w++ {
+
+		go func(w int) {
+
+			defer wg.Done()
+
+			for job := range jobsChan {
+
+				// Keep draining jobs after an error
+				// so that the producer never blocks.
+				if errs[w] != nil {
+					continue
+				}
+
+				provData, err := decodeProvFile(fsys, job.file)
+				if err != nil {
+					errs[w] = err
+					continue
+				}
+
+				if prepare {
+					prepareProv(job.run.Iteration, job.condition, provData)
+				}
+
+				// Each job writes a distinct field.
+				if job.condition == "pre" {
+					job.run.PreProv = provData
+				} else {
+					job.run.PostProv = provData
+				}
+			}
+		}(w)
+	}
+
+	for _, job := range jobs {
+		jobsChan <- job
+	}
+	close(jobsChan)
+
+	wg.Wait()
+
+	for _, err := range errs {
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
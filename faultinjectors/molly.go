@@ -1,165 +1,133 @@
 package faultinjectors
 
 import (
+	"bufio"
 	"fmt"
-	"regexp"
+	"os"
 
 	"encoding/json"
-	"io/ioutil"
-	"path/filepath"
+	"io/fs"
 )
 
 // Functions.
 
-// LoadOutput
-func (m *Molly) LoadOutput() error {
+// fsys returns the file system holding the output
+// of Molly, by default directory OutputDir.
+func (m *Molly) fsys() fs.FS {
+
+	if m.FS == nil {
+		m.FS = os.DirFS(m.OutputDir)
+	}
+
+	return m.FS
+}
+
+// readRuns decodes runs.json one run at a time
+// into m.Runs, without reading any provenance.
+func (m *Molly) readRuns() error {
 
-	// Find out how many iterations the fault injection run contains.
-	rawRunsCont, err := ioutil.ReadFile(filepath.Join(m.OutputDir, "runs.json"))
+	runsFile, err := m.fsys().Open("runs.json")
 	if err != nil {
 		return fmt.Errorf("Could not read runs.json file in faultInjOut directory: %v", err)
 	}
+	defer runsFile.Close()
 
-	// Read runs.json file into structure defined above.
-	err = json.Unmarshal(rawRunsCont, &m.Runs)
+	m.Runs, err = decodeRuns(json.NewDecoder(bufio.NewReader(runsFile)))
 	if err != nil {
-		return fmt.Errorf("Failed to unmarshal JSON content to runs structure: %v\n", err)
+		return fmt.Errorf("Failed to unmarshal JSON content to runs structure: %v", err)
 	}
 
-	m.RunsIters = make([]uint, len(m.Runs))
-	m.SuccessRunsIters = make([]uint, 0, len(m.Runs))
-	m.FailedRunsIters = make([]uint, 0, 3)
+	return nil
+}
 
-	// Load antecedent and consequent provenance for each iteration.
-	for i := range m.Runs {
+// provJobs returns the provenance files of the
+// specified runs that have not been loaded yet.
+// Files are named after the condition tables.
+func (m *Molly) provJobs(iters []uint) ([]*provJob, error) {
 
-		// Create lookup map for when the
-		// antecedent holds in this run.
-		m.Runs[i].TimePreHolds = make(map[string]bool)
-		for _, table := range m.Runs[i].Model.Tables["pre"] {
-			m.Runs[i].TimePreHolds[table[(len(table)-1)]] = true
-		}
+	jobs := make([]*provJob, 0, (2 * len(iters)))
+	tables := condTables(m.PreTable, m.PostTable)
 
-		// Create lookup map for when the
-		// consequent holds in this run.
-		m.Runs[i].TimePostHolds = make(map[string]bool)
-		for _, table := range m.Runs[i].Model.Tables["post"] {
-			m.Runs[i].TimePostHolds[table[(len(table)-1)]] = true
-		}
+	for _, iter := range iters {
 
-		// Note return status of fault injection
-		// run in separate structure.
-		m.RunsIters[i] = m.Runs[i].Iteration
-		if m.Runs[i].Status == "success" {
-			m.SuccessRunsIters = append(m.SuccessRunsIters, m.Runs[i].Iteration)
-		} else {
-			m.FailedRunsIters = append(m.FailedRunsIters, m.Runs[i].Iteration)
+		if iter >= uint(len(m.Runs)) {
+			return nil, fmt.Errorf("Run %d does not exist in runs.json", iter)
 		}
 
-		preProvFile := filepath.Join(m.OutputDir, fmt.Sprintf("run_%d_pre_provenance.json", i))
-		postProvFile := filepath.Join(m.OutputDir, fmt.Sprintf("run_%d_post_provenance.json", i))
+		run := m.Runs[iter]
 
-		rawPreProvCont, err := ioutil.ReadFile(preProvFile)
-		if err != nil {
-			return fmt.Errorf("Failed reading antecedent provenance of file '%v': %v", preProvFile, err)
+		if run.PreProv == nil {
+			jobs = append(jobs, &provJob{
+				run:       run,
+				condition: "pre",
+				file:      fmt.Sprintf("run_%d_%s_provenance.json", run.Iteration, tables["pre"]),
+			})
 		}
 
-		err = json.Unmarshal(rawPreProvCont, &m.Runs[i].PreProv)
-		if err != nil {
-			return fmt.Errorf("Failed to unmarshal JSON antecedent provenance data: %v\n", err)
+		if run.PostProv == nil {
+			jobs = append(jobs, &provJob{
+				run:       run,
+				condition: "post",
+				file:      fmt.Sprintf("run_%d_%s_provenance.json", run.Iteration, tables["post"]),
+			})
 		}
+	}
 
-		for j := range m.Runs[i].PreProv.Goals {
-
-			if m.Runs[i].PreProv.Goals[j].Table == "clock" {
-
-				clkTimeWildRegex := regexp.MustCompile(`, ([\d]+), __WILDCARD__\)`)
-				clkTimeWildMatches := clkTimeWildRegex.FindStringSubmatch(m.Runs[i].PreProv.Goals[j].Label)
-
-				clkTimeTwoRegex := regexp.MustCompile(`, ([\d]+), ([\d]+)\)`)
-				clkTimeTwoMatches := clkTimeTwoRegex.FindStringSubmatch(m.Runs[i].PreProv.Goals[j].Label)
-
-				if len(clkTimeWildMatches) > 0 {
-					m.Runs[i].PreProv.Goals[j].Time = clkTimeWildMatches[1]
-				}
+	return jobs, nil
+}
 
-				if len(clkTimeTwoMatches) > 0 {
-					m.Runs[i].PreProv.Goals[j].Time = clkTimeTwoMatches[1]
-				}
-			}
+// LoadOutput reads runs.json. Provenance is only
+// loaded for the runs passed to LoadProvenance.
+func (m *Molly) LoadOutput() error {
 
-			// Prefix goals with "pre_".
-			m.Runs[i].PreProv.Goals[j].ID = fmt.Sprintf("run_%d_pre_%s", m.Runs[i].Iteration, m.Runs[i].PreProv.Goals[j].ID)
+	err := m.readRuns()
+	if err != nil {
+		return err
+	}
 
-			// Tentative mark as antecedent not yet achieved
-			// until we can do graph operations on this provenance.
-			m.Runs[i].PreProv.Goals[j].CondHolds = false
-		}
+	m.RunsIters = make([]uint, len(m.Runs))
+	m.SuccessRunsIters = make([]uint, 0, len(m.Runs))
+	m.FailedRunsIters = make([]uint, 0, 3)
 
-		// Prefix rules with "pre_".
-		for j := range m.Runs[i].PreProv.Rules {
-			m.Runs[i].PreProv.Rules[j].ID = fmt.Sprintf("run_%d_pre_%s", m.Runs[i].Iteration, m.Runs[i].PreProv.Rules[j].ID)
-		}
+	for i := range m.Runs {
 
-		// Prefix edges with "pre_".
-		for j := range m.Runs[i].PreProv.Edges {
-			m.Runs[i].PreProv.Edges[j].From = fmt.Sprintf("run_%d_pre_%s", m.Runs[i].Iteration, m.Runs[i].PreProv.Edges[j].From)
-			m.Runs[i].PreProv.Edges[j].To = fmt.Sprintf("run_%d_pre_%s", m.Runs[i].Iteration, m.Runs[i].PreProv.Edges[j].To)
+		if m.Runs[i] == nil {
+			return fmt.Errorf("Run at index %d in runs.json is null", i)
 		}
 
-		rawPostProvCont, err := ioutil.ReadFile(postProvFile)
-		if err != nil {
-			return fmt.Errorf("Failed reading consequent provenance of file '%v': %v", postProvFile, err)
+		if m.Runs[i].Iteration != uint(i) {
+			return fmt.Errorf("Run at index %d in runs.json has iteration %d", i, m.Runs[i].Iteration)
 		}
 
-		err = json.Unmarshal(rawPostProvCont, &m.Runs[i].PostProv)
+		err := prepareRun(m.Runs[i], condTables(m.PreTable, m.PostTable))
 		if err != nil {
-			return fmt.Errorf("Failed to unmarshal JSON consequent provenance data: %v\n", err)
+			return err
 		}
 
-		for j := range m.Runs[i].PostProv.Goals {
-
-			if m.Runs[i].PostProv.Goals[j].Table == "clock" {
-
-				clkTimeWildRegex := regexp.MustCompile(`, ([\d]+), __WILDCARD__\)`)
-				clkTimeWildMatches := clkTimeWildRegex.FindStringSubmatch(m.Runs[i].PostProv.Goals[j].Label)
-
-				clkTimeTwoRegex := regexp.MustCompile(`, ([\d]+), ([\d]+)\)`)
-				clkTimeTwoMatches := clkTimeTwoRegex.FindStringSubmatch(m.Runs[i].PostProv.Goals[j].Label)
-
-				if len(clkTimeWildMatches) > 0 {
-					m.Runs[i].PostProv.Goals[j].Time = clkTimeWildMatches[1]
-				}
-
-				if len(clkTimeTwoMatches) > 0 {
-					m.Runs[i].PostProv.Goals[j].Time = clkTimeTwoMatches[1]
-				}
-			}
-
-			// Prefix goals with "post_".
-			m.Runs[i].PostProv.Goals[j].ID = fmt.Sprintf("run_%d_post_%s", m.Runs[i].Iteration, m.Runs[i].PostProv.Goals[j].ID)
-
-			// Tentative mark as consequent not yet achieved
-			// until we can do graph operations on this provenance.
-			m.Runs[i].PostProv.Goals[j].CondHolds = false
+		// Note return status of fault injection
+		// run in separate structure.
+		m.RunsIters[i] = m.Runs[i].Iteration
+		if m.Runs[i].Status == "success" {
+			m.SuccessRunsIters = append(m.SuccessRunsIters, m.Runs[i].Iteration)
+		} else {
+			m.FailedRunsIters = append(m.FailedRunsIters, m.Runs[i].Iteration)
 		}
+	}
 
-		// Prefix rules with "post_".
-		for j := range m.Runs[i].PostProv.Rules {
-			m.Runs[i].PostProv.Rules[j].ID = fmt.Sprintf("run_%d_post_%s", m.Runs[i].Iteration, m.Runs[i].PostProv.Rules[j].ID)
-		}
+	return nil
+}
 
-		// Prefix edges with "post_".
-		for j := range m.Runs[i].PostProv.Edges {
-			m.Runs[i].PostProv.Edges[j].From = fmt.Sprintf("run_%d_post_%s", m.Runs[i].Iteration, m.Runs[i].PostProv.Edges[j].From)
-			m.Runs[i].PostProv.Edges[j].To = fmt.Sprintf("run_%d_post_%s", m.Runs[i].Iteration, m.Runs[i].PostProv.Edges[j].To)
-		}
+// LoadProvenance decodes antecedent and consequent
+// provenance of the specified runs concurrently.
+// Runs loaded before are skipped.
+func (m *Molly) LoadProvenance(iters []uint) error {
 
-		// Prepare slice for recommendations.
-		m.Runs[i].Recommendation = make([]string, 0, 5)
+	jobs, err := m.provJobs(iters)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return decodeProvs(m.fsys(), jobs, m.Workers, true)
 }
 
 // GetFailureSpec returns the failure specification of this analysis.
@@ -0,0 +1,224 @@
+package faultinjectors
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"encoding/json"
+	"io/fs"
+	"path/filepath"
+)
+
+// Functions.
+
+// String formats a problem for printing.
+func (p *Problem) String() string {
+	return fmt.Sprintf("%s: %s: %s", p.File, p.Path, p.Message)
+}
+
+// validateProv checks one provenance graph for
+// duplicate IDs, goals and rules without tables,
+// and edges that do not connect a goal and a rule.
+// Paths of problems start at prefix.
+func validateProv(file string, prefix string, provData *ProvData) []*Problem {
+
+	problems := make([]*Problem, 0)
+
+	// Track which IDs denote goals and which rules.
+	isGoal := make(map[string]bool)
+
+	for j := range provData.Goals {
+
+		path := fmt.Sprintf("%s.goals[%d]", prefix, j)
+
+		if provData.Goals[j].ID == "" {
+			problems = append(problems, &Problem{file, fmt.Sprintf("%s.id", path), "goal without ID"})
+			continue
+		}
+
+		if _, seen := isGoal[provData.Goals[j].ID]; seen {
+			problems = append(problems, &Problem{file, fmt.Sprintf("%s.id", path), fmt.Sprintf("duplicate ID '%s'", provData.Goals[j].ID)})
+		}
+		isGoal[provData.Goals[j].ID] = true
+
+		// Edges are imported based on this naming.
+		if !strings.Contains(provData.Goals[j].ID, "goal") {
+			problems = append(problems, &Problem{file, fmt.Sprintf("%s.id", path), fmt.Sprintf("goal ID '%s' does not contain 'goal'", provData.Goals[j].ID)})
+		}
+
+		if provData.Goals[j].Table == "" {
+			problems = append(problems, &Problem{file, fmt.Sprintf("%s.table", path), fmt.Sprintf("goal '%s' without table", provData.Goals[j].ID)})
+		}
+	}
+
+	for j := range provData.Rules {
+
+		path := fmt.Sprintf("%s.rules[%d]", prefix, j)
+
+		if provData.Rules[j].ID == "" {
+			problems = append(problems, &Problem{file, fmt.Sprintf("%s.id", path), "rule without ID"})
+			continue
+		}
+
+		if _, seen := isGoal[provData.Rules[j].ID]; seen {
+			problems = append(problems, &Problem{file, fmt.Sprintf("%s.id", path), fmt.Sprintf("duplicate ID '%s'", provData.Rules[j].ID)})
+		}
+		isGoal[provData.Rules[j].ID] = false
+
+		if strings.Contains(provData.Rules[j].ID, "goal") {
+			problems = append(problems, &Problem{file, fmt.Sprintf("%s.id", path), fmt.Sprintf("rule ID '%s' contains 'goal'", provData.Rules[j].ID)})
+		}
+
+		if provData.Rules[j].Table == "" {
+			problems = append(problems, &Problem{file, fmt.Sprintf("%s.table", path), fmt.Sprintf("rule '%s' without table", provData.Rules[j].ID)})
+		}
+	}
+
+	for j := range provData.Edges {
+
+		path := fmt.Sprintf("%s.edges[%d]", prefix, j)
+
+		fromGoal, fromFound := isGoal[provData.Edges[j].From]
+		if !fromFound {
+			problems = append(problems, &Problem{file, fmt.Sprintf("%s.from", path), fmt.Sprintf("dangling edge endpoint '%s'", provData.Edges[j].From)})
+		}
+
+		toGoal, toFound := isGoal[provData.Edges[j].To]
+		if !toFound {
+			problems = append(problems, &Problem{file, fmt.Sprintf("%s.to", path), fmt.Sprintf("dangling edge endpoint '%s'", provData.Edges[j].To)})
+		}
+
+		// Provenance alternates between goals and rules.
+		if fromFound && toFound && (fromGoal == toGoal) {
+			problems = append(problems, &Problem{file, path, fmt.Sprintf("edge '%s' -> '%s' does not connect a goal and a rule", provData.Edges[j].From, provData.Edges[j].To)})
+		}
+	}
+
+	return problems
+}
+
+// validateRuns checks the fields of all runs that
+// Nemo relies on. Paths of problems start at prefix.
+// Models have to contain the condition tables.
+func validateRuns(file string, prefix string, runs []*Run, tables map[string]string) []*Problem {
+
+	problems := make([]*Problem, 0)
+
+	if len(runs) == 0 {
+		problems = append(problems, &Problem{file, prefix, "no runs"})
+	}
+
+	seenIters := make(map[uint]int)
+
+	for i := range runs {
+
+		path := fmt.Sprintf("%s[%d]", prefix, i)
+
+		if runs[i] == nil {
+			problems = append(problems, &Problem{file, path, "run is null"})
+			continue
+		}
+
+		// Runs are addressed by their iteration number
+		// all over Nemo, so it has to equal the index.
+		if runs[i].Iteration != uint(i) {
+			problems = append(problems, &Problem{file, fmt.Sprintf("%s.iteration", path), fmt.Sprintf("iteration %d does not match array index %d", runs[i].Iteration, i)})
+		}
+
+		if first, seen := seenIters[runs[i].Iteration]; seen {
+			problems = append(problems, &Problem{file, fmt.Sprintf("%s.iteration", path), fmt.Sprintf("iteration %d already used by %s[%d]", runs[i].Iteration, prefix, first)})
+		} else {
+			seenIters[runs[i].Iteration] = i
+		}
+
+		if runs[i].FailureSpec == nil {
+			problems = append(problems, &Problem{file, fmt.Sprintf("%s.failureSpec", path), "missing failure specification"})
+		}
+
+		if runs[i].Model == nil {
+			problems = append(problems, &Problem{file, fmt.Sprintf("%s.model", path), "missing model"})
+			continue
+		}
+
+		for _, cond := range []string{"pre", "post"} {
+
+			table, found := runs[i].Model.Tables[tables[cond]]
+			if !found {
+				problems = append(problems, &Problem{file, fmt.Sprintf("%s.model.tables", path), fmt.Sprintf("missing table '%s'", tables[cond])})
+				continue
+			}
+
+			for j := range table {
+
+				if len(table[j]) == 0 {
+					problems = append(problems, &Problem{file, fmt.Sprintf("%s.model.tables.%s[%d]", path, tables[cond], j), "empty row, expected time in last column"})
+				}
+			}
+		}
+	}
+
+	return problems
+}
+
+// Validate checks the complete output directory
+// of Molly and reports every problem it finds,
+// instead of stopping at the first one.
+func (m *Molly) Validate() []*Problem {
+
+	runsFile := filepath.Join(m.OutputDir, "runs.json")
+
+	rawRunsCont, err := fs.ReadFile(m.fsys(), "runs.json")
+	if err != nil {
+		return []*Problem{{runsFile, "$", fmt.Sprintf("could not read file: %v", err)}}
+	}
+
+	var runs []*Run
+
+	err = json.Unmarshal(rawRunsCont, &runs)
+	if err != nil {
+		return []*Problem{{runsFile, "$", fmt.Sprintf("could not unmarshal runs: %v", err)}}
+	}
+
+	tables := condTables(m.PreTable, m.PostTable)
+	problems := validateRuns(runsFile, "$", runs, tables)
+
+	for i := range runs {
+
+		if runs[i] == nil {
+			continue
+		}
+
+		for _, cond := range []string{"pre", "post"} {
+
+			provName := fmt.Sprintf("run_%d_%s_provenance.json", runs[i].Iteration, tables[cond])
+			provFile := filepath.Join(m.OutputDir, provName)
+
+			rawProvCont, err := fs.ReadFile(m.fsys(), provName)
+			if errors.Is(err, fs.ErrNotExist) {
+				problems = append(problems, &Problem{provFile, "$", fmt.Sprintf("run %d has no '%s' provenance", runs[i].Iteration, tables[cond])})
+				continue
+			} else if err != nil {
+				problems = append(problems, &Problem{provFile, "$", fmt.Sprintf("could not read file: %v", err)})
+				continue
+			}
+
+			var provData *ProvData
+
+			err = json.Unmarshal(rawProvCont, &provData)
+			if err != nil {
+				problems = append(problems, &Problem{provFile, "$", fmt.Sprintf("could not unmarshal provenance: %v", err)})
+				continue
+			}
+
+			if provData == nil {
+				problems = append(problems, &Problem{provFile, "$", fmt.Sprintf("run %d has no '%s' provenance", runs[i].Iteration, tables[cond])})
+				continue
+			}
+
+			problems = append(problems, validateProv(provFile, "$", provData)...)
+		}
+	}
+
+	return problems
+}
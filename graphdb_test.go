@@ -0,0 +1,710 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"io/fs"
+
+	"github.com/awalterschulze/gographviz"
+	fi "github.com/numbleroot/nemo/faultinjectors"
+	gr "github.com/numbleroot/nemo/graphing"
+)
+
+// Structs.
+
+// fakeGraphDB is an in-process graph backend for tests. It keeps
+// the provenance of all runs in memory instead of Neo4j. Analyses
+// that do not query the database run the real implementation.
+// Differential provenance and the trigger events behind
+// corrections and extensions are found in memory the way the
+// Cypher queries define them, so these tests do not exercise the
+// queries. TestPipelineGoldenNeo4J does, given a Neo4j instance.
+// Prototypes need Neo4j and are not available.
+type fakeGraphDB struct {
+	neo         *gr.Neo4J
+	runs        []*fi.Run
+	loaded      []*fi.Run
+	pre         []*fi.ProvGraph
+	post        []*fi.ProvGraph
+	unsupported error
+}
+
+// Functions.
+
+// newFakeGraphDB returns an empty fake backend
+// analyzing like neo, without connecting it.
+func newFakeGraphDB(neo *gr.Neo4J) *fakeGraphDB {
+
+	return &fakeGraphDB{
+		neo:         neo,
+		unsupported: fmt.Errorf("Not available without Neo4j"),
+	}
+}
+
+// provGraph converts provenance as read from a fault injector
+// into the structure pulled from the database, which only
+// holds nodes connected by edges.
+func provGraph(iteration uint, condition string, variant string, prov *fi.ProvData) *fi.ProvGraph {
+
+	graph := &fi.ProvGraph{
+		Iteration: iteration,
+		Condition: condition,
+		Variant:   variant,
+		Nodes:     make([]*fi.ProvNode, 0, (len(prov.Goals) + len(prov.Rules))),
+		Edges:     make([]*fi.Edge, 0, len(prov.Edges)),
+	}
+
+	nodes := make(map[string]*fi.ProvNode, (len(prov.Goals) + len(prov.Rules)))
+
+	for _, goal := range prov.Goals {
+
+		nodes[goal.ID] = &fi.ProvNode{
+			ID:        goal.ID,
+			Kind:      "goal",
+			Label:     goal.Label,
+			Table:     goal.Table,
+			Time:      goal.Time,
+			CondHolds: goal.CondHolds,
+		}
+	}
+
+	for _, rule := range prov.Rules {
+
+		nodes[rule.ID] = &fi.ProvNode{
+			ID:    rule.ID,
+			Kind:  "rule",
+			Label: rule.Label,
+			Table: rule.Table,
+			Type:  rule.Type,
+		}
+	}
+
+	seen := make(map[string]bool)
+
+	for _, edge := range prov.Edges {
+
+		for _, id := range []string{edge.From, edge.To} {
+
+			if !seen[id] && (nodes[id] != nil) {
+				seen[id] = true
+				graph.Nodes = append(graph.Nodes, nodes[id])
+			}
+		}
+
+		graph.Edges = append(graph.Edges, &fi.Edge{From: edge.From, To: edge.To})
+	}
+
+	return graph
+}
+
+// neighbors returns the nodes of graph by ID, along with
+// the nodes each node has an edge from and to.
+func neighbors(graph *fi.ProvGraph) (map[string]*fi.ProvNode, map[string][]*fi.ProvNode, map[string][]*fi.ProvNode) {
+
+	nodes := make(map[string]*fi.ProvNode, len(graph.Nodes))
+	for _, node := range graph.Nodes {
+		nodes[node.ID] = node
+	}
+
+	from := make(map[string][]*fi.ProvNode)
+	to := make(map[string][]*fi.ProvNode)
+
+	for _, edge := range graph.Edges {
+		from[edge.To] = append(from[edge.To], nodes[edge.From])
+		to[edge.From] = append(to[edge.From], nodes[edge.To])
+	}
+
+	return nodes, from, to
+}
+
+// toGoal converts a goal node the way the database
+// queries do, its label naming the receiver first.
+func toGoal(node *fi.ProvNode) *fi.Goal {
+
+	label := strings.TrimLeft(node.Label, node.Table)
+	label = strings.Trim(label, "()")
+	labelParts := strings.Split(label, ", ")
+
+	return &fi.Goal{
+		ID:        node.ID,
+		Label:     node.Label,
+		Table:     node.Table,
+		Time:      node.Time,
+		CondHolds: node.CondHolds,
+		Receiver:  labelParts[0],
+	}
+}
+
+// toRule converts a rule node.
+func toRule(node *fi.ProvNode) *fi.Rule {
+	return &fi.Rule{ID: node.ID, Label: node.Label, Table: node.Table, Type: node.Type}
+}
+
+// anyNode reports whether any of nodes is of kind
+// and, if holds is given, whether its condition holds.
+func anyNode(nodes []*fi.ProvNode, kind string, holds ...bool) bool {
+
+	for _, node := range nodes {
+
+		if (node.Kind == kind) && ((len(holds) == 0) || (node.CondHolds == holds[0])) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// markConditionHolds marks the goals of the condition table,
+// and of the tables of goals that a root goal of that table
+// is derived from, as the condition holding there.
+func markConditionHolds(graph *fi.ProvGraph, table string) {
+
+	_, from, to := neighbors(graph)
+	tables := make(map[string]bool)
+
+	for _, root := range graph.Nodes {
+
+		if (root.Kind != "goal") || (root.Table != table) || (len(from[root.ID]) > 0) {
+			continue
+		}
+
+		for _, rule := range to[root.ID] {
+
+			if (rule.Kind != "rule") || (rule.Table != table) {
+				continue
+			}
+
+			for _, goal := range to[rule.ID] {
+
+				if (goal.Kind == "goal") && anyNode(to[goal.ID], "rule") {
+					tables[goal.Table] = true
+				}
+			}
+		}
+	}
+
+	if len(tables) == 0 {
+		return
+	}
+
+	tables[table] = true
+	for _, node := range graph.Nodes {
+
+		if (node.Kind == "goal") && tables[node.Table] {
+			node.CondHolds = true
+		}
+	}
+}
+
+// provDOT draws graph with one node per goal and rule.
+func provDOT(graph *fi.ProvGraph) (*gographviz.Graph, error) {
+
+	dotGraph := gographviz.NewGraph()
+
+	err := dotGraph.SetName("dataflow")
+	if err != nil {
+		return nil, err
+	}
+
+	err = dotGraph.SetDir(true)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, node := range graph.Nodes {
+
+		attrs := map[string]string{
+			"label": fmt.Sprintf("\"%s\"", node.Label),
+			"shape": "ellipse",
+		}
+
+		if node.Kind == "rule" {
+			attrs["shape"] = "rect"
+		}
+
+		if node.Missing {
+			attrs["style"] = "dashed"
+		}
+
+		err := dotGraph.AddNode("dataflow", fmt.Sprintf("\"%s\"", node.ID), attrs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, edge := range graph.Edges {
+
+		err := dotGraph.AddEdge(fmt.Sprintf("\"%s\"", edge.From), fmt.Sprintf("\"%s\"", edge.To), true, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return dotGraph, nil
+}
+
+// provDOTs draws all graphs.
+func provDOTs(graphs []*fi.ProvGraph) ([]*gographviz.Graph, error) {
+
+	dots := make([]*gographviz.Graph, len(graphs))
+	for i := range graphs {
+
+		dot, err := provDOT(graphs[i])
+		if err != nil {
+			return nil, err
+		}
+		dots[i] = dot
+	}
+
+	return dots, nil
+}
+
+// InitGraphDB keeps the runs to analyze.
+func (f *fakeGraphDB) InitGraphDB(conn string, runs []*fi.Run) error {
+
+	f.runs = runs
+	f.neo.Runs = runs
+
+	return nil
+}
+
+// CloseDB does nothing.
+func (f *fakeGraphDB) CloseDB() error {
+	return nil
+}
+
+// LoadRawProvenance converts the provenance of the
+// runs with the specified iterations.
+func (f *fakeGraphDB) LoadRawProvenance(iters []uint) error {
+
+	load := make(map[uint]bool, len(iters))
+	for _, iter := range iters {
+		load[iter] = true
+	}
+
+	f.loaded = make([]*fi.Run, 0, len(iters))
+	f.pre = make([]*fi.ProvGraph, 0, len(iters))
+	f.post = make([]*fi.ProvGraph, 0, len(iters))
+
+	for _, run := range f.runs {
+
+		if !load[run.Iteration] {
+			continue
+		}
+
+		if (run.PreProv == nil) || (run.PostProv == nil) {
+			return fmt.Errorf("Provenance of run %d not loaded", run.Iteration)
+		}
+
+		pre := provGraph(run.Iteration, "pre", "raw", run.PreProv)
+		post := provGraph(run.Iteration, "post", "raw", run.PostProv)
+
+		markConditionHolds(pre, f.neo.PreTable)
+		markConditionHolds(post, f.neo.PostTable)
+
+		f.loaded = append(f.loaded, run)
+		f.pre = append(f.pre, pre)
+		f.post = append(f.post, post)
+	}
+
+	return nil
+}
+
+// SimplifyProv applies no passes, cleaned-up
+// provenance equals the raw provenance.
+func (f *fakeGraphDB) SimplifyProv(iters []uint) (map[uint]map[string][]string, error) {
+
+	simplifiedBy := make(map[uint]map[string][]string, len(iters))
+	for _, iter := range iters {
+		simplifiedBy[iter] = map[string][]string{"pre": {}, "post": {}}
+	}
+
+	return simplifiedBy, nil
+}
+
+// CreateHazardAnalysis runs the real analysis.
+func (f *fakeGraphDB) CreateHazardAnalysis(faultInjOut fs.FS) ([]*gographviz.Graph, error) {
+	return f.neo.CreateHazardAnalysis(faultInjOut)
+}
+
+// CreatePrototypes needs Neo4j.
+func (f *fakeGraphDB) CreatePrototypes(iters []uint, failedIters []uint) ([]string, [][]string, []string, [][]string, error) {
+	return nil, nil, nil, nil, f.unsupported
+}
+
+// CreatePrototypeGraphs needs Neo4j.
+func (f *fakeGraphDB) CreatePrototypeGraphs(iters []uint, failedIters []uint) (*gographviz.Graph, *gographviz.Graph, []*gographviz.Graph, []*gographviz.Graph, error) {
+	return nil, nil, nil, nil, f.unsupported
+}
+
+// PullPrePostProv draws the provenance of all runs.
+func (f *fakeGraphDB) PullPrePostProv() ([]*gographviz.Graph, []*gographviz.Graph, []*gographviz.Graph, []*gographviz.Graph, error) {
+
+	pre, post, preClean, postClean, err := f.PullProvGraphs()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	all := make([][]*gographviz.Graph, 4)
+	for i, graphs := range [][]*fi.ProvGraph{pre, post, preClean, postClean} {
+
+		all[i], err = provDOTs(graphs)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+	}
+
+	return all[0], all[1], all[2], all[3], nil
+}
+
+// PullProvGraphs returns the provenance of all runs,
+// cleaned-up versions being copies of the raw ones.
+func (f *fakeGraphDB) PullProvGraphs() ([]*fi.ProvGraph, []*fi.ProvGraph, []*fi.ProvGraph, []*fi.ProvGraph, error) {
+
+	if f.post == nil {
+		return nil, nil, nil, nil, fmt.Errorf("Provenance not loaded")
+	}
+
+	preClean := make([]*fi.ProvGraph, len(f.loaded))
+	postClean := make([]*fi.ProvGraph, len(f.loaded))
+
+	for i, run := range f.loaded {
+		preClean[i] = provGraph(run.Iteration, "pre", "clean", run.PreProv)
+		postClean[i] = provGraph(run.Iteration, "post", "clean", run.PostProv)
+
+		markConditionHolds(preClean[i], f.neo.PreTable)
+		markConditionHolds(postClean[i], f.neo.PostTable)
+	}
+
+	return f.pre, f.post, preClean, postClean, nil
+}
+
+// CreateTableAbstraction runs the real analysis.
+func (f *fakeGraphDB) CreateTableAbstraction(preProvs []*fi.ProvGraph, postProvs []*fi.ProvGraph, failedIters []uint) ([]*gographviz.Graph, []*gographviz.Graph, []*gographviz.Graph, []*gr.TableGraph, error) {
+	return f.neo.CreateTableAbstraction(preProvs, postProvs, failedIters)
+}
+
+// CreateNaiveDiffProv keeps the part of the consequent
+// provenance of the good run whose goals the failed run
+// did not derive. Missing events are the rules deepest in
+// that part, along with the goals they lack.
+func (f *fakeGraphDB) CreateNaiveDiffProv(symmetric bool, failedIters []uint, successPostProv *gographviz.Graph) ([]*gographviz.Graph, []*gographviz.Graph, []*fi.ProvGraph, [][]*fi.Missing, error) {
+
+	var good *fi.ProvGraph
+	byIter := make(map[uint]*fi.ProvGraph, len(f.post))
+	for _, graph := range f.post {
+
+		byIter[graph.Iteration] = graph
+		if graph.Iteration == f.neo.GoodRun {
+			good = graph
+		}
+	}
+
+	if good == nil {
+		return nil, nil, nil, nil, fmt.Errorf("Missing consequent provenance of good run %d", f.neo.GoodRun)
+	}
+
+	// The real backend draws onto the DOT graph passed,
+	// which thus has to be the one of the good run.
+	for _, node := range successPostProv.Nodes.Nodes {
+
+		if !strings.HasPrefix(strings.Trim(node.Name, "\""), fmt.Sprintf("run_%d_", f.neo.GoodRun)) {
+			return nil, nil, nil, nil, fmt.Errorf("Consequent provenance passed as the one of good run %d holds node %s", f.neo.GoodRun, node.Name)
+		}
+	}
+
+	diffDots := make([]*gographviz.Graph, len(failedIters))
+	failedDots := make([]*gographviz.Graph, len(failedIters))
+	diffGraphs := make([]*fi.ProvGraph, len(failedIters))
+	missingEvents := make([][]*fi.Missing, len(failedIters))
+
+	for i, iter := range failedIters {
+
+		failed := byIter[iter]
+		diffRunID := (2000 + iter)
+
+		failedLabels := make(map[string]bool)
+		for _, node := range failed.Nodes {
+
+			if node.Kind == "goal" {
+				failedLabels[node.Label] = true
+			}
+		}
+
+		// Keep goals the failed run lacks and
+		// the rules between them, renamed.
+		rename := func(id string) string {
+			return strings.Replace(id, fmt.Sprintf("run_%d_", f.neo.GoodRun), fmt.Sprintf("run_%d_", diffRunID), 1)
+		}
+
+		kept := make(map[string]*fi.ProvNode)
+		for _, node := range good.Nodes {
+
+			if (node.Kind == "rule") || !failedLabels[node.Label] {
+				copied := *node
+				copied.ID = rename(node.ID)
+				kept[node.ID] = &copied
+			}
+		}
+
+		diff := &fi.ProvGraph{Iteration: diffRunID, Condition: "post", Variant: "diff"}
+		parents := make(map[string]int)
+		children := make(map[string][]string)
+
+		for _, edge := range good.Edges {
+
+			if (kept[edge.From] != nil) && (kept[edge.To] != nil) {
+				diff.Edges = append(diff.Edges, &fi.Edge{From: rename(edge.From), To: rename(edge.To)})
+				parents[edge.To]++
+				children[edge.From] = append(children[edge.From], edge.To)
+			}
+		}
+
+		for _, node := range good.Nodes {
+
+			id := node.ID
+			if (kept[id] != nil) && ((parents[id] > 0) || (len(children[id]) > 0)) {
+				diff.Nodes = append(diff.Nodes, kept[id])
+			}
+		}
+
+		// Find the rules deepest below a root that
+		// derive leaves, by length of their path.
+		depth := make(map[string]int)
+		queue := make([]string, 0, len(diff.Nodes))
+		for _, node := range good.Nodes {
+
+			if (kept[node.ID] != nil) && (parents[node.ID] == 0) && (len(children[node.ID]) > 0) {
+				depth[node.ID] = 0
+				queue = append(queue, node.ID)
+			}
+		}
+
+		for len(queue) > 0 {
+
+			id := queue[0]
+			queue = queue[1:]
+
+			for _, child := range children[id] {
+
+				if _, found := depth[child]; !found {
+					depth[child] = (depth[id] + 1)
+					queue = append(queue, child)
+				}
+			}
+		}
+
+		deepest := -1
+		for id, d := range depth {
+
+			for _, child := range children[id] {
+
+				if (kept[id].Kind == "rule") && (len(children[child]) == 0) && (d > deepest) {
+					deepest = d
+				}
+			}
+		}
+
+		missing := make([]*fi.Missing, 0, 2)
+		for _, node := range good.Nodes {
+
+			d, found := depth[node.ID]
+			if !found || (d != deepest) || (node.Kind != "rule") {
+				continue
+			}
+
+			m := &fi.Missing{
+				Rule:  &fi.Rule{ID: rename(node.ID), Label: node.Label, Table: node.Table, Type: node.Type},
+				Goals: make([]*fi.Goal, 0, 2),
+			}
+
+			for _, child := range children[node.ID] {
+
+				if len(children[child]) == 0 {
+					leaf := kept[child]
+					m.Goals = append(m.Goals, &fi.Goal{ID: leaf.ID, Label: leaf.Label, Table: leaf.Table, Time: leaf.Time, CondHolds: leaf.CondHolds})
+				}
+			}
+
+			if len(m.Goals) > 0 {
+				missing = append(missing, m)
+			}
+		}
+
+		flagged := make(map[string]bool)
+		for _, m := range missing {
+
+			flagged[m.Rule.ID] = true
+			for _, goal := range m.Goals {
+				flagged[goal.ID] = true
+			}
+		}
+
+		for _, node := range diff.Nodes {
+			node.Missing = flagged[node.ID]
+		}
+
+		var err error
+
+		diffDots[i], err = provDOT(diff)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+
+		failedDots[i], err = provDOT(failed)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+
+		diffGraphs[i] = diff
+		missingEvents[i] = missing
+	}
+
+	return diffDots, failedDots, diffGraphs, missingEvents, nil
+}
+
+// CreateTemporalDiff runs the real analysis.
+func (f *fakeGraphDB) CreateTemporalDiff(preProvs []*fi.ProvGraph, postProvs []*fi.ProvGraph, runs []*fi.Run, goodRun uint, failedIters []uint) ([]*gographviz.Graph, []*fi.Divergence, error) {
+	return f.neo.CreateTemporalDiff(preProvs, postProvs, runs, goodRun, failedIters)
+}
+
+// goodRun returns the provenance of the good run.
+func (f *fakeGraphDB) goodRun() (*fi.ProvGraph, *fi.ProvGraph, error) {
+
+	for i := range f.loaded {
+
+		if f.loaded[i].Iteration == f.neo.GoodRun {
+			return f.pre[i], f.post[i], nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("Missing provenance of good run %d", f.neo.GoodRun)
+}
+
+// GenerateCorrections finds the trigger events of the good
+// run as the queries of Neo4J.GenerateCorrections do and
+// suggests corrections for them via gr.SuggestCorrections.
+func (f *fakeGraphDB) GenerateCorrections() ([]string, error) {
+
+	pre, post, err := f.goodRun()
+	if err != nil {
+		return nil, err
+	}
+
+	// Aggregation rule, trigger goal, trigger rule
+	// chains on the way to the antecedent holding.
+	preTriggers := make(map[*fi.Rule][]*gr.GoalRulePair)
+	aggs := make(map[string]*fi.Rule)
+	_, from, to := neighbors(pre)
+
+	for _, agg := range pre.Nodes {
+
+		if (agg.Kind != "rule") || !anyNode(from[agg.ID], "goal", true) {
+			continue
+		}
+
+		for _, goal := range to[agg.ID] {
+
+			if (goal.Kind != "goal") || goal.CondHolds {
+				continue
+			}
+
+			for _, rule := range to[goal.ID] {
+
+				if rule.Kind == "rule" {
+					if aggs[agg.ID] == nil {
+						aggs[agg.ID] = toRule(agg)
+					}
+
+					preTriggers[aggs[agg.ID]] = append(preTriggers[aggs[agg.ID]], &gr.GoalRulePair{Goal: toGoal(goal), Rule: toRule(rule)})
+				}
+			}
+		}
+	}
+
+	// Goal and rule pairs on the way to
+	// the consequent holding.
+	postTriggers := make(map[*fi.Goal][]*fi.Rule)
+	goals := make(map[string]*fi.Goal)
+	_, from, to = neighbors(post)
+
+	for _, goal := range post.Nodes {
+
+		if (goal.Kind != "goal") || !goal.CondHolds || !anyNode(from[goal.ID], "rule") {
+			continue
+		}
+
+		for _, rule := range to[goal.ID] {
+
+			if rule.Kind != "rule" {
+				continue
+			}
+
+			triggers := false
+			for _, next := range to[rule.ID] {
+				triggers = triggers || ((next.Kind == "goal") && !next.CondHolds && anyNode(to[next.ID], "rule"))
+			}
+
+			if triggers {
+				if goals[goal.ID] == nil {
+					goals[goal.ID] = toGoal(goal)
+				}
+
+				postTriggers[goals[goal.ID]] = append(postTriggers[goals[goal.ID]], toRule(rule))
+			}
+		}
+	}
+
+	return gr.SuggestCorrections(preTriggers, postTriggers), nil
+}
+
+// GenerateExtensions checks whether all runs achieved the
+// antecedent and, if not, suggests making the network
+// events of the good run more fault-tolerant.
+func (f *fakeGraphDB) GenerateExtensions() (bool, []string, error) {
+
+	achieved := 0
+	for _, pre := range f.pre {
+
+		for _, node := range pre.Nodes {
+
+			if (node.Kind == "goal") && (node.Table == f.neo.PreTable) && node.CondHolds {
+				achieved++
+			}
+		}
+	}
+
+	if achieved >= len(f.runs) {
+		return true, []string{}, nil
+	}
+
+	pre, _, err := f.goodRun()
+	if err != nil {
+		return false, nil, err
+	}
+
+	_, from, to := neighbors(pre)
+	asyncTables := make([]string, 0, 4)
+
+	for _, rule := range pre.Nodes {
+
+		if (rule.Kind != "rule") || (rule.Type != "async") {
+			continue
+		}
+
+		triggers := anyNode(from[rule.ID], "goal", false)
+		if anyNode(from[rule.ID], "goal", true) {
+
+			for _, next := range to[rule.ID] {
+				triggers = triggers || ((next.Kind == "goal") && !next.CondHolds && anyNode(to[next.ID], "rule"))
+			}
+		}
+
+		if triggers {
+			asyncTables = append(asyncTables, rule.Table)
+		}
+	}
+
+	return false, gr.SuggestExtensions(asyncTables), nil
+}
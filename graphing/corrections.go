@@ -3,6 +3,7 @@ package graphing
 import (
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 
 	graph "github.com/johnnadratowski/golang-neo4j-bolt-driver/structures/graph"
@@ -45,7 +46,10 @@ func (n *Neo4J) findPreTriggers(run uint) (map[*fi.Rule][]*GoalRulePair, error)
 
 	// Prepare a map indexed by aggregation rule,
 	// collecting all trigger goals and rules.
+	// Rows of the same aggregation rule share
+	// one key, found via the rule's ID.
 	triggers := make(map[*fi.Rule][]*GoalRulePair)
+	aggs := make(map[string]*fi.Rule)
 
 	for err == nil {
 
@@ -66,14 +70,17 @@ func (n *Neo4J) findPreTriggers(run uint) (map[*fi.Rule][]*GoalRulePair, error)
 			goalLabel = strings.Trim(goalLabel, "()")
 			goalLabelParts := strings.Split(goalLabel, ", ")
 
-			aggregation := &fi.Rule{
-				ID:    agg.Properties["id"].(string),
-				Label: agg.Properties["label"].(string),
-				Table: agg.Properties["table"].(string),
-				Type:  agg.Properties["type"].(string),
-			}
+			aggregation, found := aggs[agg.Properties["id"].(string)]
+			if !found {
+
+				aggregation = &fi.Rule{
+					ID:    agg.Properties["id"].(string),
+					Label: agg.Properties["label"].(string),
+					Table: agg.Properties["table"].(string),
+					Type:  agg.Properties["type"].(string),
+				}
 
-			if len(triggers[aggregation]) < 1 {
+				aggs[aggregation.ID] = aggregation
 				triggers[aggregation] = make([]*GoalRulePair, 0, 4)
 			}
 
@@ -135,8 +142,10 @@ func (n *Neo4J) findPostTriggers(run uint) (map[*fi.Goal][]*fi.Rule, error) {
 	}
 
 	// Prepare a map indexed by trigger goal,
-	// collecting all trigger rules.
+	// collecting all trigger rules. Rows of the
+	// same goal share one key, found via its ID.
 	triggers := make(map[*fi.Goal][]*fi.Rule)
+	goals := make(map[string]*fi.Goal)
 
 	for err == nil {
 
@@ -156,16 +165,19 @@ func (n *Neo4J) findPostTriggers(run uint) (map[*fi.Goal][]*fi.Rule, error) {
 			goalLabel = strings.Trim(goalLabel, "()")
 			goalLabelParts := strings.Split(goalLabel, ", ")
 
-			g := &fi.Goal{
-				ID:        goal.Properties["id"].(string),
-				Label:     goal.Properties["label"].(string),
-				Table:     goal.Properties["table"].(string),
-				Time:      goal.Properties["time"].(string),
-				CondHolds: goal.Properties["condition_holds"].(bool),
-				Receiver:  goalLabelParts[0],
-			}
+			g, found := goals[goal.Properties["id"].(string)]
+			if !found {
 
-			if len(triggers[g]) < 1 {
+				g = &fi.Goal{
+					ID:        goal.Properties["id"].(string),
+					Label:     goal.Properties["label"].(string),
+					Table:     goal.Properties["table"].(string),
+					Time:      goal.Properties["time"].(string),
+					CondHolds: goal.Properties["condition_holds"].(bool),
+					Receiver:  goalLabelParts[0],
+				}
+
+				goals[g.ID] = g
 				triggers[g] = make([]*fi.Rule, 0, 3)
 			}
 
@@ -203,21 +215,64 @@ func (n *Neo4J) GenerateCorrections() ([]string, error) {
 
 	fmt.Printf("Running generation of suggestions for corrections (pre ~> post)... ")
 
-	// Recs will contain our top-level recommendations.
-	recs := make([]string, 0, 6)
-
 	// Extract the antecedent trigger event chains.
-	preTriggers, err := n.findPreTriggers(0)
+	preTriggers, err := n.findPreTriggers(n.GoodRun)
 	if err != nil {
 		return nil, err
 	}
 
 	// Extract the consequent trigger event chains.
-	postTriggers, err := n.findPostTriggers(0)
+	postTriggers, err := n.findPostTriggers(n.GoodRun)
 	if err != nil {
 		return nil, err
 	}
 
+	recs := SuggestCorrections(preTriggers, postTriggers)
+
+	fmt.Printf("done\n\n")
+
+	return recs, nil
+}
+
+// SuggestCorrections formulates the corrections to the
+// antecedent rules that the trigger events of the good run
+// call for, in order of the tables of the trigger events.
+func SuggestCorrections(preTriggers map[*fi.Rule][]*GoalRulePair, postTriggers map[*fi.Goal][]*fi.Rule) []string {
+
+	// Recs will contain our top-level recommendations.
+	recs := make([]string, 0, 6)
+
+	// Visit trigger events in a fixed order, so
+	// that suggestions read the same every time.
+	// Keys are unique per ID, making the order total.
+	preAggs := make([]*fi.Rule, 0, len(preTriggers))
+	for preAgg := range preTriggers {
+		preAggs = append(preAggs, preAgg)
+	}
+
+	sort.Slice(preAggs, func(i, j int) bool {
+
+		if preAggs[i].Table != preAggs[j].Table {
+			return preAggs[i].Table < preAggs[j].Table
+		}
+
+		return preAggs[i].ID < preAggs[j].ID
+	})
+
+	postGoals := make([]*fi.Goal, 0, len(postTriggers))
+	for postGoal := range postTriggers {
+		postGoals = append(postGoals, postGoal)
+	}
+
+	sort.Slice(postGoals, func(i, j int) bool {
+
+		if postGoals[i].Table != postGoals[j].Table {
+			return postGoals[i].Table < postGoals[j].Table
+		}
+
+		return postGoals[i].ID < postGoals[j].ID
+	})
+
 	// Prepare slice of strings representing the
 	// compound of trigger rules required for firing
 	// the respective aggregation rule.
@@ -228,7 +283,7 @@ func (n *Neo4J) GenerateCorrections() ([]string, error) {
 	// have to take extra steps.
 	differentNodes := make(map[string]map[string][]*fi.Goal)
 
-	for preAgg := range preTriggers {
+	for _, preAgg := range preAggs {
 
 		differentNodes[preAgg.Table] = make(map[string][]*fi.Goal)
 
@@ -242,11 +297,11 @@ func (n *Neo4J) GenerateCorrections() ([]string, error) {
 		}
 	}
 
-	for preAgg := range preTriggers {
+	for _, preAgg := range preAggs {
 
 		for i := range preTriggers[preAgg] {
 
-			for postGoal := range postTriggers {
+			for _, postGoal := range postGoals {
 
 				if preTriggers[preAgg][i].Goal.Receiver != postGoal.Receiver {
 
@@ -267,7 +322,7 @@ func (n *Neo4J) GenerateCorrections() ([]string, error) {
 			// rule and all consequent rules to add are
 			// the same ones. Thus, local order suffices.
 
-			for postGoal := range postTriggers {
+			for _, postGoal := range postGoals {
 				aggNew = fmt.Sprintf("%s, %s(%s, ...)", aggNew, postGoal.Table, postGoal.Receiver)
 			}
 		} else {
@@ -276,7 +331,13 @@ func (n *Neo4J) GenerateCorrections() ([]string, error) {
 			// takes place on a different node than this
 			// antecedent's goal. We need communication.
 
+			pres := make([]string, 0, len(differentNodes[preAgg.Table]))
 			for pre := range differentNodes[preAgg.Table] {
+				pres = append(pres, pre)
+			}
+			sort.Strings(pres)
+
+			for _, pre := range pres {
 
 				for post := range differentNodes[preAgg.Table][pre] {
 
@@ -322,7 +383,18 @@ func (n *Neo4J) GenerateCorrections() ([]string, error) {
 		recs = append(recs, fmt.Sprintf("Change: <code>%s;</code> &nbsp; <i class = \"fas fa-long-arrow-alt-right\"></i> &nbsp; <code>%s;</code>", preTriggerRules[preAgg.Table], aggNew))
 	}
 
-	fmt.Printf("done\n\n")
+	// Several aggregation rules of one table share
+	// their trigger rule, so drop repeated suggestions.
+	seen := make(map[string]bool)
+	uniqueRecs := make([]string, 0, len(recs))
 
-	return recs, nil
+	for _, rec := range recs {
+
+		if !seen[rec] {
+			seen[rec] = true
+			uniqueRecs = append(uniqueRecs, rec)
+		}
+	}
+
+	return uniqueRecs
 }
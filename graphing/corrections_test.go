@@ -0,0 +1,56 @@
+package graphing
+
+import (
+	"strings"
+	"testing"
+
+	fi "github.com/numbleroot/nemo/faultinjectors"
+)
+
+// Functions.
+
+// TestSuggestCorrections checks that aggregation rules of
+// one table yield a single suggestion, in the same order
+// no matter how the maps happen to be iterated.
+func TestSuggestCorrections(t *testing.T) {
+
+	var first []string
+
+	for round := 0; round < 20; round++ {
+
+		preTriggers := make(map[*fi.Rule][]*GoalRulePair)
+		postTriggers := make(map[*fi.Goal][]*fi.Rule)
+
+		for _, id := range []string{"r3", "r1", "r2"} {
+
+			agg := &fi.Rule{ID: id, Table: "pre", Type: "single"}
+			preTriggers[agg] = []*GoalRulePair{{
+				Goal: &fi.Goal{ID: "g" + id, Table: "log", Receiver: "a"},
+				Rule: &fi.Rule{ID: "t" + id, Table: "log", Type: "async"},
+			}}
+		}
+
+		post := &fi.Goal{ID: "p1", Table: "post", Receiver: "a", CondHolds: true}
+		postTriggers[post] = []*fi.Rule{{ID: "q1", Table: "post", Type: "single"}}
+
+		recs := SuggestCorrections(preTriggers, postTriggers)
+
+		changes := 0
+		for _, rec := range recs {
+
+			if strings.HasPrefix(rec, "Change:") {
+				changes++
+			}
+		}
+
+		if changes != 1 {
+			t.Fatalf("Expected 1 suggested change, got %d: %v", changes, recs)
+		}
+
+		if first == nil {
+			first = recs
+		} else if strings.Join(recs, "\n") != strings.Join(first, "\n") {
+			t.Fatalf("Expected suggestions in the same order, got %v and %v", first, recs)
+		}
+	}
+}
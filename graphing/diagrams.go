@@ -12,7 +12,7 @@ import (
 // Functions.
 
 // createDOT
-func createDOT(edges []graph.Path, graphType string) (*gographviz.Graph, error) {
+func createDOT(edges []graph.Path, graphType string, styles *Styles) (*gographviz.Graph, error) {
 
 	dotGraph := gographviz.NewGraph()
 
@@ -28,9 +28,9 @@ func createDOT(edges []graph.Path, graphType string) (*gographviz.Graph, error)
 		return nil, err
 	}
 
-	// Make sure the background is transparent.
+	// Set the background, transparent by default.
 	err = dotGraph.AddNode("dataflow", "graph", map[string]string{
-		"bgcolor": "\"transparent\"",
+		"bgcolor": quote(styles.Background),
 	})
 	if err != nil {
 		return nil, err
@@ -45,64 +45,64 @@ func createDOT(edges []graph.Path, graphType string) (*gographviz.Graph, error)
 
 		fromAttrs["label"] = fmt.Sprintf("\"%s\"", edges[i].Nodes[0].Properties["label"])
 		fromAttrs["style"] = "\"filled, solid\""
-		fromAttrs["color"] = "\"black\""
-		fromAttrs["fontcolor"] = "\"black\""
-		fromAttrs["fillcolor"] = "\"white\""
+		fromAttrs["color"] = quote(styles.Line)
+		fromAttrs["fontcolor"] = quote(styles.Line)
+		fromAttrs["fillcolor"] = quote(styles.Fill)
 
 		// Style node differently based on time notion.
 		if edges[i].Nodes[0].Properties["type"] == "async" {
 			fromAttrs["style"] = "\"filled, bold\""
-			fromAttrs["color"] = "\"lawngreen\""
+			fromAttrs["color"] = quote(styles.Async)
 		} else if edges[i].Nodes[0].Properties["type"] == "next" {
-			fromAttrs["fontcolor"] = "\"gold\""
+			fromAttrs["fontcolor"] = quote(styles.Next)
 		}
 
 		// Style node differently based on achieved condition.
 		if (edges[i].Nodes[0].Properties["condition_holds"] == true) && (graphType == "pre") {
-			fromAttrs["color"] = "\"firebrick\""
-			fromAttrs["fillcolor"] = "\"firebrick\""
+			fromAttrs["color"] = quote(styles.PreHolds)
+			fromAttrs["fillcolor"] = quote(styles.PreHolds)
 		} else if (edges[i].Nodes[0].Properties["condition_holds"] == true) && (graphType == "post") {
-			fromAttrs["color"] = "\"deepskyblue\""
-			fromAttrs["fillcolor"] = "\"deepskyblue\""
+			fromAttrs["color"] = quote(styles.PostHolds)
+			fromAttrs["fillcolor"] = quote(styles.PostHolds)
 		}
 
 		// Alter shape based on being rule or goal.
 		if edges[i].Nodes[0].Labels[0] == "Rule" {
-			fromAttrs["shape"] = "rect"
+			fromAttrs["shape"] = styles.RuleShape
 		} else {
-			fromAttrs["shape"] = "ellipse"
+			fromAttrs["shape"] = styles.GoalShape
 		}
 
 		toAttrs := make(map[string]string)
 
 		toAttrs["label"] = fmt.Sprintf("\"%s\"", edges[i].Nodes[1].Properties["label"])
 		toAttrs["style"] = "\"filled, solid\""
-		toAttrs["color"] = "\"black\""
-		toAttrs["fontcolor"] = "\"black\""
-		toAttrs["fillcolor"] = "\"white\""
+		toAttrs["color"] = quote(styles.Line)
+		toAttrs["fontcolor"] = quote(styles.Line)
+		toAttrs["fillcolor"] = quote(styles.Fill)
 
 		// Style node differently based on time notion.
 		if edges[i].Nodes[1].Properties["type"] == "async" {
 			toAttrs["style"] = "\"filled, bold\""
-			toAttrs["color"] = "\"lawngreen\""
+			toAttrs["color"] = quote(styles.Async)
 		} else if edges[i].Nodes[1].Properties["type"] == "next" {
-			toAttrs["fontcolor"] = "\"gold\""
+			toAttrs["fontcolor"] = quote(styles.Next)
 		}
 
 		// Style node differently based on achieved condition.
 		if (edges[i].Nodes[1].Properties["condition_holds"] == true) && (graphType == "pre") {
-			toAttrs["color"] = "\"firebrick\""
-			toAttrs["fillcolor"] = "\"firebrick\""
+			toAttrs["color"] = quote(styles.PreHolds)
+			toAttrs["fillcolor"] = quote(styles.PreHolds)
 		} else if (edges[i].Nodes[1].Properties["condition_holds"] == true) && (graphType == "post") {
-			toAttrs["color"] = "\"deepskyblue\""
-			toAttrs["fillcolor"] = "\"deepskyblue\""
+			toAttrs["color"] = quote(styles.PostHolds)
+			toAttrs["fillcolor"] = quote(styles.PostHolds)
 		}
 
 		// Alter shape based on being rule or goal.
 		if edges[i].Nodes[1].Labels[0] == "Rule" {
-			toAttrs["shape"] = "rect"
+			toAttrs["shape"] = styles.RuleShape
 		} else {
-			toAttrs["shape"] = "ellipse"
+			toAttrs["shape"] = styles.GoalShape
 		}
 
 		// Add first node with all info from query.
@@ -119,7 +119,7 @@ func createDOT(edges []graph.Path, graphType string) (*gographviz.Graph, error)
 
 		// Add edge to DOT graph.
 		err = dotGraph.AddEdge(from, to, true, map[string]string{
-			"color": "\"black\"",
+			"color": quote(styles.Line),
 		})
 		if err != nil {
 			return nil, err
@@ -129,8 +129,79 @@ func createDOT(edges []graph.Path, graphType string) (*gographviz.Graph, error)
 	return dotGraph, nil
 }
 
+// createProvGraph converts the edges of a provenance
+// graph into a structure suited for serialization.
+func createProvGraph(iteration uint, condition string, variant string, edges []graph.Path) *fi.ProvGraph {
+
+	provGraph := &fi.ProvGraph{
+		Iteration: iteration,
+		Condition: condition,
+		Variant:   variant,
+		Nodes:     make([]*fi.ProvNode, 0, (len(edges) + 1)),
+		Edges:     make([]*fi.Edge, 0, len(edges)),
+	}
+
+	seen := make(map[string]bool)
+
+	for i := range edges {
+
+		for _, node := range edges[i].Nodes[:2] {
+
+			id := node.Properties["id"].(string)
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			// Cleaned-up provenance may lack some
+			// properties, keep their zero values.
+			provNode := &fi.ProvNode{
+				ID:   id,
+				Kind: strings.ToLower(node.Labels[0]),
+			}
+			provNode.Label, _ = node.Properties["label"].(string)
+			provNode.Table, _ = node.Properties["table"].(string)
+			provNode.Type, _ = node.Properties["type"].(string)
+			provNode.Time, _ = node.Properties["time"].(string)
+			provNode.CondHolds, _ = node.Properties["condition_holds"].(bool)
+
+			provGraph.Nodes = append(provGraph.Nodes, provNode)
+		}
+
+		provGraph.Edges = append(provGraph.Edges, &fi.Edge{
+			From: edges[i].Nodes[0].Properties["id"].(string),
+			To:   edges[i].Nodes[1].Properties["id"].(string),
+		})
+	}
+
+	return provGraph
+}
+
+// createDiffProvGraph converts the edges of a differential
+// provenance graph into a structure suited for serialization
+// and flags the events missing from the failed run.
+func createDiffProvGraph(diffRunID uint, diffEdges []graph.Path, missing []*fi.Missing) *fi.ProvGraph {
+
+	missingMap := make(map[string]bool)
+	for m := range missing {
+
+		missingMap[missing[m].Rule.ID] = true
+		for g := range missing[m].Goals {
+			missingMap[missing[m].Goals[g].ID] = true
+		}
+	}
+
+	provGraph := createProvGraph(diffRunID, "post", "diff", diffEdges)
+
+	for _, node := range provGraph.Nodes {
+		node.Missing = missingMap[node.ID]
+	}
+
+	return provGraph
+}
+
 // createDiffDot
-func createDiffDot(diffRunID uint, diffEdges []graph.Path, failedRunID uint, failedEdges []graph.Path, successRunID uint, successPostProv *gographviz.Graph, missing []*fi.Missing) (*gographviz.Graph, *gographviz.Graph, error) {
+func createDiffDot(diffRunID uint, diffEdges []graph.Path, failedRunID uint, failedEdges []graph.Path, successRunID uint, successPostProv *gographviz.Graph, missing []*fi.Missing, styles *Styles) (*gographviz.Graph, *gographviz.Graph, error) {
 
 	// Create map for lookup of missing events.
 	missingMap := make(map[string]bool)
@@ -167,16 +238,16 @@ func createDiffDot(diffRunID uint, diffEdges []graph.Path, failedRunID uint, fai
 		return nil, nil, err
 	}
 
-	// Make sure both backgrounds are transparent.
+	// Set both backgrounds, transparent by default.
 	err = diffDotGraph.AddNode("dataflow", "graph", map[string]string{
-		"bgcolor": "\"transparent\"",
+		"bgcolor": quote(styles.Background),
 	})
 	if err != nil {
 		return nil, nil, err
 	}
 
 	err = failedDotGraph.AddNode("dataflow", "graph", map[string]string{
-		"bgcolor": "\"transparent\"",
+		"bgcolor": quote(styles.Background),
 	})
 	if err != nil {
 		return nil, nil, err
@@ -184,8 +255,8 @@ func createDiffDot(diffRunID uint, diffEdges []graph.Path, failedRunID uint, fai
 
 	for _, edge := range successPostProv.Edges.Edges {
 
-		diffSrc := strings.Replace(edge.Src, fmt.Sprintf("run_%d", successRunID), fmt.Sprintf("run_%d", diffRunID), -1)
-		diffDst := strings.Replace(edge.Dst, fmt.Sprintf("run_%d", successRunID), fmt.Sprintf("run_%d", diffRunID), -1)
+		diffSrc := strings.Replace(edge.Src, fmt.Sprintf("run_%d_", successRunID), fmt.Sprintf("run_%d_", diffRunID), -1)
+		diffDst := strings.Replace(edge.Dst, fmt.Sprintf("run_%d_", successRunID), fmt.Sprintf("run_%d_", diffRunID), -1)
 
 		// Copy attribute map.
 		attrMap := make(map[string]string)
@@ -210,7 +281,7 @@ func createDiffDot(diffRunID uint, diffEdges []graph.Path, failedRunID uint, fai
 
 	for _, node := range successPostProv.Nodes.Nodes {
 
-		diffName := strings.Replace(node.Name, fmt.Sprintf("run_%d", successRunID), fmt.Sprintf("run_%d", diffRunID), -1)
+		diffName := strings.Replace(node.Name, fmt.Sprintf("run_%d_", successRunID), fmt.Sprintf("run_%d_", diffRunID), -1)
 
 		// Copy attribute map.
 		attrMap := make(map[string]string)
@@ -254,13 +325,13 @@ func createDiffDot(diffRunID uint, diffEdges []graph.Path, failedRunID uint, fai
 		_, isMissingFrom := missingMap[from]
 		if isMissingFrom {
 			diffDotGraph.Nodes.Lookup[from].Attrs["style"] = "\"filled, dashed, bold\""
-			diffDotGraph.Nodes.Lookup[from].Attrs["color"] = "\"mediumvioletred\""
+			diffDotGraph.Nodes.Lookup[from].Attrs["color"] = quote(styles.Missing)
 		}
 
 		_, isMissingTo := missingMap[to]
 		if isMissingTo {
 			diffDotGraph.Nodes.Lookup[to].Attrs["style"] = "\"filled, dashed, bold\""
-			diffDotGraph.Nodes.Lookup[to].Attrs["color"] = "\"mediumvioletred\""
+			diffDotGraph.Nodes.Lookup[to].Attrs["color"] = quote(styles.Missing)
 		}
 	}
 
@@ -289,3 +360,93 @@ func createDiffDot(diffRunID uint, diffEdges []graph.Path, failedRunID uint, fai
 
 	return diffDotGraph, failedDotGraph, err
 }
+
+// createSpaceTimeDOT derives a space-time diagram from
+// the messages of a run. We use it for fault injectors
+// that do not supply space-time diagrams themselves.
+func createSpaceTimeDOT(run *fi.Run, styles *Styles) (*gographviz.Graph, error) {
+
+	dotGraph := gographviz.NewGraph()
+
+	err := dotGraph.SetName("spacetime")
+	if err != nil {
+		return nil, err
+	}
+
+	err = dotGraph.SetDir(true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect all nodes and the latest timestep.
+	nodes := make([]string, 0, 4)
+	seenNodes := make(map[string]bool)
+	var eot uint = 1
+
+	if (run.FailureSpec != nil) && (run.FailureSpec.Nodes != nil) {
+
+		eot = run.FailureSpec.EOT
+
+		for _, node := range *run.FailureSpec.Nodes {
+			seenNodes[node] = true
+			nodes = append(nodes, node)
+		}
+	}
+
+	for _, msg := range run.Messages {
+
+		for _, node := range []string{msg.SendNode, msg.RecvNode} {
+
+			if !seenNodes[node] {
+				seenNodes[node] = true
+				nodes = append(nodes, node)
+			}
+		}
+
+		if msg.RecvTime > eot {
+			eot = msg.RecvTime
+		}
+	}
+
+	// Node names end in the timestep they represent,
+	// just like in the diagrams created by Molly.
+	nodeName := func(node string, time uint) string {
+		return fmt.Sprintf("\"%s_%d\"", node, time)
+	}
+
+	for _, node := range nodes {
+
+		for t := uint(1); t <= eot; t++ {
+
+			err := dotGraph.AddNode("spacetime", nodeName(node, t), map[string]string{
+				"label": fmt.Sprintf("\"%s @ %d\"", node, t),
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			// Connect consecutive timesteps of each node.
+			if t > 1 {
+
+				err := dotGraph.AddEdge(nodeName(node, (t-1)), nodeName(node, t), true, map[string]string{
+					"color": quote(styles.Inactive),
+				})
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	for _, msg := range run.Messages {
+
+		err := dotGraph.AddEdge(nodeName(msg.SendNode, msg.SendTime), nodeName(msg.RecvNode, msg.RecvTime), true, map[string]string{
+			"label": fmt.Sprintf("\"%s\"", msg.Content),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return dotGraph, nil
+}
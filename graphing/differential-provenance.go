@@ -15,7 +15,7 @@ import (
 // Functions.
 
 // CreateNaiveDiffProv
-func (n *Neo4J) CreateNaiveDiffProv(symmetric bool, failedRuns []uint, successPostProv *gographviz.Graph) ([]*gographviz.Graph, []*gographviz.Graph, [][]*fi.Missing, error) {
+func (n *Neo4J) CreateNaiveDiffProv(symmetric bool, failedRuns []uint, successPostProv *gographviz.Graph) ([]*gographviz.Graph, []*gographviz.Graph, []*fi.ProvGraph, [][]*fi.Missing, error) {
 
 	fmt.Printf("Creating differential provenance (good - bad), naive way... ")
 
@@ -23,7 +23,7 @@ func (n *Neo4J) CreateNaiveDiffProv(symmetric bool, failedRuns []uint, successPo
 		MATCH (failed:Goal {run: ###RUN###, condition: 'post'})
 		WITH collect(failed.label) AS failGoals
 
-		MATCH pathSucc = (root:Goal {run: 0, condition: 'post'})-[*0..]->(goal:Goal {run: 0, condition: 'post'})
+		MATCH pathSucc = (root:Goal {run: ###GOOD###, condition: 'post'})-[*0..]->(goal:Goal {run: ###GOOD###, condition: 'post'})
 		WHERE NOT root.label IN failGoals AND NOT goal.label IN failGoals
 		RETURN pathSucc;
 		", "/tmp/export-differential-provenance", {format: "cypher-shell", cypherFormat: "create"})
@@ -33,41 +33,43 @@ func (n *Neo4J) CreateNaiveDiffProv(symmetric bool, failedRuns []uint, successPo
 
 	diffDots := make([]*gographviz.Graph, len(failedRuns))
 	failedDots := make([]*gographviz.Graph, len(failedRuns))
+	diffGraphs := make([]*fi.ProvGraph, len(failedRuns))
 	missingEvents := make([][]*fi.Missing, len(failedRuns))
 
 	for i := range failedRuns {
 
 		diffRunID := 2000 + failedRuns[i]
 
-		// Replace failed run in skeleton query.
-		exportQuery = strings.Replace(exportQuery, "###RUN###", fmt.Sprintf("%d", failedRuns[i]), -1)
-		_, err := n.Conn1.ExecNeo(exportQuery, nil)
+		// Replace failed and good run in skeleton query.
+		runQuery := strings.Replace(exportQuery, "###RUN###", fmt.Sprintf("%d", failedRuns[i]), -1)
+		runQuery = strings.Replace(runQuery, "###GOOD###", fmt.Sprintf("%d", n.GoodRun), -1)
+		_, err := n.Conn1.ExecNeo(runQuery, nil)
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
 		}
 
 		// Replace run ID part of node ID in saved queries.
-		sedIDLong := fmt.Sprintf("s/`id`:\"run_0/`id`:\"run_%d/g", diffRunID)
+		sedIDLong := fmt.Sprintf("s/`id`:\"run_%d_/`id`:\"run_%d_/g", n.GoodRun, diffRunID)
 		cmd := exec.Command("sudo", "docker", "exec", "graphdb", "sed", "-i", sedIDLong, "/tmp/export-differential-provenance")
 		out, err := cmd.CombinedOutput()
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
 		}
 
 		if strings.TrimSpace(string(out)) != "" {
-			return nil, nil, nil, fmt.Errorf("Wrong return value from docker-compose exec sed diffprov run ID command: %s", out)
+			return nil, nil, nil, nil, fmt.Errorf("Wrong return value from docker-compose exec sed diffprov run ID command: %s", out)
 		}
 
 		// Replace run ID in saved queries.
-		sedIDShort := fmt.Sprintf("s/`run`:0/`run`:%d/g", diffRunID)
+		sedIDShort := fmt.Sprintf("s/`run`:%d\\b/`run`:%d/g", n.GoodRun, diffRunID)
 		cmd = exec.Command("sudo", "docker", "exec", "graphdb", "sed", "-i", sedIDShort, "/tmp/export-differential-provenance")
 		out, err = cmd.CombinedOutput()
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
 		}
 
 		if strings.TrimSpace(string(out)) != "" {
-			return nil, nil, nil, fmt.Errorf("Wrong return value from docker-compose exec sed diffprov run ID command: %s", out)
+			return nil, nil, nil, nil, fmt.Errorf("Wrong return value from docker-compose exec sed diffprov run ID command: %s", out)
 		}
 
 		// Import modified difference graph as new one.
@@ -75,7 +77,7 @@ func (n *Neo4J) CreateNaiveDiffProv(symmetric bool, failedRuns []uint, successPo
 			CALL apoc.cypher.runFile("/tmp/export-differential-provenance", {statistics: false});
 		`, nil)
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
 		}
 
 		// Query differential provenance graph for leaves.
@@ -97,19 +99,19 @@ func (n *Neo4J) CreateNaiveDiffProv(symmetric bool, failedRuns []uint, successPo
 			RETURN rule, leaves;
 		`)
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
 		}
 
 		leavesRaw, err := stmtLeaves.QueryNeo(map[string]interface{}{
 			"run": diffRunID,
 		})
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
 		}
 
 		leavesAll, _, err := leavesRaw.All()
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
 		}
 
 		missing := make([]*fi.Missing, len(leavesAll))
@@ -147,12 +149,12 @@ func (n *Neo4J) CreateNaiveDiffProv(symmetric bool, failedRuns []uint, successPo
 
 		err = leavesRaw.Close()
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
 		}
 
 		err = stmtLeaves.Close()
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
 		}
 
 		// Query for imported differential provenance.
@@ -161,14 +163,14 @@ func (n *Neo4J) CreateNaiveDiffProv(symmetric bool, failedRuns []uint, successPo
 			RETURN path;
 		`)
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
 		}
 
 		edgesRaw, err := stmtProv.QueryNeo(map[string]interface{}{
 			"run": diffRunID,
 		})
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
 		}
 
 		diffEdges := make([]graph.Path, 0, 10)
@@ -179,7 +181,7 @@ func (n *Neo4J) CreateNaiveDiffProv(symmetric bool, failedRuns []uint, successPo
 
 			edgeRaw, _, err = edgesRaw.NextNeo()
 			if err != nil && err != io.EOF {
-				return nil, nil, nil, err
+				return nil, nil, nil, nil, err
 			} else if err == nil {
 
 				// Type-assert raw edge into well-defined struct.
@@ -192,14 +194,14 @@ func (n *Neo4J) CreateNaiveDiffProv(symmetric bool, failedRuns []uint, successPo
 
 		err = edgesRaw.Close()
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
 		}
 
 		edgesRaw, err = stmtProv.QueryNeo(map[string]interface{}{
 			"run": failedRuns[i],
 		})
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
 		}
 
 		failedEdges := make([]graph.Path, 0, 10)
@@ -210,7 +212,7 @@ func (n *Neo4J) CreateNaiveDiffProv(symmetric bool, failedRuns []uint, successPo
 
 			edgeRaw, _, err = edgesRaw.NextNeo()
 			if err != nil && err != io.EOF {
-				return nil, nil, nil, err
+				return nil, nil, nil, nil, err
 			} else if err == nil {
 
 				// Type-assert raw edge into well-defined struct.
@@ -222,22 +224,23 @@ func (n *Neo4J) CreateNaiveDiffProv(symmetric bool, failedRuns []uint, successPo
 		}
 
 		// Pass to DOT string generator.
-		diffDot, failedDot, err := createDiffDot(diffRunID, diffEdges, failedRuns[i], failedEdges, 0, successPostProv, missing)
+		diffDot, failedDot, err := createDiffDot(diffRunID, diffEdges, failedRuns[i], failedEdges, n.GoodRun, successPostProv, missing, n.styles())
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
 		}
 
 		err = stmtProv.Close()
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
 		}
 
 		diffDots[i] = diffDot
 		failedDots[i] = failedDot
+		diffGraphs[i] = createDiffProvGraph(diffRunID, diffEdges, missing)
 		missingEvents[i] = missing
 	}
 
 	fmt.Printf("done\n\n")
 
-	return diffDots, failedDots, missingEvents, nil
+	return diffDots, failedDots, diffGraphs, missingEvents, nil
 }
@@ -3,12 +3,38 @@ package graphing
 import (
 	"fmt"
 	"io"
+	"sort"
 
 	graph "github.com/johnnadratowski/golang-neo4j-bolt-driver/structures/graph"
 )
 
 // Functions.
 
+// SuggestExtensions asks to double-check the fault
+// tolerance of each of the network events in tables,
+// once per table and in order of tables.
+func SuggestExtensions(tables []string) []string {
+
+	// Add extensions only once per rule.
+	seen := make(map[string]bool)
+	for _, table := range tables {
+		seen[table] = true
+	}
+
+	rules := make([]string, 0, len(seen))
+	for table := range seen {
+		rules = append(rules, table)
+	}
+	sort.Strings(rules)
+
+	extensions := make([]string, len(rules))
+	for i := range rules {
+		extensions[i] = fmt.Sprintf("<code>%s(node, ...)@async :- ...;</code>", rules[i])
+	}
+
+	return extensions
+}
+
 // GenerateExtensions
 func (n *Neo4J) GenerateExtensions() (bool, []string, error) {
 
@@ -18,15 +44,14 @@ func (n *Neo4J) GenerateExtensions() (bool, []string, error) {
 	// Prepare slice of extensions.
 	extensions := make([]string, 0, 3)
 
-	// Prepare map for adding extensions only once per rule.
-	rulesState := make(map[string]string)
-
 	// Query for antecedent achievement per run.
 	preAchievedRows, err := n.Conn1.QueryNeo(`
-		MATCH (pre:Goal {condition: "pre", table: "pre", condition_holds: true})
+		MATCH (pre:Goal {condition: "pre", table: {table}, condition_holds: true})
 		WHERE pre.run < 1000
 		RETURN collect(pre) AS pres;
-	`, nil)
+	`, map[string]interface{}{
+		"table": n.condTable("pre"),
+	})
 	if err != nil {
 		return false, nil, err
 	}
@@ -57,14 +82,16 @@ func (n *Neo4J) GenerateExtensions() (bool, []string, error) {
 	if !allAchievedPre {
 
 		// In case not all runs achieved the antecedent,
-		// we query the successful (first) run and collect
+		// we query the successful (good) run and collect
 		// all network events.
 
 		asyncEventsRows, err := n.Conn1.QueryNeo(`
-			MATCH (r:Rule {run: 0, condition: "pre", type: "async"})
-			WHERE (:Goal {run: 0, condition: "pre", condition_holds: true})-[*1]->(r)-[*1]->(:Goal {run: 0, condition: "pre", condition_holds: false})-[*1]->(:Rule {run: 0, condition: "pre"}) OR (:Goal {run: 0, condition: "pre", condition_holds: false})-[*1]->(r)
+			MATCH (r:Rule {run: {run}, condition: "pre", type: "async"})
+			WHERE (:Goal {run: {run}, condition: "pre", condition_holds: true})-[*1]->(r)-[*1]->(:Goal {run: {run}, condition: "pre", condition_holds: false})-[*1]->(:Rule {run: {run}, condition: "pre"}) OR (:Goal {run: {run}, condition: "pre", condition_holds: false})-[*1]->(r)
 			RETURN r;
-		`, nil)
+		`, map[string]interface{}{
+			"run": n.GoodRun,
+		})
 		if err != nil {
 			return false, nil, err
 		}
@@ -74,20 +101,13 @@ func (n *Neo4J) GenerateExtensions() (bool, []string, error) {
 			return false, nil, err
 		}
 
+		asyncTables := make([]string, len(asyncEventsRaw))
 		for i := range asyncEventsRaw {
-
 			rule := asyncEventsRaw[i][0].(graph.Node)
-
-			// Add rule to extension suggestions only
-			// in case we did not already do so.
-			rulesState[rule.Properties["table"].(string)] = fmt.Sprintf("<code>%s(node, ...)@async :- ...;</code>", rule.Properties["table"].(string))
+			asyncTables[i] = rule.Properties["table"].(string)
 		}
 
-		for rule := range rulesState {
-
-			// Append an extension suggestion to the final slice.
-			extensions = append(extensions, rulesState[rule])
-		}
+		extensions = SuggestExtensions(asyncTables)
 
 		err = asyncEventsRows.Close()
 		if err != nil {
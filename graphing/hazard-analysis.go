@@ -1,11 +1,11 @@
 package graphing
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
-	"io/ioutil"
-	"path/filepath"
+	"io/fs"
 
 	"github.com/awalterschulze/gographviz"
 )
@@ -13,39 +13,51 @@ import (
 // Functions.
 
 // CreateHazardAnalysis
-func (n *Neo4J) CreateHazardAnalysis(faultInjOut string) ([]*gographviz.Graph, error) {
+func (n *Neo4J) CreateHazardAnalysis(faultInjOut fs.FS) ([]*gographviz.Graph, error) {
 
 	fmt.Printf("Running hazard window analysis... ")
 
+	styles := n.styles()
 	dots := make([]*gographviz.Graph, len(n.Runs))
 
 	for i := range n.Runs {
 
-		// Space-time file name in fault injector directory.
-		fiSpaceTime := filepath.Join(faultInjOut, fmt.Sprintf("run_%d_spacetime.dot", n.Runs[i].Iteration))
+		// Space-time file name in fault injector output.
+		fiSpaceTime := fmt.Sprintf("run_%d_spacetime.dot", n.Runs[i].Iteration)
+
+		var spaceTimeGraph *gographviz.Graph
 
 		// Load current space-time diagram.
-		spaceTimeDotBytes, err := ioutil.ReadFile(fiSpaceTime)
-		if err != nil {
+		spaceTimeDotBytes, err := fs.ReadFile(faultInjOut, fiSpaceTime)
+		if errors.Is(err, fs.ErrNotExist) {
+
+			// Not all fault injectors supply space-time
+			// diagrams. Derive one from the messages.
+			spaceTimeGraph, err = createSpaceTimeDOT(n.Runs[i], n.styles())
+			if err != nil {
+				return nil, err
+			}
+		} else if err != nil {
 			return nil, err
-		}
+		} else {
 
-		// Read DOT data.
-		spaceTimeGraph, err := gographviz.Read(spaceTimeDotBytes)
-		if err != nil {
-			return nil, err
+			// Read DOT data.
+			spaceTimeGraph, err = gographviz.Read(spaceTimeDotBytes)
+			if err != nil {
+				return nil, err
+			}
 		}
 
 		for j := range spaceTimeGraph.Nodes.Nodes {
 
 			spaceTimeGraph.Nodes.Nodes[j].Attrs.Extend(map[gographviz.Attr]string{
 				"style":     "\"solid, filled\"",
-				"color":     "\"lightgrey\"",
-				"fillcolor": "\"lightgrey\"",
+				"color":     quote(styles.Inactive),
+				"fillcolor": quote(styles.Inactive),
 			})
 
 			// Split into naming and time parts.
-			nameParts := strings.Split(spaceTimeGraph.Nodes.Nodes[j].Name, "_")
+			nameParts := strings.Split(strings.Trim(spaceTimeGraph.Nodes.Nodes[j].Name, "\""), "_")
 
 			// Possibly selecting the time of the node here.
 			// If this is not actually the time, it does not
@@ -61,8 +73,8 @@ func (n *Neo4J) CreateHazardAnalysis(faultInjOut string) ([]*gographviz.Graph, e
 			if preHolds {
 
 				spaceTimeGraph.Nodes.Nodes[j].Attrs.Extend(map[gographviz.Attr]string{
-					"color":     "\"firebrick\"",
-					"fillcolor": "\"firebrick\"",
+					"color":     quote(styles.PreHolds),
+					"fillcolor": quote(styles.PreHolds),
 				})
 			}
 
@@ -74,7 +86,7 @@ func (n *Neo4J) CreateHazardAnalysis(faultInjOut string) ([]*gographviz.Graph, e
 			if postHolds {
 
 				spaceTimeGraph.Nodes.Nodes[j].Attrs.Extend(map[gographviz.Attr]string{
-					"fillcolor": "\"deepskyblue\"",
+					"fillcolor": quote(styles.PostHolds),
 				})
 			}
 		}
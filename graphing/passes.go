@@ -0,0 +1,402 @@
+package graphing
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Structs.
+
+// Pass is a named simplification of the clean copy of
+// the provenance of one run and condition. Applying it
+// returns how many changes it made to the graph.
+type Pass struct {
+	Name        string
+	Description string
+	apply       func(n *Neo4J, iter uint, condition string) (int, error)
+}
+
+// allPasses lists all available passes.
+var allPasses = []*Pass{
+	{
+		Name:        "collapse-next",
+		Description: "Collapse @next persistence chains into one rule each.",
+		apply: func(n *Neo4J, iter uint, condition string) (int, error) {
+			return n.collapseChains(iter, condition, "next")
+		},
+	},
+	{
+		Name:        "drop-clock",
+		Description: "Drop clock goals.",
+		apply: func(n *Neo4J, iter uint, condition string) (int, error) {
+			return n.dropLeafGoals(iter, condition, "clock")
+		},
+	},
+	{
+		Name:        "hide-network",
+		Description: "Hide network facts, such as network(...).",
+		apply: func(n *Neo4J, iter uint, condition string) (int, error) {
+			return n.dropLeafGoals(iter, condition, "network")
+		},
+	},
+	{
+		Name:        "merge-siblings",
+		Description: "Merge identical sibling subtrees into one.",
+		apply: func(n *Neo4J, iter uint, condition string) (int, error) {
+			return n.mergeSiblings(iter, condition)
+		},
+	},
+	{
+		Name:        "collapse-tables",
+		Description: "Collapse recursive derivations within one table into one rule each.",
+		apply: func(n *Neo4J, iter uint, condition string) (int, error) {
+			return n.collapseChains(iter, condition, "table")
+		},
+	},
+}
+
+// DefaultPasses are applied unless passes are selected.
+var DefaultPasses = []string{"collapse-next"}
+
+// Functions.
+
+// AvailablePasses returns all passes Nemo provides.
+func AvailablePasses() []*Pass {
+	return allPasses
+}
+
+// lookupPass returns the pass called name, if any.
+func lookupPass(name string) *Pass {
+
+	for _, pass := range allPasses {
+
+		if pass.Name == name {
+			return pass
+		}
+	}
+
+	return nil
+}
+
+// ParsePasses returns the passes in the comma-separated
+// list raw, in the order they are listed. The empty list
+// and 'none' select no pass at all.
+func ParsePasses(raw string) ([]string, error) {
+
+	names := make([]string, 0, len(allPasses))
+	seen := make(map[string]bool)
+
+	for _, name := range strings.Split(raw, ",") {
+
+		name = strings.TrimSpace(name)
+		if (name == "") || (name == "none") {
+			continue
+		}
+
+		if lookupPass(name) == nil {
+
+			available := make([]string, len(allPasses))
+			for i, pass := range allPasses {
+				available[i] = pass.Name
+			}
+
+			return nil, fmt.Errorf("Unknown pass '%s', choose from: %s", name, strings.Join(available, ", "))
+		}
+
+		if seen[name] {
+			return nil, fmt.Errorf("Pass '%s' is selected more than once", name)
+		}
+		seen[name] = true
+
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// passes returns the passes selected in n or,
+// if none are, the default ones.
+func (n *Neo4J) passes() ([]*Pass, error) {
+
+	names := n.Passes
+	if names == nil {
+		names = DefaultPasses
+	}
+
+	passes := make([]*Pass, 0, len(names))
+	for _, name := range names {
+
+		pass := lookupPass(name)
+		if pass == nil {
+			return nil, fmt.Errorf("Unknown pass '%s'", name)
+		}
+		passes = append(passes, pass)
+	}
+
+	return passes, nil
+}
+
+// queryCount runs query returning a single count.
+func (n *Neo4J) queryCount(query string, params map[string]interface{}) (int, error) {
+
+	rows, err := n.Conn1.QueryNeo(query, params)
+	if err != nil {
+		return 0, err
+	}
+
+	all, _, err := rows.All()
+	if err != nil {
+		return 0, err
+	}
+
+	err = rows.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	if (len(all) == 0) || (len(all[0]) == 0) {
+		return 0, nil
+	}
+
+	count, ok := all[0][0].(int64)
+	if !ok {
+		return 0, fmt.Errorf("Unexpected count %v", all[0][0])
+	}
+
+	return int(count), nil
+}
+
+// dropLeafGoals removes all goals of table that are base
+// facts, i.e., derived by no rule, from the clean copy
+// of the provenance of run iter.
+func (n *Neo4J) dropLeafGoals(iter uint, condition string, table string) (int, error) {
+
+	return n.queryCount(`
+		MATCH (g:Goal {run: {run}, condition: {condition}, table: {table}})
+		WHERE NOT (g)-[:DUETO]->()
+		WITH collect(g) AS goals
+		FOREACH (g IN goals | DETACH DELETE g)
+		RETURN size(goals);
+	`, map[string]interface{}{
+		"run":       (1000 + iter),
+		"condition": condition,
+		"table":     table,
+	})
+}
+
+// mergeIdentical returns the children of each of the
+// nodes ids left after keeping only the first of each
+// set of identical sibling subtrees, and how many were
+// dropped. Subtrees are identical if their nodes are
+// of the same kinds all the way down.
+func mergeIdentical(ids []int64, kinds map[int64]string, children map[int64][]int64) (map[int64][]int64, int) {
+
+	// Shapes of subtrees, computed bottom-up.
+	shapes := make(map[int64]string, len(ids))
+	inProgress := make(map[int64]bool)
+
+	var shape func(id int64) string
+	shape = func(id int64) string {
+
+		if s, found := shapes[id]; found {
+			return s
+		}
+
+		// Guard against cycles.
+		if inProgress[id] {
+			return kinds[id]
+		}
+		inProgress[id] = true
+
+		childShapes := make([]string, 0, len(children[id]))
+		for _, child := range children[id] {
+			childShapes = append(childShapes, shape(child))
+		}
+		sort.Strings(childShapes)
+
+		shapes[id] = fmt.Sprintf("%s(%s)", kinds[id], strings.Join(childShapes, ","))
+		inProgress[id] = false
+
+		return shapes[id]
+	}
+
+	// Drop edges to all but the first sibling of each shape.
+	merged := 0
+	kept := make(map[int64][]int64, len(children))
+	for _, parent := range ids {
+
+		seen := make(map[string]bool)
+		for _, child := range children[parent] {
+
+			if seen[shape(child)] {
+				merged++
+				continue
+			}
+
+			seen[shape(child)] = true
+			kept[parent] = append(kept[parent], child)
+		}
+	}
+
+	return kept, merged
+}
+
+// mergeSiblings keeps only one of the children of a node
+// whose subtrees are identical, i.e., hold the same facts
+// and rules all the way down. Nodes no longer reachable
+// from any root are removed.
+func (n *Neo4J) mergeSiblings(iter uint, condition string) (int, error) {
+
+	params := map[string]interface{}{
+		"run":       (1000 + iter),
+		"condition": condition,
+	}
+
+	nodesRaw, err := n.Conn1.QueryNeo(`
+		MATCH (x {run: {run}, condition: {condition}})
+		RETURN ID(x), labels(x)[0], x.table, x.type, x.label;
+	`, params)
+	if err != nil {
+		return 0, err
+	}
+
+	nodesAll, _, err := nodesRaw.All()
+	if err != nil {
+		return 0, err
+	}
+
+	err = nodesRaw.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	edgesRaw, err := n.Conn1.QueryNeo(`
+		MATCH (a {run: {run}, condition: {condition}})-[:DUETO]->(b {run: {run}, condition: {condition}})
+		RETURN ID(a), ID(b);
+	`, params)
+	if err != nil {
+		return 0, err
+	}
+
+	edgesAll, _, err := edgesRaw.All()
+	if err != nil {
+		return 0, err
+	}
+
+	err = edgesRaw.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	// Describe each node by its kind, table, type, and
+	// label, which holds the arguments of goals.
+	kinds := make(map[int64]string, len(nodesAll))
+	ids := make([]int64, 0, len(nodesAll))
+	for _, node := range nodesAll {
+
+		id := node[0].(int64)
+		kinds[id] = fmt.Sprintf("%v|%v|%v|%v", node[1], node[2], node[3], node[4])
+		ids = append(ids, id)
+	}
+
+	children := make(map[int64][]int64)
+	hasParent := make(map[int64]bool)
+	for _, edge := range edgesAll {
+
+		from, to := edge[0].(int64), edge[1].(int64)
+		children[from] = append(children[from], to)
+		hasParent[to] = true
+	}
+
+	kept, merged := mergeIdentical(ids, kinds, children)
+
+	if merged == 0 {
+		return 0, nil
+	}
+
+	// Find all nodes still reachable from roots.
+	reachable := make(map[int64]bool, len(ids))
+	queue := make([]int64, 0, len(ids))
+	for _, id := range ids {
+
+		if !hasParent[id] {
+			reachable[id] = true
+			queue = append(queue, id)
+		}
+	}
+
+	for len(queue) > 0 {
+
+		id := queue[0]
+		queue = queue[1:]
+
+		for _, child := range kept[id] {
+
+			if !reachable[child] {
+				reachable[child] = true
+				queue = append(queue, child)
+			}
+		}
+	}
+
+	// Remove dropped edges, then unreachable nodes.
+	stmtDelEdge, err := n.Conn1.PrepareNeo(`
+		MATCH (a)-[e:DUETO]->(b)
+		WHERE ID(a) = {from} AND ID(b) = {to}
+		DELETE e;
+	`)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, parent := range ids {
+
+		keep := make(map[int64]bool, len(kept[parent]))
+		for _, child := range kept[parent] {
+			keep[child] = true
+		}
+
+		for _, child := range children[parent] {
+
+			if keep[child] || !reachable[parent] {
+				continue
+			}
+
+			_, err := stmtDelEdge.ExecNeo(map[string]interface{}{
+				"from": parent,
+				"to":   child,
+			})
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	err = stmtDelEdge.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	unreachable := make([]string, 0, len(ids))
+	for _, id := range ids {
+
+		if !reachable[id] {
+			unreachable = append(unreachable, fmt.Sprintf("%d", id))
+		}
+	}
+
+	if len(unreachable) > 0 {
+
+		_, err = n.Conn1.ExecNeo(fmt.Sprintf(`
+			MATCH (x)
+			WHERE ID(x) IN [%s]
+			DETACH DELETE x;
+		`, strings.Join(unreachable, ", ")), nil)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return merged, nil
+}
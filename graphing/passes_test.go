@@ -0,0 +1,53 @@
+package graphing
+
+import (
+	"testing"
+)
+
+// Functions.
+
+// TestMergeIdentical checks that only sibling subtrees
+// holding the same facts all the way down are merged.
+func TestMergeIdentical(t *testing.T) {
+
+	// Goal post(foo) is due to a rule with four subtrees:
+	// log(a, 1) twice, log(b, 2), and log(a, 1) derived
+	// from another fact.
+	kinds := map[int64]string{
+		1: "Goal|post||post(foo)",
+		2: "Rule|post|single|post",
+		3: "Goal|log||log(a, 1)",
+		4: "Goal|log||log(a, 1)",
+		5: "Goal|log||log(b, 2)",
+		6: "Goal|log||log(a, 1)",
+		7: "Rule|log|async|log",
+		8: "Goal|begin||begin(a)",
+	}
+
+	children := map[int64][]int64{
+		1: {2},
+		2: {3, 4, 5, 6},
+		6: {7},
+		7: {8},
+	}
+
+	ids := []int64{1, 2, 3, 4, 5, 6, 7, 8}
+
+	kept, merged := mergeIdentical(ids, kinds, children)
+
+	if merged != 1 {
+		t.Errorf("Expected 1 merged subtree, got %d", merged)
+	}
+
+	want := []int64{3, 5, 6}
+	if len(kept[2]) != len(want) {
+		t.Fatalf("Expected children %v, got %v", want, kept[2])
+	}
+
+	for i := range want {
+
+		if kept[2][i] != want[i] {
+			t.Errorf("Expected children %v, got %v", want, kept[2])
+		}
+	}
+}
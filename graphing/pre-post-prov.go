@@ -14,9 +14,14 @@ import (
 
 // Neo4J
 type Neo4J struct {
-	Conn1 neo4j.Conn
-	Conn2 neo4j.Conn
-	Runs  []*fi.Run
+	Conn1     neo4j.Conn
+	Conn2     neo4j.Conn
+	Runs      []*fi.Run
+	GoodRun   uint
+	PreTable  string
+	PostTable string
+	Passes    []string
+	Styles    *Styles
 }
 
 // Functions.
@@ -219,17 +224,18 @@ func (n *Neo4J) markConditionHolds(iteration uint, provCond string) error {
 
 	stmtMarkCond, err := n.Conn1.PrepareNeo(`
 		MATCH (g:Goal {run: {run}, condition: {condition}})-[*1]->(r:Rule {run: {run}, condition: {condition}})
-		WHERE (:Goal {run: {run}, condition: {condition}, table: {condition}})-[*1]->(:Rule {run: {run}, condition: {condition}, table: {condition}})-[*1]->(g) AND NOT ()-->(:Goal {run: {run}, condition: {condition}, table: {condition}})-[*1]->(:Rule {run: {run}, condition: {condition}, table: {condition}})-[*1]->(g)
+		WHERE (:Goal {run: {run}, condition: {condition}, table: {table}})-[*1]->(:Rule {run: {run}, condition: {condition}, table: {table}})-[*1]->(g) AND NOT ()-->(:Goal {run: {run}, condition: {condition}, table: {table}})-[*1]->(:Rule {run: {run}, condition: {condition}, table: {table}})-[*1]->(g)
 		WITH g.table AS rule
 
 		MATCH (n:Goal {run: {run}, condition: {condition}})
-		WHERE n.table = {condition} OR n.table = rule
+		WHERE n.table = {table} OR n.table = rule
 		SET n.condition_holds = true
 	`)
 
 	_, err = stmtMarkCond.ExecNeo(map[string]interface{}{
 		"run":       iteration,
 		"condition": provCond,
+		"table":     n.condTable(provCond),
 	})
 	if err != nil {
 		return err
@@ -243,13 +249,36 @@ func (n *Neo4J) markConditionHolds(iteration uint, provCond string) error {
 	return nil
 }
 
-// LoadRawProvenance
-func (n *Neo4J) LoadRawProvenance() error {
+// LoadRawProvenance imports the provenance of the
+// runs with the specified iterations, in run order.
+func (n *Neo4J) LoadRawProvenance(iters []uint) error {
 
 	fmt.Printf("Loading raw provenance data...\n")
 
+	// Remove provenance loaded earlier, e.g., for a
+	// different invariant of the protocol. Only nodes
+	// of Nemo carry a run and a condition, all other
+	// data in the database is left untouched.
+	_, err := n.Conn1.ExecNeo(`
+		MATCH (n)
+		WHERE exists(n.run) AND exists(n.condition)
+		DETACH DELETE n;
+	`, nil)
+	if err != nil {
+		return err
+	}
+
+	load := make(map[uint]bool, len(iters))
+	for _, iter := range iters {
+		load[iter] = true
+	}
+
 	for i := range n.Runs {
 
+		if !load[n.Runs[i].Iteration] {
+			continue
+		}
+
 		// Load antecedent provenance.
 		fmt.Printf("\t[%d] Antecedent provenance... ", n.Runs[i].Iteration)
 		err := n.loadProv(n.Runs[i].Iteration, "pre", n.Runs[i].PreProv)
@@ -284,6 +313,52 @@ func (n *Neo4J) LoadRawProvenance() error {
 	return nil
 }
 
+// pullProvEdges queries all edges of the provenance
+// graph of one condition in the supplied run.
+func pullProvEdges(stmtProv neo4j.Stmt, run uint, condition string) ([]graph.Path, error) {
+
+	edges := make([]graph.Path, 0, 20)
+
+	edgesRaw, err := stmtProv.QueryNeo(map[string]interface{}{
+		"run":       run,
+		"condition": condition,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	edgesRows, _, err := edgesRaw.All()
+	if err != nil {
+		return nil, err
+	}
+
+	for p := range edgesRows {
+
+		// Type-assert raw edge into well-defined struct.
+		edge := edgesRows[p][0].(graph.Path)
+
+		// Append to slice of edges.
+		edges = append(edges, edge)
+	}
+
+	err = edgesRaw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return edges, nil
+}
+
+// prepareProvStmt prepares the query for all
+// edges of one provenance graph.
+func (n *Neo4J) prepareProvStmt() (neo4j.Stmt, error) {
+
+	return n.Conn1.PrepareNeo(`
+		MATCH path = ({run: {run}, condition: {condition}})-[:DUETO*1]->({run: {run}, condition: {condition}})
+		RETURN path;
+	`)
+}
+
 // PullPrePostProv
 func (n *Neo4J) PullPrePostProv() ([]*gographviz.Graph, []*gographviz.Graph, []*gographviz.Graph, []*gographviz.Graph, error) {
 
@@ -295,157 +370,118 @@ func (n *Neo4J) PullPrePostProv() ([]*gographviz.Graph, []*gographviz.Graph, []*
 	postCleanDots := make([]*gographviz.Graph, len(n.Runs))
 
 	// Query for imported correctness condition provenance.
-	stmtProv, err := n.Conn1.PrepareNeo(`
-		MATCH path = ({run: {run}, condition: {condition}})-[:DUETO*1]->({run: {run}, condition: {condition}})
-		RETURN path;
-	`)
+	stmtProv, err := n.prepareProvStmt()
 	if err != nil {
 		return nil, nil, nil, nil, err
 	}
 
 	for i := range n.Runs {
 
-		preEdges := make([]graph.Path, 0, 20)
-		postEdges := make([]graph.Path, 0, 20)
-		preCleanEdges := make([]graph.Path, 0, 20)
-		postCleanEdges := make([]graph.Path, 0, 20)
-
-		preEdgesRaw, err := stmtProv.QueryNeo(map[string]interface{}{
-			"run":       n.Runs[i].Iteration,
-			"condition": "pre",
-		})
-		if err != nil {
-			return nil, nil, nil, nil, err
-		}
-
-		preEdgesRows, _, err := preEdgesRaw.All()
+		preEdges, err := pullProvEdges(stmtProv, n.Runs[i].Iteration, "pre")
 		if err != nil {
 			return nil, nil, nil, nil, err
 		}
 
-		for p := range preEdgesRows {
-
-			// Type-assert raw edge into well-defined struct.
-			edge := preEdgesRows[p][0].(graph.Path)
-
-			// Append to slice of edges.
-			preEdges = append(preEdges, edge)
-		}
-
 		// Pass to DOT string generator.
-		preDot, err := createDOT(preEdges, "pre")
+		preDot, err := createDOT(preEdges, "pre", n.styles())
 		if err != nil {
 			return nil, nil, nil, nil, err
 		}
 
-		err = preEdgesRaw.Close()
+		postEdges, err := pullProvEdges(stmtProv, n.Runs[i].Iteration, "post")
 		if err != nil {
 			return nil, nil, nil, nil, err
 		}
 
-		postEdgesRaw, err := stmtProv.QueryNeo(map[string]interface{}{
-			"run":       n.Runs[i].Iteration,
-			"condition": "post",
-		})
+		// Pass to DOT string generator.
+		postDot, err := createDOT(postEdges, "post", n.styles())
 		if err != nil {
 			return nil, nil, nil, nil, err
 		}
 
-		postEdgesRows, _, err := postEdgesRaw.All()
+		preCleanEdges, err := pullProvEdges(stmtProv, (1000 + n.Runs[i].Iteration), "pre")
 		if err != nil {
 			return nil, nil, nil, nil, err
 		}
 
-		for p := range postEdgesRows {
-
-			// Type-assert raw edge into well-defined struct.
-			edge := postEdgesRows[p][0].(graph.Path)
-
-			// Append to slice of edges.
-			postEdges = append(postEdges, edge)
-		}
-
 		// Pass to DOT string generator.
-		postDot, err := createDOT(postEdges, "post")
+		preCleanDot, err := createDOT(preCleanEdges, "pre", n.styles())
 		if err != nil {
 			return nil, nil, nil, nil, err
 		}
 
-		err = postEdgesRaw.Close()
+		postCleanEdges, err := pullProvEdges(stmtProv, (1000 + n.Runs[i].Iteration), "post")
 		if err != nil {
 			return nil, nil, nil, nil, err
 		}
 
-		preCleanEdgesRaw, err := stmtProv.QueryNeo(map[string]interface{}{
-			"run":       (1000 + n.Runs[i].Iteration),
-			"condition": "pre",
-		})
+		// Pass to DOT string generator.
+		postCleanDot, err := createDOT(postCleanEdges, "post", n.styles())
 		if err != nil {
 			return nil, nil, nil, nil, err
 		}
 
-		preCleanEdgesRows, _, err := preCleanEdgesRaw.All()
-		if err != nil {
-			return nil, nil, nil, nil, err
-		}
+		preDots[i] = preDot
+		postDots[i] = postDot
+		preCleanDots[i] = preCleanDot
+		postCleanDots[i] = postCleanDot
+	}
 
-		for p := range preCleanEdgesRows {
+	err = stmtProv.Close()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
 
-			// Type-assert raw edge into well-defined struct.
-			edge := preCleanEdgesRows[p][0].(graph.Path)
+	fmt.Printf("done\n\n")
 
-			// Append to slice of edges.
-			preCleanEdges = append(preCleanEdges, edge)
-		}
+	return preDots, postDots, preCleanDots, postCleanDots, nil
+}
 
-		// Pass to DOT string generator.
-		preCleanDot, err := createDOT(preCleanEdges, "pre")
-		if err != nil {
-			return nil, nil, nil, nil, err
-		}
+// PullProvGraphs pulls antecedent and consequent
+// provenance, raw and cleaned-up, of all runs as
+// graph structures for the interactive report.
+func (n *Neo4J) PullProvGraphs() ([]*fi.ProvGraph, []*fi.ProvGraph, []*fi.ProvGraph, []*fi.ProvGraph, error) {
 
-		err = preCleanEdgesRaw.Close()
-		if err != nil {
-			return nil, nil, nil, nil, err
-		}
+	fmt.Printf("Pulling antecedent and consequent provenance graphs... ")
 
-		postCleanEdgesRaw, err := stmtProv.QueryNeo(map[string]interface{}{
-			"run":       (1000 + n.Runs[i].Iteration),
-			"condition": "post",
-		})
+	preGraphs := make([]*fi.ProvGraph, len(n.Runs))
+	postGraphs := make([]*fi.ProvGraph, len(n.Runs))
+	preCleanGraphs := make([]*fi.ProvGraph, len(n.Runs))
+	postCleanGraphs := make([]*fi.ProvGraph, len(n.Runs))
+
+	stmtProv, err := n.prepareProvStmt()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	for i := range n.Runs {
+
+		iter := n.Runs[i].Iteration
+
+		preEdges, err := pullProvEdges(stmtProv, iter, "pre")
 		if err != nil {
 			return nil, nil, nil, nil, err
 		}
 
-		postCleanEdgesRows, _, err := postCleanEdgesRaw.All()
+		postEdges, err := pullProvEdges(stmtProv, iter, "post")
 		if err != nil {
 			return nil, nil, nil, nil, err
 		}
 
-		for p := range postCleanEdgesRows {
-
-			// Type-assert raw edge into well-defined struct.
-			edge := postCleanEdgesRows[p][0].(graph.Path)
-
-			// Append to slice of edges.
-			postCleanEdges = append(postCleanEdges, edge)
-		}
-
-		// Pass to DOT string generator.
-		postCleanDot, err := createDOT(postCleanEdges, "post")
+		preCleanEdges, err := pullProvEdges(stmtProv, (1000 + iter), "pre")
 		if err != nil {
 			return nil, nil, nil, nil, err
 		}
 
-		err = postCleanEdgesRaw.Close()
+		postCleanEdges, err := pullProvEdges(stmtProv, (1000 + iter), "post")
 		if err != nil {
 			return nil, nil, nil, nil, err
 		}
 
-		preDots[i] = preDot
-		postDots[i] = postDot
-		preCleanDots[i] = preCleanDot
-		postCleanDots[i] = postCleanDot
+		preGraphs[i] = createProvGraph(iter, "pre", "raw", preEdges)
+		postGraphs[i] = createProvGraph(iter, "post", "raw", postEdges)
+		preCleanGraphs[i] = createProvGraph(iter, "pre", "clean", preCleanEdges)
+		postCleanGraphs[i] = createProvGraph(iter, "post", "clean", postCleanEdges)
 	}
 
 	err = stmtProv.Close()
@@ -455,5 +491,5 @@ func (n *Neo4J) PullPrePostProv() ([]*gographviz.Graph, []*gographviz.Graph, []*
 
 	fmt.Printf("done\n\n")
 
-	return preDots, postDots, preCleanDots, postCleanDots, nil
+	return preGraphs, postGraphs, preCleanGraphs, postCleanGraphs, nil
 }
@@ -62,12 +62,12 @@ func (n *Neo4J) cleanCopyProv(iter uint, condition string) error {
 	return nil
 }
 
-// collapseNextChains
-func (n *Neo4J) collapseNextChains(iter uint, condition string) error {
-
-	run := (1000 + iter)
-
-	stmtCollapseNext, err := n.Conn2.PrepareNeo(`
+// chainQueries match the chains of rules and goals
+// collapsed into one rule, by kind of chain: @next
+// persistence chains and recursive derivations within
+// one table.
+var chainQueries = map[string]string{
+	"next": `
 		MATCH path = (r1:Rule {run: {run}, condition: {condition}, type: "next"})-[*1..]->(g:Goal {run: {run}, condition: {condition}})-[*1..]->(r2:Rule {run: {run}, condition: {condition}, type: "next"})
 		WHERE all(node IN nodes(path) WHERE node.type = "next" OR not(exists(node.type)))
 		WITH path, nodes(path) AS nodesRaw, length(path) AS len
@@ -75,9 +75,35 @@ func (n *Neo4J) collapseNextChains(iter uint, condition string) error {
 		WITH path, collect(ID(node)) AS nodes, len
 		RETURN path, nodes
 		ORDER BY len DESC;
-	`)
+	`,
+	"table": `
+		MATCH path = (r1:Rule {run: {run}, condition: {condition}})-[*1..]->(g:Goal {run: {run}, condition: {condition}})-[*1..]->(r2:Rule {run: {run}, condition: {condition}})
+		WHERE all(node IN nodes(path) WHERE node.table = r1.table AND (node:Goal OR node.type <> "collapsed"))
+		WITH path, nodes(path) AS nodesRaw, length(path) AS len
+		UNWIND nodesRaw AS node
+		WITH path, collect(ID(node)) AS nodes, len
+		RETURN path, nodes
+		ORDER BY len DESC;
+	`,
+}
+
+// chainLabels name the rule replacing a chain of
+// the respective kind, given the chain's table.
+var chainLabels = map[string]string{
+	"next":  "%s_collapsed",
+	"table": "%s_recursion",
+}
+
+// collapseChains replaces all chains of the specified
+// kind in the clean copy of the provenance of run iter
+// with one rule each. It returns the number of chains.
+func (n *Neo4J) collapseChains(iter uint, condition string, kind string) (int, error) {
+
+	run := (1000 + iter)
+
+	stmtCollapseNext, err := n.Conn2.PrepareNeo(chainQueries[kind])
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	nextPaths, err := stmtCollapseNext.QueryNeo(map[string]interface{}{
@@ -85,17 +111,17 @@ func (n *Neo4J) collapseNextChains(iter uint, condition string) error {
 		"condition": condition,
 	})
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	nextPathsAll, _, err := nextPaths.All()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	err = nextPaths.Close()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// Create structure to track top-level @next chains per iteration.
@@ -139,7 +165,7 @@ func (n *Neo4J) collapseNextChains(iter uint, condition string) error {
 
 	err = stmtCollapseNext.Close()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// Find predecessor relations to chain.
@@ -150,7 +176,7 @@ func (n *Neo4J) collapseNextChains(iter uint, condition string) error {
 		RETURN preds;
 	`)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	preds := make([][]int64, len(nextChains))
@@ -163,17 +189,17 @@ func (n *Neo4J) collapseNextChains(iter uint, condition string) error {
 			"rootID":    nextChainIDs[i][0],
 		})
 		if err != nil {
-			return err
+			return 0, err
 		}
 
 		predsAll, _, err := predsRaw.All()
 		if err != nil {
-			return err
+			return 0, err
 		}
 
 		err = predsRaw.Close()
 		if err != nil {
-			return err
+			return 0, err
 		}
 
 		preds[i] = make([]int64, 0, 1)
@@ -191,7 +217,7 @@ func (n *Neo4J) collapseNextChains(iter uint, condition string) error {
 
 	err = stmtPred.Close()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// Find all "outwards" relations of chain.
@@ -202,7 +228,7 @@ func (n *Neo4J) collapseNextChains(iter uint, condition string) error {
 		RETURN succs;
 	`)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	succs := make([][]int64, len(nextChains))
@@ -215,17 +241,17 @@ func (n *Neo4J) collapseNextChains(iter uint, condition string) error {
 			"leafID":    nextChainIDs[i][(len(nextChainIDs[i]) - 1)],
 		})
 		if err != nil {
-			return err
+			return 0, err
 		}
 
 		succsAll, _, err := succsRaw.All()
 		if err != nil {
-			return err
+			return 0, err
 		}
 
 		err = succsRaw.Close()
 		if err != nil {
-			return err
+			return 0, err
 		}
 
 		succs[i] = make([]int64, 0, 1)
@@ -243,12 +269,12 @@ func (n *Neo4J) collapseNextChains(iter uint, condition string) error {
 
 	err = stmtSucc.Close()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	for i := range nextChains {
 
-		label := fmt.Sprintf("%s_collapsed", nextChains[i][0].Properties["table"])
+		label := fmt.Sprintf(chainLabels[kind], nextChains[i][0].Properties["table"])
 		id := fmt.Sprintf("run_%d_%s_%s_%d", run, condition, label, i)
 
 		var predsIDs string
@@ -285,7 +311,7 @@ func (n *Neo4J) collapseNextChains(iter uint, condition string) error {
 			"table":     nextChains[i][0].Properties["table"],
 		})
 		if err != nil {
-			return err
+			return 0, err
 		}
 
 		// Connect newly created collapsed next node with
@@ -304,13 +330,17 @@ func (n *Neo4J) collapseNextChains(iter uint, condition string) error {
 
 		_, err = n.Conn2.ExecNeo(addPredsSuccsQuery, nil)
 		if err != nil {
-			return err
+			return 0, err
 		}
 	}
 
-	// Delete extracted next chain.
+	if len(nextChains) == 0 {
+		return 0, nil
+	}
+
+	// Delete extracted chains.
 	stmtDelChainRaw := `
-		MATCH path = (r:Rule {run: {run}, condition: {condition}, type: "next"})-[*1..]->(g:Goal {run: {run}, condition: {condition}})-[*1..]->(l:Rule {run: {run}, condition: {condition}, type: "next"})
+		MATCH path = (r:Rule {run: {run}, condition: {condition}})-[*1..]->(g:Goal {run: {run}, condition: {condition}})-[*1..]->(l:Rule {run: {run}, condition: {condition}})
 		WHERE all(node IN nodes(path) WHERE ID(node) IN ###CHAIN_IDs###)
 		WITH path, nodes(path) AS nodes, length(path) AS len
 		ORDER BY len DESC
@@ -328,7 +358,7 @@ func (n *Neo4J) collapseNextChains(iter uint, condition string) error {
 
 	stmtDelChain, err := n.Conn1.PrepareNeo(stmtDelChainRaw)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	_, err = stmtDelChain.ExecNeo(map[string]interface{}{
@@ -336,52 +366,64 @@ func (n *Neo4J) collapseNextChains(iter uint, condition string) error {
 		"condition": condition,
 	})
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	err = stmtDelChain.Close()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
+	return len(nextChains), nil
 }
 
-// SimplifyProv
-func (n *Neo4J) SimplifyProv(iters []uint) error {
+// SimplifyProv copies the provenance of all runs in
+// iters (run: 1000+) and applies the selected passes to
+// the copies, in order. It returns per run and condition
+// the names of the passes that changed its graph.
+func (n *Neo4J) SimplifyProv(iters []uint) (map[uint]map[string][]string, error) {
+
+	selected, err := n.passes()
+	if err != nil {
+		return nil, err
+	}
 
 	fmt.Printf("Preprocessing provenance graphs... ")
 
+	simplifiedBy := make(map[uint]map[string][]string, len(iters))
+
 	for i := range iters {
 
-		// Clean-copy antecedent provenance (run: 1000+).
-		err := n.cleanCopyProv(iters[i], "pre")
-		if err != nil {
-			return err
-		}
+		simplifiedBy[iters[i]] = make(map[string][]string, 2)
 
-		// Clean-copy consequent provenance (run: 1000+).
-		err = n.cleanCopyProv(iters[i], "post")
-		if err != nil {
-			return err
-		}
+		for _, condition := range []string{"pre", "post"} {
 
-		// Do preprocessing over graphs of run 1000+:
+			// Clean-copy provenance (run: 1000+).
+			err := n.cleanCopyProv(iters[i], condition)
+			if err != nil {
+				return nil, err
+			}
 
-		// Collapse @next chains in antecedent provenance.
-		err = n.collapseNextChains(iters[i], "pre")
-		if err != nil {
-			return err
-		}
+			applied := make([]string, 0, len(selected))
 
-		// Collapse @next chains in consequent provenance.
-		err = n.collapseNextChains(iters[i], "post")
-		if err != nil {
-			return err
+			// Do preprocessing over graphs of run 1000+.
+			for _, pass := range selected {
+
+				changes, err := pass.apply(n, iters[i], condition)
+				if err != nil {
+					return nil, fmt.Errorf("Pass %s failed on run %d: %v", pass.Name, iters[i], err)
+				}
+
+				if changes > 0 {
+					applied = append(applied, pass.Name)
+				}
+			}
+
+			simplifiedBy[iters[i]][condition] = applied
 		}
 	}
 
 	fmt.Printf("done\n\n")
 
-	return nil
+	return simplifiedBy, nil
 }
@@ -0,0 +1,271 @@
+package graphing
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/awalterschulze/gographviz"
+)
+
+// Structs.
+
+// protoDAG is a prototype with the dependency
+// edges between its rule tables.
+type protoDAG struct {
+	tables []string
+	edges  [][2]string
+}
+
+// Functions.
+
+// ruleDeps returns the tables of all rules in the
+// cleaned-up provenance of run iter and the edges
+// from each rule's table to the tables of the rules
+// deriving its body goals.
+func (n *Neo4J) ruleDeps(iter uint, condition string) (map[string]bool, map[[2]string]bool, error) {
+
+	params := map[string]interface{}{
+		"run":       (1000 + iter),
+		"condition": condition,
+	}
+
+	rulesRaw, err := n.Conn1.QueryNeo(`
+		MATCH (r:Rule {run: {run}, condition: {condition}})
+		RETURN DISTINCT r.table;
+	`, params)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rulesAll, _, err := rulesRaw.All()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = rulesRaw.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	depsRaw, err := n.Conn1.QueryNeo(`
+		MATCH (r1:Rule {run: {run}, condition: {condition}})-[:DUETO]->(:Goal {run: {run}, condition: {condition}})-[:DUETO]->(r2:Rule {run: {run}, condition: {condition}})
+		RETURN DISTINCT r1.table, r2.table;
+	`, params)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	depsAll, _, err := depsRaw.All()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = depsRaw.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tables := make(map[string]bool, len(rulesAll))
+	for _, row := range rulesAll {
+		tables[row[0].(string)] = true
+	}
+
+	deps := make(map[[2]string]bool, len(depsAll))
+	for _, row := range depsAll {
+
+		// Recursion adds no dependency between tables.
+		if row[0].(string) != row[1].(string) {
+			deps[[2]string{row[0].(string), row[1].(string)}] = true
+		}
+	}
+
+	return tables, deps, nil
+}
+
+// buildProtoDAG keeps the edges among root and the
+// tables of a prototype found in runDeps: in all runs
+// for the intersection, in any run for the union.
+func buildProtoDAG(root string, tables []string, runDeps []map[[2]string]bool, inAll bool) *protoDAG {
+
+	inProto := map[string]bool{root: true}
+	dag := &protoDAG{tables: []string{root}}
+	for _, table := range tables {
+
+		if !inProto[table] {
+			inProto[table] = true
+			dag.tables = append(dag.tables, table)
+		}
+	}
+
+	counts := make(map[[2]string]int)
+	for _, deps := range runDeps {
+
+		for dep := range deps {
+			counts[dep]++
+		}
+	}
+
+	for dep, count := range counts {
+
+		if !inProto[dep[0]] || !inProto[dep[1]] {
+			continue
+		}
+
+		if (inAll && (count == len(runDeps))) || (!inAll && (count > 0)) {
+			dag.edges = append(dag.edges, dep)
+		}
+	}
+
+	sort.Slice(dag.edges, func(i, j int) bool {
+
+		if dag.edges[i][0] != dag.edges[j][0] {
+			return dag.edges[i][0] < dag.edges[j][0]
+		}
+
+		return dag.edges[i][1] < dag.edges[j][1]
+	})
+
+	return dag
+}
+
+// createProtoDOT draws the prototype dag. If failedTables
+// is set, tables and dependencies absent from the failed
+// run these describe are overlaid as missing.
+func (n *Neo4J) createProtoDOT(dag *protoDAG, failedTables map[string]bool, failedDeps map[[2]string]bool) (*gographviz.Graph, error) {
+
+	styles := n.styles()
+	dotGraph := gographviz.NewGraph()
+
+	err := dotGraph.SetName("prototype")
+	if err != nil {
+		return nil, err
+	}
+
+	err = dotGraph.SetDir(true)
+	if err != nil {
+		return nil, err
+	}
+
+	err = dotGraph.AddNode("prototype", "graph", map[string]string{
+		"bgcolor": quote(styles.Background),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, table := range dag.tables {
+
+		attrs := map[string]string{
+			"label":     quote(table),
+			"shape":     styles.RuleShape,
+			"style":     "\"filled, solid\"",
+			"color":     quote(styles.Line),
+			"fontcolor": quote(styles.Line),
+			"fillcolor": quote(styles.Fill),
+		}
+
+		// The first table is the one of the condition.
+		if i == 0 {
+			attrs["color"] = quote(styles.PostHolds)
+			attrs["style"] = "\"filled, bold\""
+		}
+
+		if (failedTables != nil) && !failedTables[table] {
+			attrs["color"] = quote(styles.Missing)
+			attrs["fontcolor"] = quote(styles.Missing)
+			attrs["style"] = "\"filled, dashed, bold\""
+		}
+
+		err := dotGraph.AddNode("prototype", quote(table), attrs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, edge := range dag.edges {
+
+		attrs := map[string]string{
+			"color": quote(styles.Line),
+		}
+
+		if (failedDeps != nil) && !failedDeps[edge] {
+			attrs["color"] = quote(styles.Missing)
+			attrs["style"] = "dashed"
+		}
+
+		err := dotGraph.AddEdge(quote(edge[0]), quote(edge[1]), true, attrs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return dotGraph, nil
+}
+
+// CreatePrototypeGraphs computes the consequent intersection-
+// and union-prototype as graphs over rule tables. For each
+// failed run, it also returns both with the part missing in
+// that run overlaid.
+func (n *Neo4J) CreatePrototypeGraphs(iters []uint, failedIters []uint) (*gographviz.Graph, *gographviz.Graph, []*gographviz.Graph, []*gographviz.Graph, error) {
+
+	fmt.Printf("Drawing success prototypes... ")
+
+	interProto, unionProto, iterProv, err := n.extractProtos(iters, "post")
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	// Only runs achieving the condition shape prototypes.
+	runDeps := make([]map[[2]string]bool, 0, len(iters))
+	for i := range iters {
+
+		if len(iterProv[i]) == 0 {
+			continue
+		}
+
+		_, deps, err := n.ruleDeps(iters[i], "post")
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		runDeps = append(runDeps, deps)
+	}
+
+	root := n.condTable("post")
+	interDAG := buildProtoDAG(root, interProto, runDeps, true)
+	unionDAG := buildProtoDAG(root, unionProto, runDeps, false)
+
+	interDot, err := n.createProtoDOT(interDAG, nil, nil)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	unionDot, err := n.createProtoDOT(unionDAG, nil, nil)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	interMissDots := make([]*gographviz.Graph, len(failedIters))
+	unionMissDots := make([]*gographviz.Graph, len(failedIters))
+
+	for i := range failedIters {
+
+		tables, deps, err := n.ruleDeps(failedIters[i], "post")
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+
+		interMissDots[i], err = n.createProtoDOT(interDAG, tables, deps)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+
+		unionMissDots[i], err = n.createProtoDOT(unionDAG, tables, deps)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+	}
+
+	fmt.Printf("done\n\n")
+
+	return interDot, unionDot, interMissDots, unionMissDots, nil
+}
@@ -5,8 +5,10 @@ import (
 )
 
 // extractProtos extracts the intersection-prototype
-// and union-prototype from all iterations.
-func (n *Neo4J) extractProtos(iters []uint, condition string) ([]string, []string, error) {
+// and union-prototype from all iterations. It also returns
+// the rule tables of each iteration, empty for iterations
+// that did not achieve the condition.
+func (n *Neo4J) extractProtos(iters []uint, condition string) ([]string, []string, [][]string, error) {
 
 	stmtCondRules, err := n.Conn1.PrepareNeo(`
 		MATCH path = (root:Goal {run: {run}, condition: {condition}})-[*1]->(r1:Rule {run: {run}, condition: {condition}})-[*1..]->(r2:Rule {run: {run}, condition: {condition}})
@@ -23,7 +25,7 @@ func (n *Neo4J) extractProtos(iters []uint, condition string) ([]string, []strin
 		RETURN rules;
     `)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	achvdCond := 0
@@ -40,17 +42,17 @@ func (n *Neo4J) extractProtos(iters []uint, condition string) ([]string, []strin
 			"condition": condition,
 		})
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
 
 		condAllRules, _, err := condRules.All()
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
 
 		err = condRules.Close()
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
 
 		for j := range condAllRules {
@@ -103,7 +105,7 @@ func (n *Neo4J) extractProtos(iters []uint, condition string) ([]string, []strin
 		}
 
 		// If in intersection, append label to final prototype.
-		if (foundIn == achvdCond) && (iterProv[0][i] != condition) {
+		if (foundIn == achvdCond) && (iterProv[0][i] != n.condTable(condition)) {
 			interProto = append(interProto, iterProv[0][i])
 		}
 	}
@@ -117,7 +119,7 @@ func (n *Neo4J) extractProtos(iters []uint, condition string) ([]string, []strin
 
 			if i < len(iterProv[j]) {
 
-				if !alreadySeen[iterProv[j][i]] && (iterProv[j][i] != condition) {
+				if !alreadySeen[iterProv[j][i]] && (iterProv[j][i] != n.condTable(condition)) {
 
 					// New label, add to union.
 					unionProto = append(unionProto, iterProv[j][i])
@@ -131,10 +133,10 @@ func (n *Neo4J) extractProtos(iters []uint, condition string) ([]string, []strin
 
 	err = stmtCondRules.Close()
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
-	return interProto, unionProto, nil
+	return interProto, unionProto, iterProv, nil
 }
 
 // missingFrom
@@ -215,7 +217,7 @@ func (n *Neo4J) CreatePrototypes(iters []uint, failedIters []uint) ([]string, []
 
 	// Create consequent intersection-prototype
 	// and union-prototype.
-	interProto, unionProto, err := n.extractProtos(iters, "post")
+	interProto, unionProto, _, err := n.extractProtos(iters, "post")
 	if err != nil {
 		return nil, nil, nil, nil, err
 	}
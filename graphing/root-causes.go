@@ -0,0 +1,249 @@
+package graphing
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	fi "github.com/numbleroot/nemo/faultinjectors"
+)
+
+// Weights of the inputs to the score of a candidate
+// root cause. Each input lies between 0 and 1.
+const (
+	weightDistance = 1.0
+	weightRuns     = 1.0
+	weightAsync    = 0.5
+	weightFault    = 1.5
+)
+
+// Structs.
+
+// goodEvent is where a goal sits in the
+// consequent provenance of the good run.
+type goodEvent struct {
+	distance int
+	sender   string
+	receiver string
+}
+
+// Functions.
+
+// locateEvents finds for each goal label in prov its
+// distance to the root in derivation steps, the node
+// holding it, and the node of the goal derived from it.
+func locateEvents(prov *fi.ProvGraph) map[string]*goodEvent {
+
+	events := make(map[string]*goodEvent)
+
+	if prov == nil {
+		return events
+	}
+
+	nodes := make(map[string]*fi.ProvNode, len(prov.Nodes))
+	for _, node := range prov.Nodes {
+		nodes[node.ID] = node
+	}
+
+	children := make(map[string][]string)
+	parents := make(map[string][]string)
+	for _, edge := range prov.Edges {
+		children[edge.From] = append(children[edge.From], edge.To)
+		parents[edge.To] = append(parents[edge.To], edge.From)
+	}
+
+	// Walk down from the roots, breadth-first.
+	depths := make(map[string]int, len(prov.Nodes))
+	queue := make([]string, 0, len(prov.Nodes))
+	for _, node := range prov.Nodes {
+
+		if len(parents[node.ID]) == 0 {
+			depths[node.ID] = 0
+			queue = append(queue, node.ID)
+		}
+	}
+
+	for len(queue) > 0 {
+
+		id := queue[0]
+		queue = queue[1:]
+
+		for _, child := range children[id] {
+
+			if _, found := depths[child]; !found {
+				depths[child] = depths[id] + 1
+				queue = append(queue, child)
+			}
+		}
+	}
+
+	for _, node := range prov.Nodes {
+
+		depth, found := depths[node.ID]
+		if (node.Kind != "goal") || !found {
+			continue
+		}
+
+		// Goals and rules alternate.
+		event := &goodEvent{
+			distance: (depth / 2),
+			sender:   goalNode(node.Label),
+		}
+		event.receiver = event.sender
+
+		for _, rule := range parents[node.ID] {
+
+			for _, head := range parents[rule] {
+
+				if nodes[head] != nil {
+					event.receiver = goalNode(nodes[head].Label)
+				}
+			}
+		}
+
+		if prev, found := events[node.Label]; !found || (event.distance < prev.distance) {
+			events[node.Label] = event
+		}
+	}
+
+	return events
+}
+
+// faultHit describes the fault in spec that hit the event
+// sent by sender at time to receiver, if any.
+func faultHit(spec *fi.FailureSpec, sender string, receiver string, time int, async bool) string {
+
+	if spec == nil {
+		return ""
+	}
+
+	if async && (spec.Omissions != nil) {
+
+		for _, o := range *spec.Omissions {
+
+			if (o.From == sender) && (o.To == receiver) && (int(o.Time) == time) {
+				return fmt.Sprintf("omission %s -> %s @%d dropped it", o.From, o.To, o.Time)
+			}
+		}
+	}
+
+	// Asynchronous events arrive one step later.
+	arrival := time
+	if async {
+		arrival++
+	}
+
+	if spec.Crashes != nil {
+
+		for _, c := range *spec.Crashes {
+
+			if (c.Node == sender) && (int(c.Time) <= time) {
+				return fmt.Sprintf("crash of %s @%d hit its sender", c.Node, c.Time)
+			}
+
+			if (c.Node == receiver) && (int(c.Time) <= arrival) {
+				return fmt.Sprintf("crash of %s @%d hit its receiver", c.Node, c.Time)
+			}
+		}
+	}
+
+	return ""
+}
+
+// RankRootCauses scores the events missing from each failed
+// run, highest first. An event scores higher the closer it
+// is to the consequent in the good run, the more failed runs
+// miss it, if it depends on the network, and if an injected
+// fault hit the node sending or receiving it.
+func RankRootCauses(goodPost *fi.ProvGraph, missing [][]*fi.Missing, failedRuns []*fi.Run) [][]*fi.RootCause {
+
+	events := locateEvents(goodPost)
+
+	// Count the failed runs missing each event.
+	missedIn := make(map[string]int)
+	for i := range missing {
+
+		seen := make(map[string]bool)
+		for _, m := range missing[i] {
+
+			for _, goal := range m.Goals {
+
+				if !seen[goal.Label] {
+					seen[goal.Label] = true
+					missedIn[goal.Label]++
+				}
+			}
+		}
+	}
+
+	ranked := make([][]*fi.RootCause, len(missing))
+
+	for i := range missing {
+
+		var spec *fi.FailureSpec
+		if (i < len(failedRuns)) && (failedRuns[i] != nil) {
+			spec = failedRuns[i].FailureSpec
+		}
+
+		seen := make(map[string]bool)
+		ranked[i] = make([]*fi.RootCause, 0, len(missing[i]))
+
+		for _, m := range missing[i] {
+
+			async := (m.Rule != nil) && (m.Rule.Type == "async")
+
+			for _, goal := range m.Goals {
+
+				if seen[goal.Label] {
+					continue
+				}
+				seen[goal.Label] = true
+
+				cause := &fi.RootCause{
+					Event:   goal.Label,
+					Table:   goal.Table,
+					Time:    goal.Time,
+					Reasons: make([]string, 0, 4),
+				}
+
+				if m.Rule != nil {
+					cause.Rule = m.Rule.Table
+				}
+
+				sender, receiver := goalNode(goal.Label), goalNode(goal.Label)
+
+				if event, found := events[goal.Label]; found {
+
+					sender, receiver = event.sender, event.receiver
+					cause.Score += weightDistance / float64(1+event.distance)
+					cause.Reasons = append(cause.Reasons, fmt.Sprintf("%d derivation step(s) from the consequent in the good run", event.distance))
+				}
+
+				cause.Score += weightRuns * float64(missedIn[goal.Label]) / float64(len(missing))
+				cause.Reasons = append(cause.Reasons, fmt.Sprintf("missing in %d of %d failed run(s)", missedIn[goal.Label], len(missing)))
+
+				if async {
+					cause.Score += weightAsync
+					cause.Reasons = append(cause.Reasons, "sent over the network (@async)")
+				}
+
+				time, err := strconv.Atoi(goal.Time)
+				if err == nil {
+
+					if hit := faultHit(spec, sender, receiver, time, async); hit != "" {
+						cause.Score += weightFault
+						cause.Reasons = append(cause.Reasons, hit)
+					}
+				}
+
+				ranked[i] = append(ranked[i], cause)
+			}
+		}
+
+		sort.SliceStable(ranked[i], func(a, b int) bool {
+			return ranked[i][a].Score > ranked[i][b].Score
+		})
+	}
+
+	return ranked
+}
@@ -0,0 +1,78 @@
+package graphing
+
+import (
+	"fmt"
+)
+
+// Structs.
+
+// Styles defines the colors and shapes of the
+// provenance and space-time figures. Values are
+// Graphviz color names or codes and node shapes.
+type Styles struct {
+	Background  string
+	Fill        string
+	Line        string
+	Async       string
+	Next        string
+	PreHolds    string
+	PostHolds   string
+	Missing     string
+	Inactive    string
+	SuccessOnly string
+	FailureOnly string
+	RuleShape   string
+	GoalShape   string
+}
+
+// Functions.
+
+// DefaultStyles returns the styles figures
+// are drawn with unless configured otherwise.
+func DefaultStyles() *Styles {
+
+	return &Styles{
+		Background:  "transparent",
+		Fill:        "white",
+		Line:        "black",
+		Async:       "lawngreen",
+		Next:        "gold",
+		PreHolds:    "firebrick",
+		PostHolds:   "deepskyblue",
+		Missing:     "mediumvioletred",
+		Inactive:    "lightgrey",
+		SuccessOnly: "forestgreen",
+		FailureOnly: "orangered",
+		RuleShape:   "rect",
+		GoalShape:   "ellipse",
+	}
+}
+
+// quote returns value as quoted DOT attribute.
+func quote(value string) string {
+	return fmt.Sprintf("\"%s\"", value)
+}
+
+// styles returns the configured styles or,
+// if there are none, the default ones.
+func (n *Neo4J) styles() *Styles {
+
+	if n.Styles == nil {
+		return DefaultStyles()
+	}
+
+	return n.Styles
+}
+
+// condTable returns the name of the table
+// that defines the condition cond.
+func (n *Neo4J) condTable(cond string) string {
+
+	if (cond == "pre") && (n.PreTable != "") {
+		return n.PreTable
+	} else if (cond == "post") && (n.PostTable != "") {
+		return n.PostTable
+	}
+
+	return cond
+}
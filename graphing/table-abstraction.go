@@ -0,0 +1,354 @@
+package graphing
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/awalterschulze/gographviz"
+	fi "github.com/numbleroot/nemo/faultinjectors"
+)
+
+// Structs.
+
+// TableEdge aggregates all derivations in which goals
+// of table From were used to derive goals of table To.
+type TableEdge struct {
+	From        string   `json:"from"`
+	To          string   `json:"to"`
+	Count       int      `json:"count"`
+	Earliest    int      `json:"earliest"`
+	Latest      int      `json:"latest"`
+	Nodes       []string `json:"nodes"`
+	SuccessRuns int      `json:"successRuns,omitempty"`
+	FailedRuns  int      `json:"failedRuns,omitempty"`
+}
+
+// TableGraph is the provenance of one condition folded
+// into a graph over tables, for one run or, if built by
+// MergeTableGraphs, across all runs.
+type TableGraph struct {
+	Iteration uint         `json:"iteration"`
+	Condition string       `json:"condition"`
+	Tables    []string     `json:"tables"`
+	Edges     []*TableEdge `json:"edges"`
+}
+
+// Functions.
+
+// goalNode returns the node a goal holds at,
+// i.e., the first attribute of its label.
+func goalNode(label string) string {
+
+	open := strings.Index(label, "(")
+	if open < 0 {
+		return ""
+	}
+
+	args := strings.TrimSuffix(label[(open+1):], ")")
+	return strings.TrimSpace(strings.Split(args, ",")[0])
+}
+
+// addNode adds node to the sorted set nodes.
+func addNode(nodes []string, node string) []string {
+
+	i := sort.SearchStrings(nodes, node)
+	if (i < len(nodes)) && (nodes[i] == node) {
+		return nodes
+	}
+
+	nodes = append(nodes, "")
+	copy(nodes[(i+1):], nodes[i:])
+	nodes[i] = node
+
+	return nodes
+}
+
+// sortedTableEdges returns the edges in edges
+// ordered by source and destination table.
+func sortedTableEdges(edges map[[2]string]*TableEdge) []*TableEdge {
+
+	sorted := make([]*TableEdge, 0, len(edges))
+	for _, edge := range edges {
+		sorted = append(sorted, edge)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+
+		if sorted[i].From != sorted[j].From {
+			return sorted[i].From < sorted[j].From
+		}
+
+		return sorted[i].To < sorted[j].To
+	})
+
+	return sorted
+}
+
+// AbstractTables folds the provenance graph prov into a
+// graph over tables. Each firing of a rule adds to the
+// edges from the tables of its body goals to its table,
+// at the time and node of the goal it derives.
+func AbstractTables(prov *fi.ProvGraph) *TableGraph {
+
+	nodes := make(map[string]*fi.ProvNode, len(prov.Nodes))
+	tables := make([]string, 0, 10)
+	for _, node := range prov.Nodes {
+		nodes[node.ID] = node
+		tables = addNode(tables, node.Table)
+	}
+
+	// Goals are derived by rules, rules use goals.
+	heads := make(map[string][]*fi.ProvNode)
+	bodies := make(map[string][]*fi.ProvNode)
+	for _, edge := range prov.Edges {
+
+		from, to := nodes[edge.From], nodes[edge.To]
+		if (from == nil) || (to == nil) {
+			continue
+		}
+
+		if (from.Kind == "goal") && (to.Kind == "rule") {
+			heads[to.ID] = append(heads[to.ID], from)
+		} else if (from.Kind == "rule") && (to.Kind == "goal") {
+			bodies[from.ID] = append(bodies[from.ID], to)
+		}
+	}
+
+	edges := make(map[[2]string]*TableEdge)
+
+	for _, rule := range prov.Nodes {
+
+		if rule.Kind != "rule" {
+			continue
+		}
+
+		for _, head := range heads[rule.ID] {
+
+			time, err := strconv.Atoi(head.Time)
+			if err != nil {
+				time = -1
+			}
+
+			for _, body := range bodies[rule.ID] {
+
+				key := [2]string{body.Table, rule.Table}
+
+				edge, found := edges[key]
+				if !found {
+					edge = &TableEdge{From: body.Table, To: rule.Table, Earliest: time, Latest: time, Nodes: make([]string, 0, 2)}
+					edges[key] = edge
+				}
+
+				edge.Count++
+
+				if (time >= 0) && ((edge.Earliest < 0) || (time < edge.Earliest)) {
+					edge.Earliest = time
+				}
+
+				if time > edge.Latest {
+					edge.Latest = time
+				}
+
+				if node := goalNode(head.Label); node != "" {
+					edge.Nodes = addNode(edge.Nodes, node)
+				}
+			}
+		}
+	}
+
+	return &TableGraph{
+		Iteration: prov.Iteration,
+		Condition: prov.Condition,
+		Tables:    tables,
+		Edges:     sortedTableEdges(edges),
+	}
+}
+
+// MergeTableGraphs combines the table graphs of all
+// runs into one, counting for each edge how many
+// successful and failed runs used it.
+func MergeTableGraphs(graphs []*TableGraph, failed map[uint]bool) *TableGraph {
+
+	merged := &TableGraph{Tables: make([]string, 0, 10)}
+	edges := make(map[[2]string]*TableEdge)
+
+	for _, g := range graphs {
+
+		if g == nil {
+			continue
+		}
+
+		merged.Condition = g.Condition
+
+		for _, table := range g.Tables {
+			merged.Tables = addNode(merged.Tables, table)
+		}
+
+		for _, e := range g.Edges {
+
+			key := [2]string{e.From, e.To}
+
+			edge, found := edges[key]
+			if !found {
+				edge = &TableEdge{From: e.From, To: e.To, Earliest: e.Earliest, Latest: e.Latest, Nodes: make([]string, 0, len(e.Nodes))}
+				edges[key] = edge
+			}
+
+			edge.Count += e.Count
+
+			if (e.Earliest >= 0) && ((edge.Earliest < 0) || (e.Earliest < edge.Earliest)) {
+				edge.Earliest = e.Earliest
+			}
+
+			if e.Latest > edge.Latest {
+				edge.Latest = e.Latest
+			}
+
+			for _, node := range e.Nodes {
+				edge.Nodes = addNode(edge.Nodes, node)
+			}
+
+			if failed[g.Iteration] {
+				edge.FailedRuns++
+			} else {
+				edge.SuccessRuns++
+			}
+		}
+	}
+
+	merged.Edges = sortedTableEdges(edges)
+
+	return merged
+}
+
+// createTableDOT draws the table graph tg. Edges of the
+// graph across runs are colored by whether only successful,
+// only failed, or both kinds of runs used them.
+func (n *Neo4J) createTableDOT(tg *TableGraph, acrossRuns bool) (*gographviz.Graph, error) {
+
+	styles := n.styles()
+	dotGraph := gographviz.NewGraph()
+
+	err := dotGraph.SetName("tables")
+	if err != nil {
+		return nil, err
+	}
+
+	err = dotGraph.SetDir(true)
+	if err != nil {
+		return nil, err
+	}
+
+	err = dotGraph.AddNode("tables", "graph", map[string]string{
+		"bgcolor": quote(styles.Background),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, table := range tg.Tables {
+
+		attrs := map[string]string{
+			"label":     quote(table),
+			"shape":     styles.RuleShape,
+			"style":     "\"filled, solid\"",
+			"color":     quote(styles.Line),
+			"fontcolor": quote(styles.Line),
+			"fillcolor": quote(styles.Fill),
+		}
+
+		// Mark the table defining the condition.
+		if (tg.Condition == "pre") && (table == n.condTable("pre")) {
+			attrs["color"] = quote(styles.PreHolds)
+			attrs["style"] = "\"filled, bold\""
+		} else if (tg.Condition == "post") && (table == n.condTable("post")) {
+			attrs["color"] = quote(styles.PostHolds)
+			attrs["style"] = "\"filled, bold\""
+		}
+
+		err := dotGraph.AddNode("tables", quote(table), attrs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, edge := range tg.Edges {
+
+		times := fmt.Sprintf("@%d", edge.Earliest)
+		if edge.Latest != edge.Earliest {
+			times = fmt.Sprintf("@%d-%d", edge.Earliest, edge.Latest)
+		}
+
+		label := fmt.Sprintf("%dx %s\\n%s", edge.Count, times, strings.Join(edge.Nodes, ", "))
+		color := styles.Line
+
+		if acrossRuns {
+
+			label = fmt.Sprintf("%d succ. / %d failed\\n%s", edge.SuccessRuns, edge.FailedRuns, label)
+
+			if edge.FailedRuns == 0 {
+				color = styles.SuccessOnly
+			} else if edge.SuccessRuns == 0 {
+				color = styles.FailureOnly
+			}
+		}
+
+		err := dotGraph.AddEdge(quote(edge.From), quote(edge.To), true, map[string]string{
+			"label":     quote(label),
+			"color":     quote(color),
+			"fontcolor": quote(color),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return dotGraph, nil
+}
+
+// CreateTableAbstraction folds the provenance graphs of
+// each run into table graphs and merges them across runs.
+// It returns the DOT figures per run and across runs for
+// antecedent and consequent, followed by the table graphs.
+func (n *Neo4J) CreateTableAbstraction(preProvs []*fi.ProvGraph, postProvs []*fi.ProvGraph, failedIters []uint) ([]*gographviz.Graph, []*gographviz.Graph, []*gographviz.Graph, []*TableGraph, error) {
+
+	failed := make(map[uint]bool, len(failedIters))
+	for _, iter := range failedIters {
+		failed[iter] = true
+	}
+
+	provs := [][]*fi.ProvGraph{preProvs, postProvs}
+	perRun := make([][]*gographviz.Graph, 2)
+	acrossRuns := make([]*gographviz.Graph, 2)
+	merged := make([]*TableGraph, 2)
+
+	for c := range provs {
+
+		graphs := make([]*TableGraph, len(provs[c]))
+		perRun[c] = make([]*gographviz.Graph, len(provs[c]))
+
+		for i := range provs[c] {
+
+			graphs[i] = AbstractTables(provs[c][i])
+
+			dot, err := n.createTableDOT(graphs[i], false)
+			if err != nil {
+				return nil, nil, nil, nil, err
+			}
+			perRun[c][i] = dot
+		}
+
+		merged[c] = MergeTableGraphs(graphs, failed)
+		merged[c].Condition = []string{"pre", "post"}[c]
+
+		dot, err := n.createTableDOT(merged[c], true)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		acrossRuns[c] = dot
+	}
+
+	return perRun[0], perRun[1], acrossRuns, merged, nil
+}
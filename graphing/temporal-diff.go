@@ -0,0 +1,318 @@
+package graphing
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/awalterschulze/gographviz"
+	fi "github.com/numbleroot/nemo/faultinjectors"
+)
+
+// Functions.
+
+// factsByTime collects the labels of all goals in provs
+// by the time they were derived at.
+func factsByTime(provs ...*fi.ProvGraph) map[int]map[string]bool {
+
+	facts := make(map[int]map[string]bool)
+
+	for _, prov := range provs {
+
+		if prov == nil {
+			continue
+		}
+
+		for _, node := range prov.Nodes {
+
+			if node.Kind != "goal" {
+				continue
+			}
+
+			time, err := strconv.Atoi(node.Time)
+			if (err != nil) || (time < 0) {
+				continue
+			}
+
+			if facts[time] == nil {
+				facts[time] = make(map[string]bool)
+			}
+			facts[time][node.Label] = true
+		}
+	}
+
+	return facts
+}
+
+// injectedFaults describes the faults in spec by the
+// time they take effect at. Omitted messages would have
+// arrived one step after they were sent, crashed nodes
+// stop deriving facts at the time of the crash.
+func injectedFaults(spec *fi.FailureSpec) map[int][]string {
+
+	faults := make(map[int][]string)
+
+	if spec == nil {
+		return faults
+	}
+
+	if spec.Omissions != nil {
+
+		for _, o := range *spec.Omissions {
+			time := int(o.Time) + 1
+			faults[time] = append(faults[time], fmt.Sprintf("omission %s -> %s @%d", o.From, o.To, o.Time))
+		}
+	}
+
+	if spec.Crashes != nil {
+
+		for _, c := range *spec.Crashes {
+			time := int(c.Time)
+			faults[time] = append(faults[time], fmt.Sprintf("crash of %s @%d", c.Node, c.Time))
+		}
+	}
+
+	return faults
+}
+
+// diffFacts returns the facts in a but not in b, sorted.
+func diffFacts(a map[string]bool, b map[string]bool) []string {
+
+	diff := make([]string, 0, len(a))
+	for fact := range a {
+
+		if !b[fact] {
+			diff = append(diff, fact)
+		}
+	}
+
+	sort.Strings(diff)
+
+	return diff
+}
+
+// TemporalDiff aligns the goals of good and failed by the
+// time they were derived at and finds the earliest time
+// at which the two runs derived different facts, along
+// with the latest faults taking effect until then.
+func TemporalDiff(good []*fi.ProvGraph, failed []*fi.ProvGraph, spec *fi.FailureSpec, goodRun uint) *fi.Divergence {
+
+	goodFacts := factsByTime(good...)
+	failedFacts := factsByTime(failed...)
+	faults := injectedFaults(spec)
+
+	// Consider every time any run derived facts
+	// at or any fault took effect at.
+	timesSeen := make(map[int]bool)
+	for time := range goodFacts {
+		timesSeen[time] = true
+	}
+
+	for time := range failedFacts {
+		timesSeen[time] = true
+	}
+
+	for time := range faults {
+		timesSeen[time] = true
+	}
+
+	times := make([]int, 0, len(timesSeen))
+	for time := range timesSeen {
+		times = append(times, time)
+	}
+	sort.Ints(times)
+
+	div := &fi.Divergence{
+		GoodRun:  goodRun,
+		Time:     -1,
+		Timeline: make([]*fi.TimeStep, 0, len(times)),
+	}
+
+	for _, time := range times {
+
+		step := &fi.TimeStep{
+			Time:    time,
+			Missing: diffFacts(goodFacts[time], failedFacts[time]),
+			Extra:   diffFacts(failedFacts[time], goodFacts[time]),
+			Faults:  faults[time],
+		}
+		step.Shared = len(goodFacts[time]) - len(step.Missing)
+
+		div.Timeline = append(div.Timeline, step)
+
+		if (div.Time < 0) && ((len(step.Missing) > 0) || (len(step.Extra) > 0)) {
+			div.Time = time
+			div.Missing = step.Missing
+			div.Extra = step.Extra
+		}
+	}
+
+	// Name the latest faults taking effect
+	// no later than the runs diverged.
+	if div.Time >= 0 {
+
+		for i := (len(times) - 1); i >= 0; i-- {
+
+			if (times[i] <= div.Time) && (len(faults[times[i]]) > 0) {
+				div.Faults = faults[times[i]]
+				break
+			}
+		}
+	}
+
+	return div
+}
+
+// createTimelineDOT draws the timeline of div from left
+// to right, marking the time of divergence and attaching
+// each fault to the time it took effect at.
+func (n *Neo4J) createTimelineDOT(div *fi.Divergence) (*gographviz.Graph, error) {
+
+	styles := n.styles()
+	dotGraph := gographviz.NewGraph()
+
+	err := dotGraph.SetName("timeline")
+	if err != nil {
+		return nil, err
+	}
+
+	err = dotGraph.SetDir(true)
+	if err != nil {
+		return nil, err
+	}
+
+	err = dotGraph.AddNode("timeline", "graph", map[string]string{
+		"bgcolor": quote(styles.Background),
+		"rankdir": "LR",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	prev := ""
+	for i, step := range div.Timeline {
+
+		name := fmt.Sprintf("t%d", step.Time)
+		label := fmt.Sprintf("@%d\\n%d shared", step.Time, step.Shared)
+		color := styles.Line
+		style := "\"filled, solid\""
+
+		if len(step.Missing) > 0 {
+			label = fmt.Sprintf("%s\\n%d missing", label, len(step.Missing))
+		}
+
+		if len(step.Extra) > 0 {
+			label = fmt.Sprintf("%s\\n%d extra", label, len(step.Extra))
+		}
+
+		if step.Time == div.Time {
+
+			// Spell out what differed first.
+			for _, fact := range step.Missing {
+				label = fmt.Sprintf("%s\\n- %s", label, fact)
+			}
+
+			for _, fact := range step.Extra {
+				label = fmt.Sprintf("%s\\n+ %s", label, fact)
+			}
+
+			color = styles.Missing
+			style = "\"filled, bold\""
+		} else if (len(step.Missing) > 0) || (len(step.Extra) > 0) {
+			color = styles.Missing
+		}
+
+		err := dotGraph.AddNode("timeline", name, map[string]string{
+			"label":     quote(label),
+			"shape":     styles.RuleShape,
+			"style":     style,
+			"color":     quote(color),
+			"fontcolor": quote(color),
+			"fillcolor": quote(styles.Fill),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if prev != "" {
+
+			err := dotGraph.AddEdge(prev, name, true, map[string]string{
+				"color": quote(styles.Line),
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		prev = name
+
+		for j, fault := range step.Faults {
+
+			faultName := fmt.Sprintf("f%d_%d", i, j)
+
+			err := dotGraph.AddNode("timeline", faultName, map[string]string{
+				"label":     quote(fault),
+				"shape":     styles.GoalShape,
+				"style":     "\"filled, dashed\"",
+				"color":     quote(styles.FailureOnly),
+				"fontcolor": quote(styles.FailureOnly),
+				"fillcolor": quote(styles.Fill),
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			err = dotGraph.AddEdge(faultName, name, true, map[string]string{
+				"color": quote(styles.FailureOnly),
+				"style": "dashed",
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return dotGraph, nil
+}
+
+// CreateTemporalDiff finds for each failed run when it
+// diverged from the good run, based on the antecedent and
+// consequent provenance of all runs. It returns a timeline
+// figure and the divergence of each failed run.
+func (n *Neo4J) CreateTemporalDiff(preProvs []*fi.ProvGraph, postProvs []*fi.ProvGraph, runs []*fi.Run, goodRun uint, failedIters []uint) ([]*gographviz.Graph, []*fi.Divergence, error) {
+
+	provs := make(map[uint][]*fi.ProvGraph)
+	for _, prov := range append(append([]*fi.ProvGraph{}, preProvs...), postProvs...) {
+
+		if prov != nil {
+			provs[prov.Iteration] = append(provs[prov.Iteration], prov)
+		}
+	}
+
+	if len(provs[goodRun]) == 0 {
+		return nil, nil, fmt.Errorf("Missing provenance of good run %d", goodRun)
+	}
+
+	specs := make(map[uint]*fi.FailureSpec, len(runs))
+	for _, run := range runs {
+
+		if run != nil {
+			specs[run.Iteration] = run.FailureSpec
+		}
+	}
+
+	dots := make([]*gographviz.Graph, len(failedIters))
+	divs := make([]*fi.Divergence, len(failedIters))
+
+	for i, iter := range failedIters {
+
+		divs[i] = TemporalDiff(provs[goodRun], provs[iter], specs[iter], goodRun)
+
+		dot, err := n.createTimelineDOT(divs[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		dots[i] = dot
+	}
+
+	return dots, divs, nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"strings"
+
+	"io/ioutil"
+	"path/filepath"
+
+	cf "github.com/numbleroot/nemo/config"
+	fi "github.com/numbleroot/nemo/faultinjectors"
+)
+
+// Structs.
+
+// invariantOutcome holds the runs of one
+// invariant after its stages have run.
+type invariantOutcome struct {
+	invariant  *cf.Invariant
+	runs       []*fi.Run
+	reportPath string
+}
+
+// Functions.
+
+// invariantDir returns the results directory of invariant
+// inv below execDir. The single unnamed invariant stores
+// its results in execDir directly.
+func invariantDir(execDir string, inv *cf.Invariant) string {
+
+	if inv.Name == "" {
+		return execDir
+	}
+
+	return filepath.Join(execDir, inv.Name)
+}
+
+// selectInvariants returns the invariants of cfg to
+// analyze, or only the one called name if it is set.
+func selectInvariants(cfg *cf.Config, name string) ([]*cf.Invariant, error) {
+
+	invs := cfg.AllInvariants()
+
+	if name == "" {
+		return invs, nil
+	}
+
+	names := make([]string, 0, len(invs))
+	for _, inv := range invs {
+
+		if inv.Name == name {
+			return []*cf.Invariant{inv}, nil
+		}
+
+		names = append(names, inv.Name)
+	}
+
+	return nil, fmt.Errorf("Unknown invariant '%s', choose from: %s", name, strings.Join(names, ", "))
+}
+
+// failed returns the number of runs violating the invariant.
+func (o *invariantOutcome) failed() int {
+
+	failed := 0
+	for i := range o.runs {
+
+		if (o.runs[i] != nil) && (o.runs[i].Status != "success") {
+			failed++
+		}
+	}
+
+	return failed
+}
+
+// violated reports whether any run violated any invariant.
+func violated(outcomes []*invariantOutcome) bool {
+
+	for _, o := range outcomes {
+
+		if o.failed() > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// runInvariants runs stages once per invariant, one after
+// the other, as all of them share the graph database.
+// newRun sets up the DebugRun of an invariant given the
+// config analyzing it and its results directory.
+func runInvariants(invs []*cf.Invariant, cfg *cf.Config, stages []string, execDir string, newRun func(*cf.Config, string, *cf.Invariant) (*DebugRun, error)) ([]*invariantOutcome, error) {
+
+	outcomes := make([]*invariantOutcome, 0, len(invs))
+
+	for _, inv := range invs {
+
+		if inv.Name != "" {
+			fmt.Printf("Analyzing invariant '%s' (%s => %s)...\n\n", inv.Name, inv.Pre, inv.Post)
+		}
+
+		debugRun, err := newRun(cfg.ForInvariant(inv), invariantDir(execDir, inv), inv)
+		if err != nil {
+			return nil, err
+		}
+
+		runs, reportPath, err := debugRun.runStages(stages)
+		if err != nil {
+			if inv.Name != "" {
+				return nil, fmt.Errorf("Invariant '%s': %v", inv.Name, err)
+			}
+			return nil, err
+		}
+
+		outcomes = append(outcomes, &invariantOutcome{
+			invariant:  inv,
+			runs:       runs,
+			reportPath: reportPath,
+		})
+	}
+
+	return outcomes, nil
+}
+
+// writeInvariantsIndex writes index.html to execDir,
+// linking the reports of all invariants along with
+// how many runs violated each.
+func writeInvariantsIndex(execDir string, outcomes []*invariantOutcome) (string, error) {
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html>\n<head><meta charset = \"utf-8\" /><title>Nemo: %s</title></head>\n<body>\n", html.EscapeString(filepath.Base(execDir)))
+	fmt.Fprintf(&b, "<h1>Invariants of %s</h1>\n<ul>\n", html.EscapeString(filepath.Base(execDir)))
+
+	for _, o := range outcomes {
+
+		inv := o.invariant
+		fmt.Fprintf(&b, "<li><a href = \"%s/\">%s</a>: %s &rArr; %s (%d runs, %d violated)</li>\n",
+			html.EscapeString(inv.Name), html.EscapeString(inv.Name), html.EscapeString(inv.Pre), html.EscapeString(inv.Post), len(o.runs), o.failed())
+	}
+
+	fmt.Fprintf(&b, "</ul>\n</body>\n</html>\n")
+
+	err := os.MkdirAll(execDir, 0755)
+	if err != nil {
+		return "", fmt.Errorf("Could not ensure results directory exists: %v", err)
+	}
+
+	indexPath := filepath.Join(execDir, "index.html")
+
+	err = ioutil.WriteFile(indexPath, []byte(b.String()), 0644)
+	if err != nil {
+		return "", fmt.Errorf("Error writing out overview of invariants: %v", err)
+	}
+
+	return indexPath, nil
+}
@@ -5,15 +5,16 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
-	"encoding/json"
-	"io/ioutil"
+	"io/fs"
 	"path/filepath"
 
 	"github.com/awalterschulze/gographviz"
+	cf "github.com/numbleroot/nemo/config"
+	"github.com/numbleroot/nemo/dedalus"
 	fi "github.com/numbleroot/nemo/faultinjectors"
 	gr "github.com/numbleroot/nemo/graphing"
-	re "github.com/numbleroot/nemo/report"
 )
 
 // Interfaces.
@@ -21,6 +22,8 @@ import (
 // FaultInjector
 type FaultInjector interface {
 	LoadOutput() error
+	LoadProvenance([]uint) error
+	Validate() []*fi.Problem
 	GetFailureSpec() *fi.FailureSpec
 	GetMsgsFailedRuns() [][]*fi.Message
 	GetOutput() []*fi.Run
@@ -33,21 +36,27 @@ type FaultInjector interface {
 type GraphDatabase interface {
 	InitGraphDB(string, []*fi.Run) error
 	CloseDB() error
-	LoadRawProvenance() error
-	SimplifyProv([]uint) error
-	CreateHazardAnalysis(string) ([]*gographviz.Graph, error)
+	LoadRawProvenance([]uint) error
+	SimplifyProv([]uint) (map[uint]map[string][]string, error)
+	CreateHazardAnalysis(fs.FS) ([]*gographviz.Graph, error)
 	CreatePrototypes([]uint, []uint) ([]string, [][]string, []string, [][]string, error)
+	CreatePrototypeGraphs([]uint, []uint) (*gographviz.Graph, *gographviz.Graph, []*gographviz.Graph, []*gographviz.Graph, error)
 	PullPrePostProv() ([]*gographviz.Graph, []*gographviz.Graph, []*gographviz.Graph, []*gographviz.Graph, error)
-	CreateNaiveDiffProv(bool, []uint, *gographviz.Graph) ([]*gographviz.Graph, []*gographviz.Graph, [][]*fi.Missing, error)
+	PullProvGraphs() ([]*fi.ProvGraph, []*fi.ProvGraph, []*fi.ProvGraph, []*fi.ProvGraph, error)
+	CreateTableAbstraction([]*fi.ProvGraph, []*fi.ProvGraph, []uint) ([]*gographviz.Graph, []*gographviz.Graph, []*gographviz.Graph, []*gr.TableGraph, error)
+	CreateNaiveDiffProv(bool, []uint, *gographviz.Graph) ([]*gographviz.Graph, []*gographviz.Graph, []*fi.ProvGraph, [][]*fi.Missing, error)
+	CreateTemporalDiff([]*fi.ProvGraph, []*fi.ProvGraph, []*fi.Run, uint, []uint) ([]*gographviz.Graph, []*fi.Divergence, error)
 	GenerateCorrections() ([]string, error)
 	GenerateExtensions() (bool, []string, error)
 }
 
 // Reporter
 type Reporter interface {
-	Prepare(string, string, string) error
+	Prepare(string) error
 	GenerateFigure(string, *gographviz.Graph) error
 	GenerateFigures([]uint, string, []*gographviz.Graph) error
+	GenerateGraphs([]uint, string, []*fi.ProvGraph) error
+	Finalize([]*fi.Run) (string, error)
 }
 
 // Structs.
@@ -58,236 +67,528 @@ type DebugRun struct {
 	allResultsDir  string
 	thisResultsDir string
 	faultInj       FaultInjector
+	faultInjFS     fs.FS
 	graphDB        GraphDatabase
+	graphDBConn    string
+	goodRun        uint
+	topCauses      int
+	preTable       string
+	postTable      string
+	program        *dedalus.Program
+	patch          *dedalus.Program
 	reporter       Reporter
+	input          string
 }
 
-func main() {
+// pipelineFlags are the flags of all
+// subcommands running stages of the pipeline.
+type pipelineFlags struct {
+	faultInjOut *string
+	faultInj    *string
+	workers     *int
+	graphDBConn *string
+	renderer    *string
+	report      *string
+	program     *string
+	patch       *string
+	stages      *string
+	results     *string
+	config      *string
+	invariant   *string
+	passes      *string
+}
 
-	// Define which flags are supported.
-	faultInjOutFlag := flag.String("faultInjOut", "", "Specify file system path to output directory of fault injector.")
-	graphDBConnFlag := flag.String("graphDBConn", "bolt://127.0.0.1:7687", "Supply connection URI to dockerized graph database.")
-	flag.Parse()
+// loadConfig reads the config file at path or, if path
+// is empty, the first nemo.yaml, nemo.yml, or nemo.toml
+// found in dirs. Without any, it returns the defaults.
+func loadConfig(path string, dirs ...string) (*cf.Config, error) {
 
-	// Extract and check for existence of required ones.
-	faultInjOut := *faultInjOutFlag
-	if faultInjOut == "" {
-		log.Fatal("Please provide a fault injection output directory to analyze.")
+	if path == "" {
+		path = cf.Find(dirs...)
 	}
 
-	graphDBConn := *graphDBConnFlag
+	if path == "" {
+		return cf.Default(), nil
+	}
 
-	// Determine current working directory.
-	curDir, err := filepath.Abs(".")
+	cfg, err := cf.Load(path)
 	if err != nil {
-		log.Fatalf("Failed obtaining absolute current directory: %v", err)
+		return nil, err
 	}
 
-	// Start building structs.
-	debugRun := &DebugRun{
-		workDir:        curDir,
-		allResultsDir:  filepath.Join(curDir, "results"),
-		thisResultsDir: filepath.Join(curDir, "results", filepath.Base(faultInjOut)),
-		faultInj: &fi.Molly{
-			Run:       filepath.Base(faultInjOut),
+	fmt.Printf("Using settings from %s.\n", path)
+
+	return cfg, nil
+}
+
+// newGraphDB returns the graph database
+// configured as specified in cfg.
+func newGraphDB(cfg *cf.Config) GraphDatabase {
+
+	return &gr.Neo4J{
+		GoodRun:   cfg.GoodRun,
+		PreTable:  cfg.PreTable,
+		PostTable: cfg.PostTable,
+		Passes:    cfg.Passes,
+		Styles:    cfg.Styles,
+	}
+}
+
+// newFaultInjector returns the FaultInjector
+// reading output of the specified format, with
+// condition tables named as configured in cfg.
+func newFaultInjector(format string, faultInjOut string, faultInjFS fs.FS, workers int, cfg *cf.Config) (FaultInjector, error) {
+
+	switch format {
+	case "molly":
+		return &fi.Molly{
+			Run:       fi.OutputName(faultInjOut),
+			OutputDir: faultInjOut,
+			FS:        faultInjFS,
+			Workers:   workers,
+			PreTable:  cfg.PreTable,
+			PostTable: cfg.PostTable,
+		}, nil
+	case "generic":
+		return &fi.Generic{
+			Run:       fi.OutputName(faultInjOut),
 			OutputDir: faultInjOut,
-		},
-		graphDB:  &gr.Neo4J{},
-		reporter: &re.Report{},
+			FS:        faultInjFS,
+			PreTable:  cfg.PreTable,
+			PostTable: cfg.PostTable,
+		}, nil
 	}
 
-	// Ensure the results directory for this debug run exists.
-	err = os.MkdirAll(debugRun.allResultsDir, 0755)
+	return nil, fmt.Errorf("Unknown fault injector output format '%s', use 'molly' or 'generic'", format)
+}
+
+// validate checks the output of a fault injector
+// for problems without running any analysis.
+func validate(args []string) {
+
+	validateFlags := flag.NewFlagSet("validate", flag.ExitOnError)
+	faultInjOutFlag := validateFlags.String("faultInjOut", "", "Specify file system path to output directory or archive of fault injector.")
+	faultInjFlag := validateFlags.String("faultInj", "molly", "Specify format of fault injector output: 'molly' or 'generic'.")
+	configFlag := validateFlags.String("config", "", "Specify config file (default: nemo.yaml, nemo.yml, or nemo.toml in the current directory, if present).")
+	validateFlags.Parse(args)
+
+	faultInjOut := *faultInjOutFlag
+	if faultInjOut == "" {
+		log.Fatal("Please provide a fault injection output directory to validate.")
+	}
+
+	cfg, err := loadConfig(*configFlag, ".")
 	if err != nil {
-		log.Fatalf("Could not ensure resDir exists: %v", err)
+		log.Fatal(err)
 	}
 
-	// Extract, transform, and load fault injector output.
-	err = debugRun.faultInj.LoadOutput()
+	faultInjFS, closer, err := fi.OpenOutput(faultInjOut)
 	if err != nil {
-		log.Fatalf("Failed to load output from Molly: %v", err)
+		log.Fatalf("Failed to open fault injector output: %v", err)
 	}
+	defer closer.Close()
 
-	// Graph queries.
+	// Check the output for the tables of every invariant,
+	// reporting problems shared by several of them once.
+	problems := make([]*fi.Problem, 0)
+	seen := make(map[string]bool)
 
-	// Determine the IDs of all and all failed executions.
-	iters := debugRun.faultInj.GetRunsIters()
-	failedIters := debugRun.faultInj.GetFailedRunsIters()
+	for _, inv := range cfg.AllInvariants() {
 
-	// Connect to graph database docker container.
-	err = debugRun.graphDB.InitGraphDB(graphDBConn, debugRun.faultInj.GetOutput())
-	if err != nil {
-		log.Fatalf("Failed to initialize connection to graph database: %v", err)
+		faultInj, err := newFaultInjector(*faultInjFlag, faultInjOut, faultInjFS, 0, cfg.ForInvariant(inv))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, problem := range faultInj.Validate() {
+
+			if !seen[problem.String()] {
+				seen[problem.String()] = true
+				problems = append(problems, problem)
+			}
+		}
 	}
-	defer debugRun.graphDB.CloseDB()
 
-	// Load initial (naive) version of provenance
-	// graphs for antecedent and consequent.
-	err = debugRun.graphDB.LoadRawProvenance()
-	if err != nil {
-		log.Fatalf("Failed to import provenance (naive) into graph database: %v", err)
+	for i := range problems {
+		fmt.Println(problems[i])
 	}
 
-	// Clean-up loaded provenance data and
-	// re-import in reduced versions.
-	err = debugRun.graphDB.SimplifyProv(iters)
-	if err != nil {
-		log.Fatalf("Could not clean-up initial provenance data: %v", err)
+	if len(problems) > 0 {
+		fmt.Printf("\nFound %d problems in %s.\n", len(problems), faultInjOut)
+		closer.Close()
+		os.Exit(1)
 	}
 
-	// Create hazard analysis DOT figure.
-	hazardDots, err := debugRun.graphDB.CreateHazardAnalysis(faultInjOut)
-	if err != nil {
-		log.Fatalf("Failed to perform hazard analysis of simulation: %v", err)
+	fmt.Printf("No problems found in %s.\n", faultInjOut)
+}
+
+// convert writes the output of Molly in
+// the generic trace format of Nemo.
+func convert(args []string) {
+
+	convertFlags := flag.NewFlagSet("convert", flag.ExitOnError)
+	faultInjOutFlag := convertFlags.String("faultInjOut", "", "Specify file system path to output directory or archive of Molly.")
+	outFlag := convertFlags.String("out", "", "Specify directory to write trace.json and space-time diagrams to.")
+	convertFlags.Parse(args)
+
+	if (*faultInjOutFlag == "") || (*outFlag == "") {
+		log.Fatal("Please provide a Molly output directory and a target directory.")
 	}
 
-	// Extract prototypes of successful and
-	// failed runs (skeletons) and import.
-	interProto, interProtoMiss, unionProto, unionProtoMiss, err := debugRun.graphDB.CreatePrototypes(debugRun.faultInj.GetSuccessRunsIters(), debugRun.faultInj.GetFailedRunsIters())
+	faultInjFS, closer, err := fi.OpenOutput(*faultInjOutFlag)
 	if err != nil {
-		log.Fatalf("Failed to create prototypes of successful executions: %v", err)
+		log.Fatalf("Failed to open Molly output: %v", err)
 	}
+	defer closer.Close()
 
-	// Pull antecedent and consequent provenance
-	// and create DOT diagram strings.
-	preProvDots, postProvDots, preCleanProvDots, postCleanProvDots, err := debugRun.graphDB.PullPrePostProv()
-	if err != nil {
-		log.Fatalf("Failed to pull and generate antecedent and consequent provenance DOT: %v", err)
+	molly := &fi.Molly{
+		Run:       fi.OutputName(*faultInjOutFlag),
+		OutputDir: *faultInjOutFlag,
+		FS:        faultInjFS,
 	}
 
-	// Create differential provenance graphs for
-	// consequent provenance.
-	naiveDiffDots, naiveFailedDots, missingEvents, err := debugRun.graphDB.CreateNaiveDiffProv(false, debugRun.faultInj.GetFailedRunsIters(), postProvDots[0])
+	err = molly.ConvertToTrace(*outFlag)
 	if err != nil {
-		log.Fatalf("Could not create differential provenance between successful and failed provenance: %v", err)
+		log.Fatalf("Failed to convert Molly output: %v", err)
 	}
 
-	var corrections []string
-	if len(failedIters) > 0 {
+	fmt.Printf("Wrote trace to %s.\n", filepath.Join(*outFlag, "trace.json"))
+}
 
-		// Generate correction suggestions for moving towards correctness.
-		corrections, err = debugRun.graphDB.GenerateCorrections()
-		if err != nil {
-			log.Fatalf("Error while generating corrections: %v", err)
+// setFlags returns the names of all flags
+// set explicitly on the command line.
+func setFlags(flags *flag.FlagSet) map[string]bool {
+
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	return set
+}
+
+// addPipelineFlags defines the flags of a subcommand running
+// stages of the pipeline. Reporting flags are only defined
+// if the report stage may run, the stage selector only if
+// more than one stage may run.
+func addPipelineFlags(flags *flag.FlagSet, stages []string) *pipelineFlags {
+
+	pf := &pipelineFlags{
+		faultInjOut: flags.String("faultInjOut", "", "Specify file system path to output directory or archive (.tar.gz, .tgz, .zip) of fault injector."),
+		faultInj:    flags.String("faultInj", "molly", "Specify format of fault injector output: 'molly' or 'generic'."),
+		workers:     flags.Int("workers", 0, "Specify number of provenance files to decode concurrently (default: number of CPUs)."),
+		graphDBConn: flags.String("graphDBConn", "bolt://127.0.0.1:7687", "Supply connection URI to dockerized graph database."),
+		results:     flags.String("results", "results", "Specify directory to write results of all executions to."),
+		config:      flags.String("config", "", "Specify config file (default: nemo.yaml, nemo.yml, or nemo.toml next to the program or in the current directory, if present)."),
+		invariant:   flags.String("invariant", "", "Specify name of the configured invariant to run stages for (default: all of them, one after the other)."),
+	}
+
+	for _, stage := range stages {
+
+		if stage == "simplify" {
+
+			available := make([]string, 0, len(gr.AvailablePasses()))
+			for _, pass := range gr.AvailablePasses() {
+				available = append(available, pass.Name)
+			}
+
+			pf.passes = flags.String("passes", strings.Join(gr.DefaultPasses, ","), fmt.Sprintf("Specify comma-separated simplification passes to apply, in order, or 'none': %s.", strings.Join(available, ", ")))
+		}
+
+		if stage == "report" {
+			pf.renderer = flags.String("renderer", "builtin", "Specify how to render figures: 'builtin' or 'dot' (requires Graphviz).")
+			pf.report = flags.String("report", "html", "Specify comma-separated kinds of reports to write: 'html', 'markdown', 'text', 'sarif', 'junit'.")
+		}
+
+		if stage == "verify" {
+			pf.patch = flags.String("patch", "", "Optionally supply Dedalus rules (.ded) fixing the program to verify, replacing all rules for the tables they derive.")
 		}
 	}
 
-	// Attempt to create extension proposals in case
-	// the antecedent depends on network events.
-	allRunsAchievedPre, extensions, err := debugRun.graphDB.GenerateExtensions()
+	for _, stage := range stages {
+
+		if (stage == "report") || (stage == "verify") {
+			pf.program = flags.String("program", "", "Optionally supply the analyzed Dedalus program (.ded) for SARIF results to point at its rules and to verify corrections against.")
+			break
+		}
+	}
+
+	if len(stages) > 1 {
+		pf.stages = flags.String("stages", "", fmt.Sprintf("Specify comma-separated stages to run (default: all of them, with the analyses configured in the config file): %s.", strings.Join(stages, ", ")))
+	}
+
+	return pf
+}
+
+// runPipeline runs the selected stages of the pipeline
+// on the fault injector output given in pf. Stages pass
+// their results on through the results directory. Flags
+// set explicitly in flags take precedence over the config.
+func runPipeline(flags *flag.FlagSet, pf *pipelineFlags, stages []string) {
+
+	set := setFlags(flags)
+
+	// Config files are looked for next
+	// to the program and in the current directory.
+	dirs := []string{"."}
+	if (pf.program != nil) && (*pf.program != "") {
+		dirs = []string{filepath.Dir(*pf.program), "."}
+	}
+
+	cfg, err := loadConfig(*pf.config, dirs...)
 	if err != nil {
-		log.Fatalf("Error while generating extensions: %v", err)
+		log.Fatal(err)
+	}
+
+	if set["graphDBConn"] {
+		cfg.URI = *pf.graphDBConn
 	}
 
-	// Reporting.
+	if set["results"] {
+		cfg.ResultsDir = *pf.results
+	}
 
-	// Retrieve current state of run output.
-	// Enrich with missing events.
+	if (pf.passes != nil) && set["passes"] {
 
-	runs := debugRun.faultInj.GetOutput()
-	for i := range iters {
+		cfg.Passes, err = gr.ParsePasses(*pf.passes)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 
-		// Progressively formulate one top-level recommendation
-		// for programmers to focus on first.
-		if len(corrections) > 0 {
+	if pf.stages != nil {
 
-			// We observed an specification violation. Suggest corrections first.
-			runs[iters[i]].Recommendation = append(runs[iters[i]].Recommendation, "A fault occurred. Let's try making the protocol correct first.")
-			runs[iters[i]].Recommendation = append(runs[iters[i]].Recommendation, corrections...)
-		} else if len(extensions) > 0 {
+		raw := *pf.stages
 
-			// In case there exist runs in this execution where the
-			// antecedent was not achieved (not per se a problem!)
-			// and communication had to be performed for the successful
-			// run to establish the antecedent, it might be a good
-			// idea for the system designers to make sure these rules
-			// are maximum fault-tolerant.
-			runs[iters[i]].Recommendation = append(runs[iters[i]].Recommendation, "Good job, no specification violation. At least one run did not establish the antecedent, though. Maybe double-check the fault tolerance of the following rules:")
-			runs[iters[i]].Recommendation = append(runs[iters[i]].Recommendation, extensions...)
-		} else if !allRunsAchievedPre {
+		// Without selected stages, run the configured
+		// analyses and all stages that are no analyses.
+		if !set["stages"] && (cfg.Analyses != nil) {
 
-			// We saw a bug, but we don't find corrections or extensions
-			// to suggest. This must be a bug outside our capabilities
-			// (e.g., local-logic).
-			runs[iters[i]].Recommendation = append(runs[iters[i]].Recommendation, "Nemo can't help with this type of bug. Please use the graphs below regarding differential provenance for guidance to root cause.")
-		} else {
+			_, err := parseStages(strings.Join(cfg.Analyses, ","), analysisStages)
+			if err != nil {
+				log.Fatalf("Invalid analyses in %s: %v", cfg.File, err)
+			}
 
-			// No specification violation happened, no more fault tolerance to add.
-			runs[iters[i]].Recommendation = append(runs[iters[i]].Recommendation, "Well done! No faults, no missing fault tolerance.")
+			isAnalysis := make(map[string]bool, len(analysisStages))
+			for _, analysis := range analysisStages {
+				isAnalysis[analysis] = true
+			}
+
+			selected := make([]string, 0, len(stages))
+			for _, stage := range stages {
+
+				if !isAnalysis[stage] {
+					selected = append(selected, stage)
+				}
+			}
+
+			raw = strings.Join(append(selected, cfg.Analyses...), ",")
 		}
 
-		runs[iters[i]].InterProto = interProto
-		runs[iters[i]].UnionProto = unionProto
+		selected, err := parseStages(raw, stages)
+		if err != nil {
+			log.Fatal(err)
+		}
+		stages = selected
 	}
 
-	j := 0
-	for i := range failedIters {
-		runs[failedIters[i]].Corrections = corrections
-		runs[failedIters[i]].MissingEvents = missingEvents[j]
-		runs[failedIters[i]].InterProtoMissing = interProtoMiss[j]
-		runs[failedIters[i]].UnionProtoMissing = unionProtoMiss[j]
-		j++
+	graphDBConn, err := cfg.GraphDBConn()
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	// Marshal collected debugging information to JSON.
-	debuggingJSON, err := json.Marshal(runs)
-	if err != nil {
-		log.Fatalf("Failed to marshal debugging information to JSON: %v", err)
+	// Extract and check for existence of required ones.
+	faultInjOut := *pf.faultInjOut
+	if faultInjOut == "" {
+		log.Fatal("Please provide a fault injection output directory to analyze.")
 	}
 
-	// Prepare report webpage containing all insights and suggestions.
-	err = debugRun.reporter.Prepare(debugRun.workDir, debugRun.allResultsDir, debugRun.thisResultsDir)
+	// Determine current working directory.
+	curDir, err := filepath.Abs(".")
 	if err != nil {
-		log.Fatalf("Failed to prepare debugging report: %v", err)
+		log.Fatalf("Failed obtaining absolute current directory: %v", err)
 	}
 
-	// Write debugging JSON to file 'debugging.json'.
-	err = ioutil.WriteFile(filepath.Join(debugRun.thisResultsDir, "debugging.json"), debuggingJSON, 0644)
-	if err != nil {
-		log.Fatalf("Error writing out debugging.json: %v", err)
+	resultsDir := cfg.ResultsDir
+	if !filepath.IsAbs(resultsDir) {
+		resultsDir = filepath.Join(curDir, resultsDir)
 	}
 
-	// Generate and write-out hazard analysis figures.
-	err = debugRun.reporter.GenerateFigures(iters, "spacetime", hazardDots)
+	// Archives are read in place, without unpacking.
+	faultInjFS, closer, err := fi.OpenOutput(faultInjOut)
 	if err != nil {
-		log.Fatalf("Could not generate hazard analysis figures for report: %v", err)
+		log.Fatalf("Failed to open fault injector output: %v", err)
 	}
+	defer closer.Close()
 
-	// Generate and write-out antecedent provenance figures.
-	err = debugRun.reporter.GenerateFigures(iters, "pre_prov", preProvDots)
+	invs, err := selectInvariants(cfg, *pf.invariant)
 	if err != nil {
-		log.Fatalf("Could not generate antecedent provenance figures for report: %v", err)
+		log.Fatal(err)
 	}
 
-	// Generate and write-out consequent provenance figures.
-	err = debugRun.reporter.GenerateFigures(iters, "post_prov", postProvDots)
-	if err != nil {
-		log.Fatalf("Could not generate consequent provenance figures for report: %v", err)
+	// The graph database holds the provenance of one
+	// invariant at a time, as loaded by stage load.
+	usesDB := false
+	for _, stage := range stages {
+		usesDB = usesDB || !offlineStages[stage]
 	}
 
-	// Generate and write-out cleaned-up antecedent provenance figures.
-	err = debugRun.reporter.GenerateFigures(iters, "pre_prov_clean", preCleanProvDots)
-	if err != nil {
-		log.Fatalf("Could not generate cleaned-up antecedent provenance figures for report: %v", err)
+	if (len(invs) > 1) && (stages[0] != "load") && usesDB {
+		log.Fatalf("Stages %s need the provenance loaded for one invariant, please select it with -invariant.", strings.Join(stages, ", "))
 	}
 
-	// Generate and write-out cleaned-up consequent provenance figures.
-	err = debugRun.reporter.GenerateFigures(iters, "post_prov_clean", postCleanProvDots)
-	if err != nil {
-		log.Fatalf("Could not generate cleaned-up consequent provenance figures for report: %v", err)
+	if pf.report != nil {
+
+		if (*pf.renderer != "builtin") && (*pf.renderer != "dot") {
+			log.Fatalf("Unknown renderer '%s', choose 'builtin' or 'dot'.", *pf.renderer)
+		}
 	}
 
-	// Generate and write-out naive differential provenance (diff) figures.
-	err = debugRun.reporter.GenerateFigures(failedIters, "diff_post_prov-diff", naiveDiffDots)
-	if err != nil {
-		log.Fatalf("Could not generate naive differential provenance (diff) figures for report: %v", err)
+	var program *dedalus.Program
+	if (pf.program != nil) && (*pf.program != "") {
+
+		program, err = dedalus.ParseFile(*pf.program)
+		if err != nil {
+			log.Fatalf("Failed to parse Dedalus program: %v", err)
+		}
+	}
+
+	var patch *dedalus.Program
+	if (pf.patch != nil) && (*pf.patch != "") {
+
+		patch, err = dedalus.ParseFile(*pf.patch)
+		if err != nil {
+			log.Fatalf("Failed to parse patch: %v", err)
+		}
 	}
 
-	// Generate and write-out naive differential provenance (failed) figures.
-	err = debugRun.reporter.GenerateFigures(failedIters, "diff_post_prov-failed", naiveFailedDots)
+	execName := fi.OutputName(faultInjOut)
+	execDir := filepath.Join(resultsDir, execName)
+
+	outcomes, err := runInvariants(invs, cfg, stages, execDir, func(invCfg *cf.Config, dir string, inv *cf.Invariant) (*DebugRun, error) {
+
+		faultInj, err := newFaultInjector(*pf.faultInj, faultInjOut, faultInjFS, *pf.workers, invCfg)
+		if err != nil {
+			return nil, err
+		}
+
+		// Start building structs.
+		debugRun := &DebugRun{
+			workDir:        curDir,
+			allResultsDir:  resultsDir,
+			thisResultsDir: dir,
+			faultInj:       faultInj,
+			faultInjFS:     faultInjFS,
+			graphDB:        newGraphDB(invCfg),
+			graphDBConn:    graphDBConn,
+			goodRun:        invCfg.GoodRun,
+			topCauses:      invCfg.TopCauses,
+			preTable:       invCfg.PreTable,
+			postTable:      invCfg.PostTable,
+			program:        program,
+			patch:          patch,
+		}
+
+		if pf.report != nil {
+
+			name := execName
+			if inv.Name != "" {
+				name = fmt.Sprintf("%s/%s", execName, inv.Name)
+			}
+
+			debugRun.reporter, err = newReporter(*pf.report, *pf.renderer, program, invCfg.PostTable, name)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return debugRun, nil
+	})
 	if err != nil {
-		log.Fatalf("Could not generate naive differential provenance (failed) figures for report: %v", err)
+		log.Fatal(err)
+	}
+
+	if outcomes[0].reportPath == "" {
+
+		for _, o := range outcomes {
+			fmt.Printf("Done with stages %s. Results are stored in: %s\n", strings.Join(stages, ", "), filepath.Join(invariantDir(execDir, o.invariant), "stages"))
+		}
+	} else {
+
+		reportPath := outcomes[0].reportPath
+		if len(outcomes) > 1 {
+
+			reportPath, err = writeInvariantsIndex(execDir, outcomes)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			for _, o := range outcomes {
+				fmt.Printf("Invariant '%s': %d of %d runs violated it, report: %s\n", o.invariant.Name, o.failed(), len(o.runs), o.reportPath)
+			}
+			fmt.Println()
+		}
+
+		fmt.Printf("All done! Find the debug report here: %s\n\n", reportPath)
 	}
 
-	fmt.Printf("All done! Find the debug report here: %s\n\n", filepath.Join(debugRun.thisResultsDir, "index.html"))
+	// Signal a specification violation to callers, e.g.,
+	// CI, whichever stages ran. Deferred calls do not
+	// run on exit.
+	if violated(outcomes) {
+		closer.Close()
+		os.Exit(2)
+	}
+}
+
+func main() {
+
+	// Subcommands are selected by the first argument.
+	if len(os.Args) > 1 {
+
+		// Subcommands running stages of the pipeline.
+		pipelineStages := map[string][]string{
+			"load":     {"load"},
+			"simplify": {"simplify"},
+			"analyze":  analysisStages,
+			"report":   {"report"},
+		}
+
+		if stages, found := pipelineStages[os.Args[1]]; found {
+
+			flags := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+			pf := addPipelineFlags(flags, stages)
+			flags.Parse(os.Args[2:])
+
+			runPipeline(flags, pf, stages)
+			return
+		}
+
+		switch os.Args[1] {
+		case "validate":
+			validate(os.Args[2:])
+			return
+		case "convert":
+			convert(os.Args[2:])
+			return
+		case "serve":
+			serve(os.Args[2:])
+			return
+		case "query":
+			query(os.Args[2:])
+			return
+		case "simulate":
+			simulate(os.Args[2:])
+			return
+		case "ldfi":
+			ldfi(os.Args[2:])
+			return
+		}
+	}
+
+	// Without a subcommand, all stages run.
+	pf := addPipelineFlags(flag.CommandLine, allStages)
+	flag.Parse()
+
+	runPipeline(flag.CommandLine, pf, allStages)
 }
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"path/filepath"
+
+	neo4j "github.com/johnnadratowski/golang-neo4j-bolt-driver"
+	cf "github.com/numbleroot/nemo/config"
+	fi "github.com/numbleroot/nemo/faultinjectors"
+	gr "github.com/numbleroot/nemo/graphing"
+)
+
+// Structs.
+
+// neo4jTestDB runs the queries of gr.Neo4J against an
+// already running Neo4j instance, without starting or
+// stopping docker containers around it.
+type neo4jTestDB struct {
+	*gr.Neo4J
+}
+
+// Functions.
+
+// InitGraphDB connects to the instance at boltURI.
+func (n *neo4jTestDB) InitGraphDB(boltURI string, runs []*fi.Run) error {
+
+	driver := neo4j.NewDriver()
+
+	c1, err := driver.OpenNeo(boltURI)
+	if err != nil {
+		return err
+	}
+
+	c2, err := driver.OpenNeo(boltURI)
+	if err != nil {
+		c1.Close()
+		return err
+	}
+
+	n.Conn1 = c1
+	n.Conn2 = c2
+	n.Runs = runs
+
+	return nil
+}
+
+// CloseDB closes both connections, leaving the instance running.
+func (n *neo4jTestDB) CloseDB() error {
+
+	err := n.Conn1.Close()
+	if err != nil {
+		return err
+	}
+
+	return n.Conn2.Close()
+}
+
+// TestPipelineGoldenNeo4J runs the pipeline on the fixture of
+// every case study against the Neo4j instance at the bolt URI
+// in NEMO_NEO4J_URI and compares the results to the same
+// golden files as TestPipelineGolden. This covers the Cypher
+// queries the fake graph backend only mirrors. Nodes of
+// other applications in that instance are left alone.
+func TestPipelineGoldenNeo4J(t *testing.T) {
+
+	uri := os.Getenv("NEMO_NEO4J_URI")
+	if uri == "" {
+		t.Skip("Set NEMO_NEO4J_URI to the bolt URI of a running Neo4j instance to run this test")
+	}
+
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "case-studies", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := cf.Default()
+
+	for _, fixture := range fixtures {
+
+		name := filepath.Base(fixture)
+
+		t.Run(name, func(t *testing.T) {
+
+			debugRun := newTestRun(t, fixture)
+			debugRun.graphDBConn = uri
+			debugRun.graphDB = &neo4jTestDB{
+				Neo4J: &gr.Neo4J{
+					GoodRun:   cfg.GoodRun,
+					PreTable:  cfg.PreTable,
+					PostTable: cfg.PostTable,
+				},
+			}
+
+			_, _, err := debugRun.runStages(testStages)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			compareGolden(t, name, debugRun.thisResultsDir, false)
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"io/ioutil"
+	"path/filepath"
+
+	cf "github.com/numbleroot/nemo/config"
+	"github.com/numbleroot/nemo/dedalus"
+	gr "github.com/numbleroot/nemo/graphing"
+)
+
+// update regenerates the golden files instead of comparing against them.
+var update = flag.Bool("update", false, "Regenerate golden files in testdata/golden.")
+
+// testStages are the stages the fake graph backend supports.
+// Prototypes are left out, they are built by Cypher queries
+// only, without an in-memory counterpart to compare against.
+var testStages = []string{"load", "simplify", "hazard", "provenance", "tables", "diff", "divergence", "ranking", "corrections", "verify", "extensions", "report"}
+
+// Functions.
+
+// goldenFiles returns the files of a debugging report
+// compared against golden files, relative to dir. The
+// provenance figures only draw what the fake graph
+// backend holds and are left out.
+func goldenFiles(t *testing.T, dir string) []string {
+
+	files := []string{"debugging.json", "report.md"}
+
+	dots, err := filepath.Glob(filepath.Join(dir, "figures", "*.dot"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dot := range dots {
+
+		if strings.HasSuffix(dot, "_prov.dot") || strings.HasSuffix(dot, "_prov_clean.dot") {
+			continue
+		}
+
+		files = append(files, filepath.Join("figures", filepath.Base(dot)))
+	}
+
+	sort.Strings(files)
+
+	return files
+}
+
+// newTestRun returns a debug run analyzing fixture with
+// the fake graph backend, writing its results to a
+// temporary directory.
+func newTestRun(t *testing.T, fixture string) *DebugRun {
+
+	name := filepath.Base(fixture)
+	program := filepath.Join("case-studies", fmt.Sprintf("%s.ded", name))
+
+	src, err := ioutil.ReadFile(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prog, err := dedalus.Parse(program, string(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := cf.Default()
+	faultInjFS := os.DirFS(fixture)
+
+	faultInj, err := newFaultInjector("molly", fixture, faultInjFS, 1, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reporter, err := newReporter("html,markdown", "builtin", prog, cfg.PostTable, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := t.TempDir()
+
+	return &DebugRun{
+		allResultsDir:  results,
+		thisResultsDir: filepath.Join(results, name),
+		faultInj:       faultInj,
+		faultInjFS:     faultInjFS,
+		graphDB: newFakeGraphDB(&gr.Neo4J{
+			GoodRun:   cfg.GoodRun,
+			PreTable:  cfg.PreTable,
+			PostTable: cfg.PostTable,
+		}),
+		goodRun:   cfg.GoodRun,
+		topCauses: 5,
+		preTable:  cfg.PreTable,
+		postTable: cfg.PostTable,
+		program:   prog,
+		reporter:  reporter,
+	}
+}
+
+// compareGolden compares the report in results against
+// the golden files of case study name, or regenerates
+// the golden files if update is set.
+func compareGolden(t *testing.T, name string, results string, update bool) {
+
+	golden := filepath.Join("testdata", "golden", name)
+	files := goldenFiles(t, results)
+
+	if update {
+
+		err := os.RemoveAll(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, file := range files {
+
+		got, err := ioutil.ReadFile(filepath.Join(results, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if update {
+
+			err = os.MkdirAll(filepath.Dir(filepath.Join(golden, file)), 0755)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = ioutil.WriteFile(filepath.Join(golden, file), got, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			continue
+		}
+
+		want, err := ioutil.ReadFile(filepath.Join(golden, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s differs from golden file, rerun with -update if intended", file)
+		}
+	}
+
+	if wantFiles := goldenFiles(t, golden); len(wantFiles) != len(files) {
+		t.Errorf("Expected files %v, golden files are %v", wantFiles, files)
+	}
+}
+
+// TestPipelineGolden runs the pipeline on the fixture of every
+// case study and compares recommendations, missing events,
+// and figures to the golden files in testdata/golden.
+func TestPipelineGolden(t *testing.T) {
+
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "case-studies", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fixtures) == 0 {
+		t.Fatal("No fixtures found, run 'go test ./simulator -update' first")
+	}
+
+	for _, fixture := range fixtures {
+
+		name := filepath.Base(fixture)
+
+		t.Run(name, func(t *testing.T) {
+
+			debugRun := newTestRun(t, fixture)
+
+			_, _, err := debugRun.runStages(testStages)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			compareGolden(t, name, debugRun.thisResultsDir, *update)
+		})
+	}
+}
+
+// TestLoadOnlyNeededRuns checks that stages comparing failed
+// runs against the good run leave the provenance of all
+// other runs unread.
+func TestLoadOnlyNeededRuns(t *testing.T) {
+
+	debugRun := newTestRun(t, filepath.Join("testdata", "case-studies", "pb_asynchronous"))
+
+	runs, _, err := debugRun.runStages([]string{"load", "simplify", "corrections"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	needed := map[uint]bool{debugRun.goodRun: true}
+	for _, iter := range debugRun.faultInj.GetFailedRunsIters() {
+		needed[iter] = true
+	}
+
+	if len(needed) == len(runs) {
+		t.Fatal("Fixture needs a successful run other than the good run")
+	}
+
+	for _, run := range runs {
+
+		if loaded := (run.PreProv != nil); loaded != needed[run.Iteration] {
+			t.Errorf("Run %d: expected provenance loaded to be %v, was %v", run.Iteration, needed[run.Iteration], loaded)
+		}
+	}
+
+	// Stages reading all runs refuse to work on a subset.
+	_, _, err = debugRun.runStages([]string{"provenance"})
+	if err == nil {
+		t.Error("Expected stage provenance to fail on provenance of some runs")
+	}
+}
+
+// TestRestoreOnlySameInput checks that stages do not pick
+// up results an earlier invocation stored for another
+// fault injector output.
+func TestRestoreOnlySameInput(t *testing.T) {
+
+	debugRun := newTestRun(t, filepath.Join("testdata", "case-studies", "pb_asynchronous"))
+
+	_, _, err := debugRun.runStages([]string{"load", "simplify", "provenance"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = debugRun.runStages([]string{"tables"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := newTestRun(t, filepath.Join("testdata", "case-studies", "ZK-1270-racing-sent-flag"))
+	other.allResultsDir = debugRun.allResultsDir
+	other.thisResultsDir = debugRun.thisResultsDir
+
+	_, _, err = other.runStages([]string{"tables"})
+	if err == nil {
+		t.Error("Expected stage tables to fail on provenance of another output")
+	}
+}
+
+// TestQueryDiffsAgainstGoodRun checks that the provenance
+// graphs to query hold differential provenance relative
+// to the configured good run.
+func TestQueryDiffsAgainstGoodRun(t *testing.T) {
+
+	debugRun := newTestRun(t, filepath.Join("testdata", "case-studies", "pb_asynchronous"))
+	debugRun.goodRun = 3
+	debugRun.graphDB.(*fakeGraphDB).neo.GoodRun = 3
+
+	_, _, err := debugRun.runStages([]string{"load"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	graphs, err := debugRun.pullAllProvGraphs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diffs := 0
+	for _, graph := range graphs {
+
+		if graph.Variant == "diff" {
+			diffs++
+		}
+	}
+
+	if diffs != len(debugRun.faultInj.GetFailedRunsIters()) {
+		t.Errorf("Expected differential provenance of %d failed runs, got %d", len(debugRun.faultInj.GetFailedRunsIters()), diffs)
+	}
+}
+
+// TestSkipVerifyWithoutProgram checks that the pipeline
+// runs without the Dedalus program, leaving out stage
+// verify.
+func TestSkipVerifyWithoutProgram(t *testing.T) {
+
+	debugRun := newTestRun(t, filepath.Join("testdata", "case-studies", "pb_asynchronous"))
+	debugRun.program = nil
+
+	_, _, err := debugRun.runStages(testStages)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = os.Stat(filepath.Join(debugRun.stagesDir(), "verify.json"))
+	if !os.IsNotExist(err) {
+		t.Errorf("Expected no result of stage verify, got: %v", err)
+	}
+}
+
+// TestReportNamesGoodRun checks that failed runs name the
+// good run their differential provenance compares against,
+// for the report to show its consequent provenance.
+func TestReportNamesGoodRun(t *testing.T) {
+
+	debugRun := newTestRun(t, filepath.Join("testdata", "case-studies", "pb_asynchronous"))
+	debugRun.goodRun = 3
+	debugRun.graphDB.(*fakeGraphDB).neo.GoodRun = 3
+
+	runs, _, err := debugRun.runStages([]string{"load", "provenance", "diff", "report"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, iter := range debugRun.faultInj.GetFailedRunsIters() {
+
+		if runs[iter].GoodRun != 3 {
+			t.Errorf("Run %d: expected good run 3, got %d", iter, runs[iter].GoodRun)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"path/filepath"
+
+	"github.com/numbleroot/nemo/dedalus"
+	fi "github.com/numbleroot/nemo/faultinjectors"
+	qu "github.com/numbleroot/nemo/query"
+)
+
+// Functions.
+
+// pullAllProvGraphs returns raw, cleaned-up, and
+// differential provenance graphs of all runs.
+func (debugRun *DebugRun) pullAllProvGraphs() ([]*fi.ProvGraph, error) {
+
+	pre, post, preClean, postClean, err := debugRun.graphDB.PullProvGraphs()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to pull antecedent and consequent provenance graphs: %v", err)
+	}
+
+	graphs := make([]*fi.ProvGraph, 0, (4 * len(pre)))
+	graphs = append(graphs, pre...)
+	graphs = append(graphs, post...)
+	graphs = append(graphs, preClean...)
+	graphs = append(graphs, postClean...)
+
+	failedIters := debugRun.faultInj.GetFailedRunsIters()
+	if len(failedIters) == 0 {
+		return graphs, nil
+	}
+
+	err = debugRun.checkGoodRun()
+	if err != nil {
+		return nil, err
+	}
+
+	// Differential provenance is created relative
+	// to the consequent provenance of the good run.
+	_, postProvDots, _, _, err := debugRun.graphDB.PullPrePostProv()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to pull consequent provenance: %v", err)
+	}
+
+	i := debugRun.goodRunIndex()
+	if (i >= len(postProvDots)) || (postProvDots[i] == nil) {
+		return nil, fmt.Errorf("Missing consequent provenance of good run %d", debugRun.goodRun)
+	}
+
+	_, _, diffGraphs, _, err := debugRun.graphDB.CreateNaiveDiffProv(false, failedIters, postProvDots[i])
+	if err != nil {
+		return nil, fmt.Errorf("Could not create differential provenance: %v", err)
+	}
+
+	return append(graphs, diffGraphs...), nil
+}
+
+// query answers questions about the provenance of
+// fault injector output in the query language of
+// package query, interactively or for one query.
+func query(args []string) {
+
+	queryFlags := flag.NewFlagSet("query", flag.ExitOnError)
+	faultInjOutFlag := queryFlags.String("faultInjOut", "", "Specify file system path to output directory or archive of fault injector.")
+	faultInjFlag := queryFlags.String("faultInj", "molly", "Specify format of fault injector output: 'molly' or 'generic'.")
+	graphDBConnFlag := queryFlags.String("graphDBConn", "bolt://127.0.0.1:7687", "Supply connection URI to dockerized graph database.")
+	programFlag := queryFlags.String("program", "", "Optionally specify the Dedalus program the fault injector ran, letting whynot explain absent facts by its rules.")
+	execFlag := queryFlags.String("e", "", "Answer this query and exit instead of starting an interactive session.")
+	configFlag := queryFlags.String("config", "", "Specify config file (default: nemo.yaml, nemo.yml, or nemo.toml next to the program or in the current directory, if present).")
+	invariantFlag := queryFlags.String("invariant", "", "Specify name of the configured invariant whose provenance to query (default: the first one).")
+	queryFlags.Parse(args)
+
+	faultInjOut := *faultInjOutFlag
+	if faultInjOut == "" {
+		log.Fatal("Please provide a fault injection output directory to query.")
+	}
+
+	dirs := []string{"."}
+	if *programFlag != "" {
+		dirs = []string{filepath.Dir(*programFlag), "."}
+	}
+
+	cfg, err := loadConfig(*configFlag, dirs...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if setFlags(queryFlags)["graphDBConn"] {
+		cfg.URI = *graphDBConnFlag
+	}
+
+	graphDBConn, err := cfg.GraphDBConn()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	invs, err := selectInvariants(cfg, *invariantFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg = cfg.ForInvariant(invs[0])
+
+	faultInjFS, closer, err := fi.OpenOutput(faultInjOut)
+	if err != nil {
+		log.Fatalf("Failed to open fault injector output: %v", err)
+	}
+	defer closer.Close()
+
+	faultInj, err := newFaultInjector(*faultInjFlag, faultInjOut, faultInjFS, 0, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	debugRun := &DebugRun{
+		faultInj:    faultInj,
+		faultInjFS:  faultInjFS,
+		graphDB:     newGraphDB(cfg),
+		graphDBConn: graphDBConn,
+		goodRun:     cfg.GoodRun,
+		preTable:    cfg.PreTable,
+		postTable:   cfg.PostTable,
+	}
+
+	err = debugRun.load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer debugRun.graphDB.CloseDB()
+
+	graphs, err := debugRun.pullAllProvGraphs()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	engine := qu.New(graphs)
+
+	if *programFlag != "" {
+
+		prog, err := dedalus.ParseFile(*programFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		engine.UseProgram(prog, faultInj.GetOutput())
+	}
+
+	if *execFlag != "" {
+
+		out, err := engine.Exec(*execFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Print(out)
+		return
+	}
+
+	fmt.Printf("Loaded provenance of %d runs. Type 'help' for all commands, 'quit' to leave.\n", len(faultInj.GetRunsIters()))
+
+	scanner := bufio.NewScanner(os.Stdin)
+
+	for {
+
+		fmt.Print("nemo> ")
+		if !scanner.Scan() {
+			fmt.Println()
+			return
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+
+		switch line {
+		case "":
+			continue
+		case "help":
+			fmt.Println(qu.Help)
+			continue
+		case "quit", "exit":
+			return
+		}
+
+		out, err := engine.Exec(line)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			continue
+		}
+
+		fmt.Print(out)
+	}
+}
@@ -0,0 +1,54 @@
+package query
+
+import (
+	"github.com/numbleroot/nemo/dedalus"
+	fi "github.com/numbleroot/nemo/faultinjectors"
+)
+
+// Structs.
+
+// Pattern selects provenance goals by table and,
+// optionally, by arguments of their label, where
+// '_' matches any argument, and by time, e.g., @5.
+type Pattern struct {
+	Table string
+	Args  []string
+	Time  string
+}
+
+// Cond restricts the nodes a query considers, e.g.,
+// run=7 or time<3. Keys are run, condition, variant,
+// kind, table, type, label, and time.
+type Cond struct {
+	Key   string
+	Op    string
+	Value string
+}
+
+// Query is one parsed statement of the query language.
+// From is the subject of all commands taking a pattern,
+// To is only set for paths.
+type Query struct {
+	Command string
+	From    *Pattern
+	To      *Pattern
+	Conds   []*Cond
+}
+
+// graphIndex allows walking one provenance graph
+// towards causes (edge direction) and effects.
+type graphIndex struct {
+	graph   *fi.ProvGraph
+	nodes   map[string]*fi.ProvNode
+	causes  map[string][]string
+	effects map[string][]string
+}
+
+// Engine answers queries over the provenance
+// graphs of all runs of one fault injection and,
+// if available, the Dedalus program behind them.
+type Engine struct {
+	graphs  []*graphIndex
+	program *dedalus.Program
+	runs    map[uint]*fi.Run
+}
@@ -0,0 +1,591 @@
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	fi "github.com/numbleroot/nemo/faultinjectors"
+)
+
+// Help describes the query language.
+const Help = `Commands:
+  goals [where ...]                   List goals (derived facts).
+  rules [where ...]                   List rule firings.
+  runs [where ...]                    List runs containing matching goals or rules.
+  why <pattern> [where ...]           Show how matching goals were derived.
+  whynot <pattern> where run=N ...    Explain why no matching goal was derived in run N:
+                                      with a program, by the rules that could have derived
+                                      it, down to dropped messages and crashed nodes;
+                                      otherwise by a run in which one was derived.
+  ancestors <pattern> [where ...]     List everything matching goals depend on.
+  descendants <pattern> [where ...]   List everything that depends on matching goals.
+  paths <pattern> -> <pattern> [...]  List all derivation paths from the first to the second.
+
+Patterns select goals by table, optionally with arguments, '_' matching any,
+and a time: log, log(b, _), post(foo)@5. Without a time, whynot with a program
+explains the end of time.
+Conditions are joined by 'and' and compare run, condition (pre, post), variant
+(raw, clean, diff), kind (goal, rule), table, type, label, or time using
+=, !=, <, >, <=, >=. Without a variant, raw provenance is queried.
+
+Examples:
+  runs where table=acked and time<3
+  paths begin -> post where run=7
+  whynot post where run=1
+  whynot post(a)@4 where run=1`
+
+// maxPaths bounds the number of paths listed per graph.
+const maxPaths = 50
+
+// Functions.
+
+// New returns an engine over the given provenance
+// graphs, e.g., as pulled from the graph database.
+func New(graphs []*fi.ProvGraph) *Engine {
+
+	e := &Engine{graphs: make([]*graphIndex, 0, len(graphs))}
+
+	for _, g := range graphs {
+
+		if g == nil {
+			continue
+		}
+
+		gi := &graphIndex{
+			graph:   g,
+			nodes:   make(map[string]*fi.ProvNode, len(g.Nodes)),
+			causes:  make(map[string][]string),
+			effects: make(map[string][]string),
+		}
+
+		for _, node := range g.Nodes {
+			gi.nodes[node.ID] = node
+		}
+
+		for _, edge := range g.Edges {
+			gi.causes[edge.From] = append(gi.causes[edge.From], edge.To)
+			gi.effects[edge.To] = append(gi.effects[edge.To], edge.From)
+		}
+
+		e.graphs = append(e.graphs, gi)
+	}
+
+	// Answer in order of runs.
+	sort.SliceStable(e.graphs, func(i, j int) bool {
+		return e.graphs[i].graph.Iteration < e.graphs[j].graph.Iteration
+	})
+
+	return e
+}
+
+// compare applies op to a and b, numerically
+// if both are numbers, else lexicographically.
+func compare(a string, op string, b string) bool {
+
+	cmp := strings.Compare(a, b)
+
+	x, errA := strconv.Atoi(a)
+	y, errB := strconv.Atoi(b)
+	if (errA == nil) && (errB == nil) {
+
+		cmp = 0
+		if x < y {
+			cmp = -1
+		} else if x > y {
+			cmp = 1
+		}
+	} else if (op != "=") && (op != "!=") && (a == "") {
+
+		// Ordering needs a value, e.g., rules have no time.
+		return false
+	}
+
+	switch op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case ">":
+		return cmp > 0
+	case "<=":
+		return cmp <= 0
+	}
+
+	return cmp >= 0
+}
+
+// isGraphCond reports whether c restricts whole
+// graphs rather than single nodes.
+func (c *Cond) isGraphCond() bool {
+	return (c.Key == "run") || (c.Key == "condition") || (c.Key == "variant")
+}
+
+// holds reports whether c is true for node of g.
+func (c *Cond) holds(g *fi.ProvGraph, node *fi.ProvNode) bool {
+
+	value := ""
+
+	switch c.Key {
+	case "run":
+		value = fmt.Sprintf("%d", g.Iteration)
+	case "condition":
+		value = g.Condition
+	case "variant":
+		value = g.Variant
+	case "kind":
+		value = node.Kind
+	case "table":
+		value = node.Table
+	case "type":
+		value = node.Type
+	case "label":
+		value = node.Label
+	case "time":
+		value = node.Time
+	}
+
+	return compare(value, c.Op, c.Value)
+}
+
+// labelArgs returns the arguments of a goal label,
+// e.g., [b foo 2] for log(b, foo, 2).
+func labelArgs(label string) []string {
+
+	open := strings.Index(label, "(")
+	if (open < 0) || !strings.HasSuffix(label, ")") {
+		return nil
+	}
+
+	args := strings.Split(label[(open+1):(len(label)-1)], ",")
+	for i := range args {
+		args[i] = strings.TrimSpace(args[i])
+	}
+
+	return args
+}
+
+// matches reports whether goal node is selected by p. Goal
+// labels end in their time, which patterns may omit.
+func (p *Pattern) matches(node *fi.ProvNode) bool {
+
+	if (node.Kind != "goal") || (node.Table != p.Table) {
+		return false
+	}
+
+	if (p.Time != "") && (p.Time != node.Time) {
+		return false
+	}
+
+	if len(p.Args) == 0 {
+		return true
+	}
+
+	args := labelArgs(node.Label)
+	if (len(args) != len(p.Args)) && (len(args) != (len(p.Args) + 1)) {
+		return false
+	}
+
+	for i := range p.Args {
+
+		if (p.Args[i] != "_") && (p.Args[i] != args[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// selectGraphs returns the graphs satisfying all graph-level
+// conditions, the raw ones unless a variant is given.
+// If skipRun is set, conditions on the run are ignored.
+func (e *Engine) selectGraphs(conds []*Cond, skipRun bool) []*graphIndex {
+
+	hasVariant := false
+	for _, c := range conds {
+		hasVariant = hasVariant || (c.Key == "variant")
+	}
+
+	selected := make([]*graphIndex, 0, len(e.graphs))
+
+	for _, gi := range e.graphs {
+
+		if !hasVariant && (gi.graph.Variant != "raw") {
+			continue
+		}
+
+		keep := true
+		for _, c := range conds {
+
+			if c.isGraphCond() && !(skipRun && (c.Key == "run")) {
+				keep = keep && c.holds(gi.graph, nil)
+			}
+		}
+
+		if keep {
+			selected = append(selected, gi)
+		}
+	}
+
+	return selected
+}
+
+// matching returns the IDs of all nodes of gi that
+// match p (if given) and satisfy all node conditions.
+func (gi *graphIndex) matching(p *Pattern, conds []*Cond) []string {
+
+	ids := make([]string, 0, 4)
+
+	for _, node := range gi.graph.Nodes {
+
+		if (p != nil) && !p.matches(node) {
+			continue
+		}
+
+		keep := true
+		for _, c := range conds {
+
+			if !c.isGraphCond() {
+				keep = keep && c.holds(gi.graph, node)
+			}
+		}
+
+		if keep {
+			ids = append(ids, node.ID)
+		}
+	}
+
+	return ids
+}
+
+// describe returns the short form of a node.
+func describe(node *fi.ProvNode) string {
+
+	if node.Kind == "rule" {
+
+		if node.Type != "" {
+			return fmt.Sprintf("rule %s [%s]", node.Label, node.Type)
+		}
+
+		return fmt.Sprintf("rule %s", node.Label)
+	}
+
+	return node.Label
+}
+
+// header names the graph of gi in answers.
+func (gi *graphIndex) header() string {
+	return fmt.Sprintf("run %d, %s, %s", gi.graph.Iteration, gi.graph.Condition, gi.graph.Variant)
+}
+
+// closure returns all nodes reachable from start
+// via next, in breadth-first order.
+func (gi *graphIndex) closure(start []string, next map[string][]string) []string {
+
+	seen := make(map[string]bool)
+	queue := append([]string{}, start...)
+	reached := make([]string, 0, len(gi.nodes))
+
+	for _, id := range start {
+		seen[id] = true
+	}
+
+	for len(queue) > 0 {
+
+		id := queue[0]
+		queue = queue[1:]
+
+		for _, n := range next[id] {
+
+			if !seen[n] {
+				seen[n] = true
+				reached = append(reached, n)
+				queue = append(queue, n)
+			}
+		}
+	}
+
+	return reached
+}
+
+// tree writes the derivation of id, indented by depth.
+// Nodes already written are referenced, not repeated.
+// mark annotates goals and decides whether to descend.
+func (gi *graphIndex) tree(b *strings.Builder, id string, depth int, seen map[string]bool, mark func(*fi.ProvNode) (string, bool)) {
+
+	node := gi.nodes[id]
+	indent := strings.Repeat("  ", depth)
+
+	suffix, descend := "", true
+	if mark != nil {
+		suffix, descend = mark(node)
+	}
+
+	prefix := ""
+	if node.Kind == "rule" {
+		prefix = "<- "
+	}
+
+	if seen[id] {
+		fmt.Fprintf(b, "%s%s%s%s (see above)\n", indent, prefix, describe(node), suffix)
+		return
+	}
+	seen[id] = true
+
+	fmt.Fprintf(b, "%s%s%s%s\n", indent, prefix, describe(node), suffix)
+
+	if !descend {
+		return
+	}
+
+	for _, cause := range gi.causes[id] {
+		gi.tree(b, cause, (depth + 1), seen, mark)
+	}
+}
+
+// paths writes all simple paths along effects from
+// id to any node in targets, at most maxPaths.
+func (gi *graphIndex) paths(b *strings.Builder, id string, targets map[string]bool, path []string, onPath map[string]bool, found *int) {
+
+	if *found >= maxPaths {
+		return
+	}
+
+	path = append(path, describe(gi.nodes[id]))
+	onPath[id] = true
+	defer delete(onPath, id)
+
+	if targets[id] && (len(path) > 1) {
+		fmt.Fprintf(b, "  %s\n", strings.Join(path, " -> "))
+		*found++
+		return
+	}
+
+	for _, effect := range gi.effects[id] {
+
+		if !onPath[effect] {
+			gi.paths(b, effect, targets, path, onPath, found)
+		}
+	}
+}
+
+// Exec parses and answers one query.
+func (e *Engine) Exec(raw string) (string, error) {
+
+	q, err := Parse(raw)
+	if err != nil {
+		return "", err
+	}
+
+	return e.Run(q), nil
+}
+
+// Run answers a parsed query in human-readable form.
+func (e *Engine) Run(q *Query) string {
+
+	b := &strings.Builder{}
+
+	switch q.Command {
+	case "goals", "rules":
+
+		kind := strings.TrimSuffix(q.Command, "s")
+
+		for _, gi := range e.selectGraphs(q.Conds, false) {
+
+			for _, id := range gi.matching(nil, q.Conds) {
+
+				if gi.nodes[id].Kind == kind {
+					fmt.Fprintf(b, "%s: %s\n", gi.header(), describe(gi.nodes[id]))
+				}
+			}
+		}
+
+	case "runs":
+
+		runs := make([]uint, 0, len(e.graphs))
+		seen := make(map[uint]bool)
+
+		for _, gi := range e.selectGraphs(q.Conds, false) {
+
+			if !seen[gi.graph.Iteration] && (len(gi.matching(nil, q.Conds)) > 0) {
+				seen[gi.graph.Iteration] = true
+				runs = append(runs, gi.graph.Iteration)
+			}
+		}
+
+		sort.Slice(runs, func(i, j int) bool { return runs[i] < runs[j] })
+
+		iters := make([]string, len(runs))
+		for i := range runs {
+			iters[i] = fmt.Sprintf("%d", runs[i])
+		}
+
+		fmt.Fprintf(b, "%d run(s): %s\n", len(runs), strings.Join(iters, ", "))
+
+	case "why":
+
+		for _, gi := range e.selectGraphs(q.Conds, false) {
+
+			for _, id := range gi.matching(q.From, q.Conds) {
+
+				fmt.Fprintf(b, "%s:\n", gi.header())
+				gi.tree(b, id, 1, make(map[string]bool), nil)
+			}
+		}
+
+	case "whynot":
+		e.whyNot(b, q)
+
+	case "ancestors", "descendants":
+
+		for _, gi := range e.selectGraphs(q.Conds, false) {
+
+			start := gi.matching(q.From, q.Conds)
+			if len(start) == 0 {
+				continue
+			}
+
+			next := gi.causes
+			if q.Command == "descendants" {
+				next = gi.effects
+			}
+
+			fmt.Fprintf(b, "%s:\n", gi.header())
+			for _, id := range gi.closure(start, next) {
+				fmt.Fprintf(b, "  %s\n", describe(gi.nodes[id]))
+			}
+		}
+
+	case "paths":
+
+		for _, gi := range e.selectGraphs(q.Conds, false) {
+
+			targets := make(map[string]bool)
+			for _, id := range gi.matching(q.To, q.Conds) {
+				targets[id] = true
+			}
+
+			starts := gi.matching(q.From, q.Conds)
+			if (len(targets) == 0) || (len(starts) == 0) {
+				continue
+			}
+
+			pathsB := &strings.Builder{}
+			found := 0
+
+			for _, id := range starts {
+				gi.paths(pathsB, id, targets, nil, make(map[string]bool), &found)
+			}
+
+			if found > 0 {
+				fmt.Fprintf(b, "%s:\n%s", gi.header(), pathsB.String())
+			}
+
+			if found >= maxPaths {
+				fmt.Fprintf(b, "  ... stopped after %d paths\n", maxPaths)
+			}
+		}
+	}
+
+	if b.Len() == 0 {
+		return "No results.\n"
+	}
+
+	return b.String()
+}
+
+// whyNot explains the absence of matching goals in
+// the run given by q. With a program, see whyNotProgram.
+// Otherwise, via the derivation of such a goal in another
+// run, its goals marked by whether they hold in this run.
+func (e *Engine) whyNot(b *strings.Builder, q *Query) {
+
+	run := ""
+	for _, c := range q.Conds {
+
+		if (c.Key == "run") && (c.Op == "=") {
+			run = c.Value
+		}
+	}
+
+	if e.program != nil {
+
+		iter, err := strconv.ParseUint(run, 10, 64)
+		if (err == nil) && (e.runs[uint(iter)] != nil) && (e.runs[uint(iter)].Model != nil) {
+			e.whyNotProgram(b, q, e.runs[uint(iter)])
+			return
+		}
+	}
+
+	unexplained := make([]*graphIndex, 0, 2)
+
+	for _, target := range e.selectGraphs(q.Conds, false) {
+
+		matches := target.matching(q.From, q.Conds)
+		if len(matches) > 0 {
+
+			fmt.Fprintf(b, "%s: %s holds, see:\n", target.header(), describe(target.nodes[matches[0]]))
+			target.tree(b, matches[0], 1, make(map[string]bool), nil)
+			continue
+		}
+
+		// Labels of all goals holding in the explained run.
+		holds := make(map[string]bool)
+		for _, node := range target.graph.Nodes {
+
+			if node.Kind == "goal" {
+				holds[node.Label] = true
+			}
+		}
+
+		explained := false
+
+		for _, ref := range e.selectGraphs(q.Conds, true) {
+
+			if (fmt.Sprintf("%d", ref.graph.Iteration) == run) || (ref.graph.Condition != target.graph.Condition) || (ref.graph.Variant != target.graph.Variant) {
+				continue
+			}
+
+			refMatches := ref.matching(q.From, q.Conds)
+			if len(refMatches) == 0 {
+				continue
+			}
+
+			fmt.Fprintf(b, "%s: no %s was derived. In run %d it was derived as follows:\n", target.header(), q.From.Table, ref.graph.Iteration)
+
+			ref.tree(b, refMatches[0], 1, make(map[string]bool), func(node *fi.ProvNode) (string, bool) {
+
+				if node.Kind != "goal" {
+					return "", true
+				}
+
+				// Below goals that also hold in the
+				// explained run, nothing is missing.
+				if holds[node.Label] {
+					return fmt.Sprintf("   [holds in run %s]", run), false
+				}
+
+				return fmt.Sprintf("   [missing in run %s]", run), true
+			})
+
+			explained = true
+			break
+		}
+
+		if !explained {
+			unexplained = append(unexplained, target)
+		}
+	}
+
+	// Only mention graphs without any matching goal
+	// in any run if there is nothing else to say.
+	if b.Len() == 0 {
+
+		for _, target := range unexplained {
+			fmt.Fprintf(b, "%s: no %s was derived, neither in any other run.\n", target.header(), q.From.Table)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Commands understood by the query language and
+// whether they take a pattern.
+var commands = map[string]bool{
+	"goals":       false,
+	"rules":       false,
+	"runs":        false,
+	"why":         true,
+	"whynot":      true,
+	"ancestors":   true,
+	"descendants": true,
+	"paths":       true,
+}
+
+// Keys conditions may restrict.
+var condKeys = map[string]bool{
+	"run":       true,
+	"condition": true,
+	"variant":   true,
+	"kind":      true,
+	"table":     true,
+	"type":      true,
+	"label":     true,
+	"time":      true,
+}
+
+// Regular expressions of the query language.
+var (
+	whereRegex   = regexp.MustCompile(`(?i)\s+where\s+`)
+	condRegex    = regexp.MustCompile(`^(\w+)\s*(<=|>=|!=|=|<|>)\s*("[^"]*"|[^\s"]+)`)
+	andRegex     = regexp.MustCompile(`^(?i)(and\s+|,\s*)`)
+	patternRegex = regexp.MustCompile(`^(\w+)\s*(?:\((.*)\))?\s*(?:@\s*(\d+))?$`)
+)
+
+// Functions.
+
+// parsePattern parses a table name with optional
+// arguments and time, e.g., acked, acked(a, _, 2),
+// or post(foo)@5.
+func parsePattern(raw string) (*Pattern, error) {
+
+	m := patternRegex.FindStringSubmatch(strings.TrimSpace(raw))
+	if m == nil {
+		return nil, fmt.Errorf("Invalid pattern '%s', expected a table such as 'log' or 'log(a, _)'", strings.TrimSpace(raw))
+	}
+
+	p := &Pattern{
+		Table: m[1],
+		Time:  m[3],
+	}
+
+	if strings.TrimSpace(m[2]) != "" {
+
+		for _, arg := range strings.Split(m[2], ",") {
+			p.Args = append(p.Args, strings.TrimSpace(arg))
+		}
+	}
+
+	return p, nil
+}
+
+// parseConds parses the conditions following 'where'.
+func parseConds(raw string) ([]*Cond, error) {
+
+	conds := make([]*Cond, 0, 2)
+	raw = strings.TrimSpace(raw)
+
+	for raw != "" {
+
+		if len(conds) > 0 {
+			raw = andRegex.ReplaceAllString(raw, "")
+		}
+
+		m := condRegex.FindStringSubmatch(raw)
+		if m == nil {
+			return nil, fmt.Errorf("Invalid condition '%s', expected e.g. 'run=7' or 'time<3'", raw)
+		}
+
+		if !condKeys[m[1]] {
+			return nil, fmt.Errorf("Unknown key '%s' in condition, choose from run, condition, variant, kind, table, type, label, and time", m[1])
+		}
+
+		conds = append(conds, &Cond{
+			Key:   m[1],
+			Op:    m[2],
+			Value: strings.Trim(m[3], "\""),
+		})
+
+		raw = strings.TrimSpace(raw[len(m[0]):])
+	}
+
+	return conds, nil
+}
+
+// Parse parses one statement of the query language:
+//
+//	<command> [<pattern> [-> <pattern>]] [where <cond> [and <cond>]...]
+//
+// See Help for all commands.
+func Parse(raw string) (*Query, error) {
+
+	raw = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(raw), ";"))
+	if raw == "" {
+		return nil, fmt.Errorf("Empty query")
+	}
+
+	q := &Query{}
+
+	head := raw
+	parts := whereRegex.Split(raw, 2)
+	if len(parts) == 2 {
+
+		head = parts[0]
+
+		conds, err := parseConds(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		q.Conds = conds
+	}
+
+	fields := strings.SplitN(strings.TrimSpace(head), " ", 2)
+	q.Command = strings.Replace(strings.ToLower(fields[0]), "-", "", -1)
+
+	takesPattern, found := commands[q.Command]
+	if !found {
+		return nil, fmt.Errorf("Unknown command '%s', type 'help' for all commands", fields[0])
+	}
+
+	subject := ""
+	if len(fields) == 2 {
+		subject = strings.TrimSpace(fields[1])
+	}
+
+	if !takesPattern {
+
+		if subject != "" {
+			return nil, fmt.Errorf("Command '%s' takes no pattern, restrict it with 'where' instead", q.Command)
+		}
+
+		return q, nil
+	}
+
+	if subject == "" {
+		return nil, fmt.Errorf("Command '%s' needs a pattern, e.g., '%s log'", q.Command, q.Command)
+	}
+
+	if q.Command == "paths" {
+
+		ends := strings.Split(subject, "->")
+		if len(ends) != 2 {
+			return nil, fmt.Errorf("Command 'paths' needs two patterns, e.g., 'paths begin -> post'")
+		}
+
+		from, err := parsePattern(ends[0])
+		if err != nil {
+			return nil, err
+		}
+
+		to, err := parsePattern(ends[1])
+		if err != nil {
+			return nil, err
+		}
+
+		q.From = from
+		q.To = to
+
+		return q, nil
+	}
+
+	from, err := parsePattern(subject)
+	if err != nil {
+		return nil, err
+	}
+	q.From = from
+
+	if q.Command == "whynot" {
+
+		hasRun := false
+		for _, cond := range q.Conds {
+			hasRun = hasRun || ((cond.Key == "run") && (cond.Op == "="))
+		}
+
+		if !hasRun {
+			return nil, fmt.Errorf("Command 'whynot' needs the run to explain, e.g., 'whynot %s where run=1'", subject)
+		}
+	}
+
+	return q, nil
+}
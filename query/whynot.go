@@ -0,0 +1,499 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/numbleroot/nemo/dedalus"
+	fi "github.com/numbleroot/nemo/faultinjectors"
+)
+
+// Structs.
+
+// row is one tuple of a table in the model of a
+// run, i.e., all tuples that held at some time.
+type row struct {
+	args []string
+	time uint
+}
+
+// explainer explains the absence of tuples in one
+// run with the rules of the Dedalus program, down
+// to injected faults or missing base facts.
+type explainer struct {
+	program *dedalus.Program
+	run     *fi.Run
+	tables  map[string][]*row
+	visited map[string]bool
+	b       *strings.Builder
+}
+
+// attempt records how far a rule body got
+// before it could not be satisfied.
+type attempt struct {
+	depth    int
+	bindings map[string]string
+	blocked  *dedalus.Atom
+	failed   *dedalus.Comparison
+}
+
+// Constants.
+
+// maxWhyNotDepth bounds how deeply explanations nest.
+const maxWhyNotDepth = 24
+
+// maxSearchSteps bounds the join of one rule body.
+const maxSearchSteps = 100000
+
+// Functions.
+
+// newExplainer indexes the model of run for
+// explanations with program.
+func newExplainer(program *dedalus.Program, run *fi.Run, b *strings.Builder) *explainer {
+
+	x := &explainer{
+		program: program,
+		run:     run,
+		tables:  make(map[string][]*row),
+		visited: make(map[string]bool),
+		b:       b,
+	}
+
+	if run.Model == nil {
+		return x
+	}
+
+	// The last column of each tuple is its time.
+	for table, tuples := range run.Model.Tables {
+
+		for _, tuple := range tuples {
+
+			if len(tuple) == 0 {
+				continue
+			}
+
+			t, err := strconv.ParseUint(tuple[(len(tuple)-1)], 10, 64)
+			if err != nil {
+				continue
+			}
+
+			x.tables[table] = append(x.tables[table], &row{args: tuple[:(len(tuple) - 1)], time: uint(t)})
+		}
+	}
+
+	return x
+}
+
+// lookup returns all tuples of table at time matching
+// args, where empty arguments match anything.
+func (x *explainer) lookup(table string, args []string, time uint) []*row {
+
+	rows := make([]*row, 0, 2)
+
+	for _, r := range x.tables[table] {
+
+		if (r.time != time) || (len(r.args) < len(args)) {
+			continue
+		}
+
+		match := true
+		for i := range args {
+			match = match && ((args[i] == "") || (args[i] == r.args[i]))
+		}
+
+		if match {
+			rows = append(rows, r)
+		}
+	}
+
+	return rows
+}
+
+// value returns the value of term under bindings,
+// or the empty string if it is not determined.
+func value(term *dedalus.Term, bindings map[string]string) string {
+
+	switch term.Kind {
+	case dedalus.Constant:
+		return term.Value
+	case dedalus.Variable:
+		return bindings[term.Name]
+	case dedalus.Arithmetic:
+
+		v, err := strconv.Atoi(bindings[term.Name])
+		if err != nil {
+			return ""
+		}
+
+		switch term.Op {
+		case "+":
+			return fmt.Sprintf("%d", (v + term.Operand))
+		case "-":
+			return fmt.Sprintf("%d", (v - term.Operand))
+		}
+
+		return fmt.Sprintf("%d", (v * term.Operand))
+	}
+
+	return ""
+}
+
+// instantiate returns the arguments of atom under bindings.
+func instantiate(atom *dedalus.Atom, bindings map[string]string) []string {
+
+	args := make([]string, len(atom.Args))
+	for i := range atom.Args {
+		args[i] = value(atom.Args[i], bindings)
+	}
+
+	return args
+}
+
+// format returns a tuple pattern in Dedalus syntax,
+// undetermined arguments written as '_'.
+func format(table string, args []string, time uint) string {
+
+	shown := make([]string, len(args))
+	for i := range args {
+
+		shown[i] = args[i]
+		if shown[i] == "" {
+			shown[i] = "_"
+		}
+	}
+
+	return fmt.Sprintf("%s(%s)@%d", table, strings.Join(shown, ", "), time)
+}
+
+// bind extends bindings by unifying the terms of atom
+// with args. It reports false on conflicts.
+func bind(atom *dedalus.Atom, args []string, bindings map[string]string) (map[string]string, bool) {
+
+	extended := make(map[string]string, (len(bindings) + len(args)))
+	for k, v := range bindings {
+		extended[k] = v
+	}
+
+	for i, term := range atom.Args {
+
+		if (i >= len(args)) || (args[i] == "") {
+			continue
+		}
+
+		switch term.Kind {
+		case dedalus.Constant:
+
+			if term.Value != args[i] {
+				return nil, false
+			}
+
+		case dedalus.Variable:
+
+			bound, found := extended[term.Name]
+			if found && (bound != args[i]) {
+				return nil, false
+			}
+			extended[term.Name] = args[i]
+
+		case dedalus.Arithmetic:
+
+			v := value(term, extended)
+			if (v != "") && (v != args[i]) {
+				return nil, false
+			}
+		}
+	}
+
+	return extended, true
+}
+
+// holds evaluates a comparison under bindings. Undetermined
+// comparisons are assumed to hold.
+func holds(cmp *dedalus.Comparison, bindings map[string]string) bool {
+
+	left := value(cmp.Left, bindings)
+	right := value(cmp.Right, bindings)
+
+	if (left == "") || (right == "") {
+		return true
+	}
+
+	op := cmp.Op
+	if op == "==" {
+		op = "="
+	}
+
+	return compare(left, op, right)
+}
+
+// search joins the positive body atoms of rule from
+// index i on, at time, and returns the deepest attempt.
+// An attempt of depth len(positive) without blocked or
+// failed parts means the body was satisfied.
+func (x *explainer) search(positive []*dedalus.Atom, i int, rule *dedalus.Rule, bindings map[string]string, time uint, steps *int) *attempt {
+
+	*steps++
+
+	if i == len(positive) {
+
+		for _, cmp := range rule.Conditions {
+
+			if !holds(cmp, bindings) {
+				return &attempt{depth: i, bindings: bindings, failed: cmp}
+			}
+		}
+
+		for _, atom := range rule.Body {
+
+			if atom.Negated && (len(x.lookup(atom.Table, instantiate(atom, bindings), time)) > 0) {
+				return &attempt{depth: i, bindings: bindings, blocked: atom}
+			}
+		}
+
+		return &attempt{depth: i, bindings: bindings}
+	}
+
+	best := &attempt{depth: i, bindings: bindings}
+
+	for _, r := range x.lookup(positive[i].Table, instantiate(positive[i], bindings), time) {
+
+		if *steps > maxSearchSteps {
+			break
+		}
+
+		extended, ok := bind(positive[i], r.args, bindings)
+		if !ok {
+			continue
+		}
+
+		a := x.search(positive, (i + 1), rule, extended, time, steps)
+
+		// A satisfied body is the best possible attempt.
+		if (a.depth == len(positive)) && (a.blocked == nil) && (a.failed == nil) {
+			return a
+		}
+
+		if a.depth > best.depth {
+			best = a
+		}
+	}
+
+	return best
+}
+
+// crashed returns the time node crashed at, if it did.
+func (x *explainer) crashed(node string) (uint, bool) {
+
+	if (x.run.FailureSpec == nil) || (x.run.FailureSpec.Crashes == nil) {
+		return 0, false
+	}
+
+	for _, crash := range *x.run.FailureSpec.Crashes {
+
+		if crash.Node == node {
+			return crash.Time, true
+		}
+	}
+
+	return 0, false
+}
+
+// dropped reports whether the message from sender
+// to receiver sent at time was lost.
+func (x *explainer) dropped(sender string, receiver string, time uint) bool {
+
+	if (x.run.FailureSpec == nil) || (x.run.FailureSpec.Omissions == nil) {
+		return false
+	}
+
+	for _, loss := range *x.run.FailureSpec.Omissions {
+
+		if (loss.From == sender) && (loss.To == receiver) && (loss.Time == time) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// line writes one indented line of the explanation.
+func (x *explainer) line(depth int, format string, args ...interface{}) {
+	fmt.Fprintf(x.b, "%s%s\n", strings.Repeat("  ", (depth+1)), fmt.Sprintf(format, args...))
+}
+
+// explain writes why no tuple of table matching args
+// held at time, recursing into absent subgoals.
+func (x *explainer) explain(table string, args []string, time uint, depth int) {
+
+	tuple := format(table, args, time)
+
+	if len(x.lookup(table, args, time)) > 0 {
+		x.line(depth, "%s holds.", tuple)
+		return
+	}
+
+	if x.visited[tuple] {
+		x.line(depth, "%s is absent (see above).", tuple)
+		return
+	}
+	x.visited[tuple] = true
+
+	// Tuples are located at their first argument.
+	if (len(args) > 0) && (args[0] != "") {
+
+		crashTime, crashed := x.crashed(args[0])
+		if crashed && (crashTime <= time) {
+			x.line(depth, "%s is absent: node %s crashed at time %d. [base cause]", tuple, args[0], crashTime)
+			return
+		}
+	}
+
+	if depth >= maxWhyNotDepth {
+		x.line(depth, "%s is absent. Stopping here, explanation too deep.", tuple)
+		return
+	}
+
+	rules := x.program.RulesFor(table)
+	if len(rules) == 0 {
+
+		for _, fact := range x.program.Facts {
+
+			if fact.Atom.Table == table {
+				x.line(depth, "%s is absent: no base fact of %s provides it at time %d. [base cause]", tuple, table, time)
+				return
+			}
+		}
+
+		x.line(depth, "%s is absent: %s is neither derived by a rule nor provided by base facts. [base cause]", tuple, table)
+		return
+	}
+
+	x.line(depth, "%s is absent. It could have been derived by:", tuple)
+
+	for _, rule := range rules {
+		x.explainRule(rule, args, time, (depth + 1))
+	}
+}
+
+// explainRule writes why rule did not derive a
+// tuple matching args at time.
+func (x *explainer) explainRule(rule *dedalus.Rule, args []string, time uint, depth int) {
+
+	bindings, ok := bind(rule.Head, args, make(map[string]string))
+	if !ok {
+		return
+	}
+
+	x.line(depth, "%s  [%s:%d]", rule, x.program.File, rule.Line)
+
+	// Inductive and asynchronous rules fire in the
+	// timestep before their head. Time starts at 1.
+	bodyTime := time
+	if rule.Time != "" {
+
+		if time <= 1 {
+			x.line((depth + 1), "No timestep before %d to fire in.", time)
+			return
+		}
+		bodyTime = time - 1
+	}
+
+	positive := make([]*dedalus.Atom, 0, len(rule.Body))
+	for _, atom := range rule.Body {
+
+		if !atom.Negated {
+			positive = append(positive, atom)
+		}
+	}
+
+	steps := 0
+	a := x.search(positive, 0, rule, bindings, bodyTime, &steps)
+
+	switch {
+	case a.depth < len(positive):
+
+		for _, atom := range positive[:a.depth] {
+			x.line((depth + 1), "%s held.", format(atom.Table, instantiate(atom, a.bindings), bodyTime))
+		}
+
+		absent := positive[a.depth]
+		x.explain(absent.Table, instantiate(absent, a.bindings), bodyTime, (depth + 1))
+
+	case a.blocked != nil:
+		x.line((depth + 1), "Blocked: %s held, negated by notin.", format(a.blocked.Table, instantiate(a.blocked, a.bindings), bodyTime))
+
+	case a.failed != nil:
+		x.line((depth + 1), "Condition %s was false (%s %s %s).", a.failed, value(a.failed.Left, a.bindings), a.failed.Op, value(a.failed.Right, a.bindings))
+
+	case rule.Time == "async":
+
+		sender := ""
+		if (len(positive) > 0) && (len(positive[0].Args) > 0) {
+			sender = value(positive[0].Args[0], a.bindings)
+		}
+		receiver := value(rule.Head.Args[0], a.bindings)
+
+		if x.dropped(sender, receiver, bodyTime) {
+			x.line((depth + 1), "The body held at time %d, but the message from %s to %s was dropped. [base cause]", bodyTime, sender, receiver)
+			return
+		}
+
+		crashTime, crashed := x.crashed(sender)
+		if crashed && (crashTime <= bodyTime) {
+			x.line((depth + 1), "Node %s crashed at time %d before sending. [base cause]", sender, crashTime)
+			return
+		}
+
+		x.line((depth + 1), "The body held at time %d at %s, yet the message to %s did not arrive.", bodyTime, sender, receiver)
+
+	default:
+		x.line((depth + 1), "The body held at time %d, yet the head is absent.", bodyTime)
+	}
+}
+
+// UseProgram lets whynot explain absent goals with the
+// rules of program and the models of runs, instead of
+// comparing against the provenance of other runs.
+func (e *Engine) UseProgram(program *dedalus.Program, runs []*fi.Run) {
+
+	e.program = program
+	e.runs = make(map[uint]*fi.Run, len(runs))
+
+	for _, run := range runs {
+
+		if run != nil {
+			e.runs[run.Iteration] = run
+		}
+	}
+}
+
+// whyNotProgram explains the absence of goals matching
+// q in run with the rules of the program. Without a
+// time, the pattern refers to the end of time.
+func (e *Engine) whyNotProgram(b *strings.Builder, q *Query, run *fi.Run) {
+
+	x := newExplainer(e.program, run, b)
+
+	time := uint(0)
+	if q.From.Time != "" {
+
+		t, err := strconv.ParseUint(q.From.Time, 10, 64)
+		if err == nil {
+			time = uint(t)
+		}
+	} else if run.FailureSpec != nil {
+		time = run.FailureSpec.EOT
+	}
+
+	args := make([]string, len(q.From.Args))
+	for i := range q.From.Args {
+
+		if q.From.Args[i] != "_" {
+			args[i] = strings.Trim(q.From.Args[i], "\"")
+		}
+	}
+
+	fmt.Fprintf(b, "run %d (%s):\n", run.Iteration, run.Status)
+	x.explain(q.From.Table, args, time, 0)
+}
@@ -0,0 +1,302 @@
+package report
+
+import (
+	"fmt"
+
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"path/filepath"
+
+	fi "github.com/numbleroot/nemo/faultinjectors"
+)
+
+// Structs.
+
+// exportAttr is one property of a provenance node
+// as stored in exported graph formats.
+type exportAttr struct {
+	name  string
+	kind  string
+	value interface{}
+}
+
+// graphMLFile is the root element of a GraphML file.
+type graphMLFile struct {
+	XMLName xml.Name      `xml:"graphml"`
+	XMLNS   string        `xml:"xmlns,attr"`
+	Keys    []*graphMLKey `xml:"key"`
+	Graph   *graphMLGraph `xml:"graph"`
+}
+
+// graphMLKey declares a property of nodes or the graph.
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+// graphMLGraph
+type graphMLGraph struct {
+	ID          string         `xml:"id,attr"`
+	EdgeDefault string         `xml:"edgedefault,attr"`
+	Data        []*graphMLData `xml:"data"`
+	Nodes       []*graphMLNode `xml:"node"`
+	Edges       []*graphMLEdge `xml:"edge"`
+}
+
+// graphMLData
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// graphMLNode
+type graphMLNode struct {
+	ID   string         `xml:"id,attr"`
+	Data []*graphMLData `xml:"data"`
+}
+
+// graphMLEdge
+type graphMLEdge struct {
+	ID     string `xml:"id,attr"`
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+}
+
+// gexfFile is the root element of a GEXF file, Gephi's
+// native format.
+type gexfFile struct {
+	XMLName xml.Name   `xml:"gexf"`
+	XMLNS   string     `xml:"xmlns,attr"`
+	Version string     `xml:"version,attr"`
+	Graph   *gexfGraph `xml:"graph"`
+}
+
+// gexfGraph
+type gexfGraph struct {
+	DefaultEdgeType string          `xml:"defaultedgetype,attr"`
+	Mode            string          `xml:"mode,attr"`
+	Attributes      *gexfAttributes `xml:"attributes"`
+	Nodes           []*gexfNode     `xml:"nodes>node"`
+	Edges           []*graphMLEdge  `xml:"edges>edge"`
+}
+
+// gexfAttributes
+type gexfAttributes struct {
+	Class      string           `xml:"class,attr"`
+	Attributes []*gexfAttribute `xml:"attribute"`
+}
+
+// gexfAttribute
+type gexfAttribute struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+// gexfNode
+type gexfNode struct {
+	ID        string          `xml:"id,attr"`
+	Label     string          `xml:"label,attr"`
+	AttValues []*gexfAttValue `xml:"attvalues>attvalue"`
+}
+
+// gexfAttValue
+type gexfAttValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// cytoscapeFile is the Cytoscape.js JSON format,
+// as also imported by Cytoscape desktop.
+type cytoscapeFile struct {
+	Data     map[string]interface{} `json:"data"`
+	Elements *cytoscapeElements     `json:"elements"`
+}
+
+// cytoscapeElements
+type cytoscapeElements struct {
+	Nodes []*cytoscapeElement `json:"nodes"`
+	Edges []*cytoscapeElement `json:"edges"`
+}
+
+// cytoscapeElement
+type cytoscapeElement struct {
+	Data map[string]interface{} `json:"data"`
+}
+
+// Functions.
+
+// exportAttrs returns all properties of a node of
+// provGraph, in the same order for every format.
+func exportAttrs(provGraph *fi.ProvGraph, node *fi.ProvNode) []*exportAttr {
+
+	return []*exportAttr{
+		{"kind", "string", node.Kind},
+		{"label", "string", node.Label},
+		{"run", "long", provGraph.Iteration},
+		{"condition", "string", provGraph.Condition},
+		{"table", "string", node.Table},
+		{"type", "string", node.Type},
+		{"time", "string", node.Time},
+		{"condition_holds", "boolean", node.CondHolds},
+		{"missing", "boolean", node.Missing},
+	}
+}
+
+// graphName identifies a provenance graph within exports.
+func graphName(provGraph *fi.ProvGraph) string {
+	return fmt.Sprintf("run_%d_%s_%s", provGraph.Iteration, provGraph.Condition, provGraph.Variant)
+}
+
+// writeXML marshals v with XML header to path.
+func writeXML(path string, v interface{}) error {
+
+	data, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Failed to marshal %s: %v", filepath.Base(path), err)
+	}
+
+	return ioutil.WriteFile(path, append([]byte(xml.Header), append(data, '\n')...), 0644)
+}
+
+// writeGraphML writes provGraph to path in GraphML.
+func writeGraphML(path string, provGraph *fi.ProvGraph) error {
+
+	file := &graphMLFile{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []*graphMLKey{
+			{ID: "iteration", For: "graph", AttrName: "iteration", AttrType: "long"},
+			{ID: "variant", For: "graph", AttrName: "variant", AttrType: "string"},
+		},
+		Graph: &graphMLGraph{
+			ID:          graphName(provGraph),
+			EdgeDefault: "directed",
+			Data: []*graphMLData{
+				{Key: "iteration", Value: fmt.Sprintf("%d", provGraph.Iteration)},
+				{Key: "variant", Value: provGraph.Variant},
+			},
+			Nodes: make([]*graphMLNode, len(provGraph.Nodes)),
+			Edges: make([]*graphMLEdge, len(provGraph.Edges)),
+		},
+	}
+
+	// Declare node properties, independent of values.
+	for _, attr := range exportAttrs(provGraph, &fi.ProvNode{}) {
+		file.Keys = append(file.Keys, &graphMLKey{ID: attr.name, For: "node", AttrName: attr.name, AttrType: attr.kind})
+	}
+
+	for i, node := range provGraph.Nodes {
+
+		attrs := exportAttrs(provGraph, node)
+		file.Graph.Nodes[i] = &graphMLNode{ID: node.ID, Data: make([]*graphMLData, len(attrs))}
+		for j, attr := range attrs {
+			file.Graph.Nodes[i].Data[j] = &graphMLData{Key: attr.name, Value: fmt.Sprintf("%v", attr.value)}
+		}
+	}
+
+	for i, edge := range provGraph.Edges {
+		file.Graph.Edges[i] = &graphMLEdge{ID: fmt.Sprintf("e%d", i), Source: edge.From, Target: edge.To}
+	}
+
+	return writeXML(path, file)
+}
+
+// writeGEXF writes provGraph to path in GEXF.
+func writeGEXF(path string, provGraph *fi.ProvGraph) error {
+
+	file := &gexfFile{
+		XMLNS:   "http://gexf.net/1.3",
+		Version: "1.3",
+		Graph: &gexfGraph{
+			DefaultEdgeType: "directed",
+			Mode:            "static",
+			Attributes:      &gexfAttributes{Class: "node"},
+			Nodes:           make([]*gexfNode, len(provGraph.Nodes)),
+			Edges:           make([]*graphMLEdge, len(provGraph.Edges)),
+		},
+	}
+
+	// Declare node properties, independent of values.
+	for j, attr := range exportAttrs(provGraph, &fi.ProvNode{}) {
+		file.Graph.Attributes.Attributes = append(file.Graph.Attributes.Attributes, &gexfAttribute{ID: fmt.Sprintf("%d", j), Title: attr.name, Type: attr.kind})
+	}
+
+	for i, node := range provGraph.Nodes {
+
+		attrs := exportAttrs(provGraph, node)
+		file.Graph.Nodes[i] = &gexfNode{ID: node.ID, Label: node.Label, AttValues: make([]*gexfAttValue, len(attrs))}
+		for j, attr := range attrs {
+			file.Graph.Nodes[i].AttValues[j] = &gexfAttValue{For: fmt.Sprintf("%d", j), Value: fmt.Sprintf("%v", attr.value)}
+		}
+	}
+
+	for i, edge := range provGraph.Edges {
+		file.Graph.Edges[i] = &graphMLEdge{ID: fmt.Sprintf("e%d", i), Source: edge.From, Target: edge.To}
+	}
+
+	return writeXML(path, file)
+}
+
+// writeCytoscape writes provGraph to path in
+// Cytoscape.js JSON.
+func writeCytoscape(path string, provGraph *fi.ProvGraph) error {
+
+	file := &cytoscapeFile{
+		Data: map[string]interface{}{
+			"name":      graphName(provGraph),
+			"iteration": provGraph.Iteration,
+			"condition": provGraph.Condition,
+			"variant":   provGraph.Variant,
+		},
+		Elements: &cytoscapeElements{
+			Nodes: make([]*cytoscapeElement, len(provGraph.Nodes)),
+			Edges: make([]*cytoscapeElement, len(provGraph.Edges)),
+		},
+	}
+
+	for i, node := range provGraph.Nodes {
+
+		data := map[string]interface{}{"id": node.ID}
+		for _, attr := range exportAttrs(provGraph, node) {
+			data[attr.name] = attr.value
+		}
+
+		file.Elements.Nodes[i] = &cytoscapeElement{Data: data}
+	}
+
+	for i, edge := range provGraph.Edges {
+
+		file.Elements.Edges[i] = &cytoscapeElement{Data: map[string]interface{}{
+			"id":     fmt.Sprintf("e%d", i),
+			"source": edge.From,
+			"target": edge.To,
+		}}
+	}
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Failed to marshal %s: %v", filepath.Base(path), err)
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// exportGraph writes provGraph in all supported exchange
+// formats to the figures directory, next to its DOT file.
+func (r *Report) exportGraph(fileName string, provGraph *fi.ProvGraph) error {
+
+	err := writeGraphML(filepath.Join(r.figuresDir, fmt.Sprintf("%s.graphml", fileName)), provGraph)
+	if err != nil {
+		return err
+	}
+
+	err = writeGEXF(filepath.Join(r.figuresDir, fmt.Sprintf("%s.gexf", fileName)), provGraph)
+	if err != nil {
+		return err
+	}
+
+	return writeCytoscape(filepath.Join(r.figuresDir, fmt.Sprintf("%s.cyjs", fileName)), provGraph)
+}
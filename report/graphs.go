@@ -0,0 +1,151 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/awalterschulze/gographviz"
+	fi "github.com/numbleroot/nemo/faultinjectors"
+)
+
+// Structs.
+
+// graphNode is a provenance node placed by our
+// layout, ready to be drawn by the report page.
+type graphNode struct {
+	*fi.ProvNode
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// graphData is the content of one JSON file
+// read by the interactive graph viewer.
+type graphData struct {
+	Iteration uint         `json:"iteration"`
+	Condition string       `json:"condition"`
+	Variant   string       `json:"variant"`
+	Width     float64      `json:"width"`
+	Height    float64      `json:"height"`
+	Nodes     []*graphNode `json:"nodes"`
+	Edges     []*fi.Edge   `json:"edges"`
+}
+
+// Functions.
+
+// layoutProvGraph places all nodes of a provenance
+// graph with the same layout used for figures.
+func layoutProvGraph(provGraph *fi.ProvGraph) (*graphData, error) {
+
+	dotGraph := gographviz.NewGraph()
+
+	err := dotGraph.SetName("provenance")
+	if err != nil {
+		return nil, err
+	}
+
+	err = dotGraph.SetDir(true)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, node := range provGraph.Nodes {
+
+		shape := "ellipse"
+		if node.Kind == "rule" {
+			shape = "rect"
+		}
+
+		err := dotGraph.AddNode("provenance", node.ID, map[string]string{
+			"label": fmt.Sprintf("\"%s\"", strings.Replace(node.Label, "\"", "\\\"", -1)),
+			"shape": shape,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, edge := range provGraph.Edges {
+
+		err := dotGraph.AddEdge(edge.From, edge.To, true, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	l := computeLayout(dotGraph)
+
+	placed := make(map[string]*layoutNode, len(l.nodes))
+	for _, n := range l.nodes {
+		placed[n.name] = n
+	}
+
+	data := &graphData{
+		Iteration: provGraph.Iteration,
+		Condition: provGraph.Condition,
+		Variant:   provGraph.Variant,
+		Width:     l.width,
+		Height:    l.height,
+		Nodes:     make([]*graphNode, len(provGraph.Nodes)),
+		Edges:     provGraph.Edges,
+	}
+
+	for i, node := range provGraph.Nodes {
+
+		n := placed[node.ID]
+
+		data.Nodes[i] = &graphNode{
+			ProvNode: node,
+			X:        n.x,
+			Y:        n.y,
+			Width:    n.width,
+			Height:   n.height,
+		}
+	}
+
+	return data, nil
+}
+
+// GenerateGraphs writes out provenance graphs as
+// JSON for the interactive viewer of the report,
+// and exports them for external graph tools.
+func (r *Report) GenerateGraphs(iters []uint, name string, provGraphs []*fi.ProvGraph) error {
+
+	if len(iters) != len(provGraphs) {
+		return fmt.Errorf("Unequal number of iteration numbers and provenance graphs")
+	}
+
+	for i := range iters {
+
+		fileName := fmt.Sprintf("run_%d_%s", iters[i], name)
+
+		err := r.exportGraph(fileName, provGraphs[i])
+		if err != nil {
+			return err
+		}
+
+		data, err := layoutProvGraph(provGraphs[i])
+		if err != nil {
+			return err
+		}
+
+		dataJSON, err := json.Marshal(data)
+		if err != nil {
+			return fmt.Errorf("Failed to marshal provenance graph to JSON: %v", err)
+		}
+
+		graphFilePath := filepath.Join(r.figuresDir, fmt.Sprintf("%s.json", fileName))
+
+		err = ioutil.WriteFile(graphFilePath, dataJSON, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,28 +1,95 @@
 package report
 
 import (
+	"embed"
 	"fmt"
+	"regexp"
 	"strings"
 
-	"os/exec"
+	"encoding/base64"
+	"io/fs"
+	"path/filepath"
+)
+
+// assets holds the template of the report webpage
+// with all its styles, scripts, and fonts. Of the
+// fonts, we only need the WOFF2 variants.
+//
+//go:embed assets/index.html assets/vendor assets/webfonts/*.woff2
+var assets embed.FS
+
+// Regular expressions matching references from
+// the report template to its assets.
+var (
+	stylesheetRegex = regexp.MustCompile(`<link rel = "stylesheet" href = "([^"]+)" />`)
+	scriptRegex     = regexp.MustCompile(`<script src = "([^"]+)"></script>`)
+	fontFaceRegex   = regexp.MustCompile(`src:url\(\.\./webfonts/([\w-]+)\.eot\);src:[^}]*`)
 )
 
 // Functions.
 
-// copyDir
-func copyDir(srcDir string, resDir string) error {
+// inlineFonts replaces all font references of a
+// stylesheet with the WOFF2 font as data URI.
+func inlineFonts(css string) (string, error) {
+
+	var err error
+
+	css = fontFaceRegex.ReplaceAllStringFunc(css, func(fontFace string) string {
+
+		font := fontFaceRegex.FindStringSubmatch(fontFace)[1]
+
+		data, readErr := fs.ReadFile(assets, fmt.Sprintf("assets/webfonts/%s.woff2", font))
+		if readErr != nil {
+			err = readErr
+			return fontFace
+		}
+
+		return fmt.Sprintf("src:url(data:font/woff2;base64,%s) format(\"woff2\")", base64.StdEncoding.EncodeToString(data))
+	})
+
+	return css, err
+}
+
+// inlineAssets replaces all references to stylesheets
+// and scripts in page with the content of these files.
+func inlineAssets(page string) (string, error) {
+
+	var err error
+
+	page = stylesheetRegex.ReplaceAllStringFunc(page, func(link string) string {
+
+		data, readErr := fs.ReadFile(assets, filepath.ToSlash(filepath.Join("assets", stylesheetRegex.FindStringSubmatch(link)[1])))
+		if readErr != nil {
+			err = readErr
+			return link
+		}
 
-	fmt.Printf("Copying %s to %s...", srcDir, resDir)
-	cmd := exec.Command("cp", "-r", srcDir, resDir)
-	out, err := cmd.CombinedOutput()
+		css, fontErr := inlineFonts(string(data))
+		if fontErr != nil {
+			err = fontErr
+			return link
+		}
+
+		return fmt.Sprintf("<style>\n%s\n</style>", css)
+	})
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	if strings.TrimSpace(string(out)) != "" {
-		return fmt.Errorf("Wrong return value from copy command for directory: %s", out)
+	page = scriptRegex.ReplaceAllStringFunc(page, func(script string) string {
+
+		data, readErr := fs.ReadFile(assets, filepath.ToSlash(filepath.Join("assets", scriptRegex.FindStringSubmatch(script)[1])))
+		if readErr != nil {
+			err = readErr
+			return script
+		}
+
+		// Scripts must not end the surrounding tag early.
+		return fmt.Sprintf("<script>\n%s\n</script>", strings.Replace(string(data), "</script", "<\\/script", -1))
+	})
+	if err != nil {
+		return "", err
 	}
-	fmt.Printf(" done\n\n")
 
-	return nil
+	return page, nil
 }
@@ -0,0 +1,169 @@
+package report
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"encoding/xml"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/awalterschulze/gographviz"
+	fi "github.com/numbleroot/nemo/faultinjectors"
+)
+
+// Structs.
+
+// JUnit is a Reporter writing one test case per
+// fault injection run to junit.xml. Runs that
+// violated the specification fail.
+type JUnit struct {
+	Name   string
+	resDir string
+}
+
+// junitSuites is the root element of a JUnit XML file.
+type junitSuites struct {
+	XMLName  xml.Name      `xml:"testsuites"`
+	Tests    int           `xml:"tests,attr"`
+	Failures int           `xml:"failures,attr"`
+	Suites   []*junitSuite `xml:"testsuite"`
+}
+
+// junitSuite
+type junitSuite struct {
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Cases    []*junitCase `xml:"testcase"`
+}
+
+// junitCase
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+// junitFailure
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// Functions.
+
+// failureText describes why a run failed, in plain text.
+func failureText(run *fi.Run) string {
+
+	crashes, omissions := formatFaults(run.FailureSpec)
+
+	lines := []string{
+		fmt.Sprintf("Crashes: %s", crashes),
+		fmt.Sprintf("Message losses: %s", omissions),
+	}
+
+	for _, missing := range run.MissingEvents {
+
+		table := "?"
+		if missing.Rule != nil {
+			table = missing.Rule.Table
+		}
+
+		goals := make([]string, len(missing.Goals))
+		for i, goal := range missing.Goals {
+			goals[i] = fmt.Sprintf("%s @ %s", goal.Label, goal.Time)
+		}
+
+		lines = append(lines, fmt.Sprintf("Rule %s did not fire, missing: %s", table, strings.Join(goals, ", ")))
+	}
+
+	for _, correction := range run.Corrections {
+		lines = append(lines, fmt.Sprintf("Correction: %s", strings.Join(htmlToText(correction, true), " ")))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Prepare creates the directory holding the report.
+func (j *JUnit) Prepare(resDir string) error {
+
+	j.resDir = resDir
+
+	return os.MkdirAll(resDir, 0755)
+}
+
+// GenerateFigure is a no-op, JUnit XML does not contain figures.
+func (j *JUnit) GenerateFigure(fileName string, dotProv *gographviz.Graph) error {
+	return nil
+}
+
+// GenerateFigures is a no-op, JUnit XML does not contain figures.
+func (j *JUnit) GenerateFigures(iters []uint, name string, dotProvs []*gographviz.Graph) error {
+	return nil
+}
+
+// GenerateGraphs is a no-op, JUnit XML does not contain figures.
+func (j *JUnit) GenerateGraphs(iters []uint, name string, provGraphs []*fi.ProvGraph) error {
+	return nil
+}
+
+// Finalize writes the test suite of all runs and
+// returns the path of the written file.
+func (j *JUnit) Finalize(runs []*fi.Run) (string, error) {
+
+	name := j.Name
+	if name == "" {
+		name = "nemo"
+	}
+
+	suite := &junitSuite{Name: name}
+
+	for _, run := range runs {
+
+		if run == nil {
+			continue
+		}
+
+		testCase := &junitCase{
+			Name:      fmt.Sprintf("run %d", run.Iteration),
+			ClassName: name,
+		}
+
+		if run.Status != "success" {
+
+			testCase.Failure = &junitFailure{
+				Message: fmt.Sprintf("Run %d violated the specification", run.Iteration),
+				Type:    "violation",
+				Text:    failureText(run),
+			}
+
+			suite.Failures++
+		}
+
+		suite.Cases = append(suite.Cases, testCase)
+		suite.Tests++
+	}
+
+	suites := &junitSuites{
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Suites:   []*junitSuite{suite},
+	}
+
+	data, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Failed to marshal JUnit XML: %v", err)
+	}
+
+	junitPath := filepath.Join(j.resDir, "junit.xml")
+
+	err = ioutil.WriteFile(junitPath, append([]byte(xml.Header), append(data, '\n')...), 0644)
+	if err != nil {
+		return "", fmt.Errorf("Failed to write JUnit XML: %v", err)
+	}
+
+	return junitPath, nil
+}
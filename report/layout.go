@@ -0,0 +1,614 @@
+package report
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/awalterschulze/gographviz"
+)
+
+// Structs.
+
+// layoutNode is a node of a graph during layout.
+// Dummy nodes route edges spanning several layers.
+type layoutNode struct {
+	name   string
+	attrs  map[string]string
+	dummy  bool
+	layer  int
+	order  int
+	x      float64
+	y      float64
+	width  float64
+	height float64
+	up     []*layoutNode
+	down   []*layoutNode
+}
+
+// layoutEdge is an edge of a graph during layout.
+// Path holds all nodes the edge passes through.
+type layoutEdge struct {
+	from     *layoutNode
+	to       *layoutNode
+	reversed bool
+	attrs    map[string]string
+	path     []*layoutNode
+}
+
+// layout places the nodes of a directed graph in
+// layers (Sugiyama-style): cycles are broken, nodes
+// assigned to layers, crossings between layers
+// reduced, and finally coordinates assigned.
+type layout struct {
+	attrs    map[string]string
+	directed bool
+	nodes    []*layoutNode
+	edges    []*layoutEdge
+	loops    []*layoutEdge
+	layers   [][]*layoutNode
+	width    float64
+	height   float64
+}
+
+// Layout constants in SVG user units (pixels).
+const (
+	layoutFontSize   = 14.0
+	layoutCharWidth  = 7.5
+	layoutNodeHeight = 36.0
+	layoutNodeSep    = 18.0
+	layoutRankSep    = 56.0
+	layoutMargin     = 8.0
+	layoutSweeps     = 12
+)
+
+// Functions.
+
+// unquote removes surrounding quotes from a DOT
+// attribute value and resolves escaped quotes.
+func unquote(value string) string {
+
+	if (len(value) >= 2) && strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\"") {
+		value = value[1:(len(value) - 1)]
+		value = strings.Replace(value, "\\\"", "\"", -1)
+	}
+
+	return value
+}
+
+// unquoteAttrs returns a plain copy of DOT attributes.
+func unquoteAttrs(attrs gographviz.Attrs) map[string]string {
+
+	plain := make(map[string]string, len(attrs))
+	for key, value := range attrs {
+		plain[string(key)] = unquote(value)
+	}
+
+	return plain
+}
+
+// hasStyle reports whether the comma-separated
+// style attribute contains the given style.
+func hasStyle(attrs map[string]string, style string) bool {
+
+	for _, s := range strings.Split(attrs["style"], ",") {
+
+		if strings.TrimSpace(s) == style {
+			return true
+		}
+	}
+
+	return false
+}
+
+// nodeLabel returns the lines of the label of a node.
+func nodeLabel(n *layoutNode) []string {
+
+	label, found := n.attrs["label"]
+	if !found || (label == "\\N") {
+		label = unquote(n.name)
+	}
+
+	label = strings.Replace(label, "\\l", "\\n", -1)
+	label = strings.Replace(label, "\\r", "\\n", -1)
+
+	return strings.Split(label, "\\n")
+}
+
+// newLayout collects the nodes and edges of a DOT
+// graph in the order they were added, so that graphs
+// of equal structure result in equal layouts.
+func newLayout(dotGraph *gographviz.Graph) *layout {
+
+	l := &layout{
+		attrs:    unquoteAttrs(dotGraph.Attrs),
+		directed: dotGraph.Directed,
+		nodes:    make([]*layoutNode, 0, len(dotGraph.Nodes.Nodes)),
+		edges:    make([]*layoutEdge, 0, len(dotGraph.Edges.Edges)),
+	}
+
+	lookup := make(map[string]*layoutNode)
+
+	addNode := func(name string, attrs gographviz.Attrs) *layoutNode {
+
+		if n, found := lookup[name]; found {
+			return n
+		}
+
+		n := &layoutNode{
+			name:  name,
+			attrs: unquoteAttrs(attrs),
+		}
+
+		// Size nodes according to their label.
+		lines := nodeLabel(n)
+		longest := 0
+		for _, line := range lines {
+			if len(line) > longest {
+				longest = len(line)
+			}
+		}
+
+		n.width = (float64(longest) * layoutCharWidth) + 24.0
+		n.height = math.Max(layoutNodeHeight, (float64(len(lines))*(layoutFontSize+4.0))+16.0)
+
+		switch n.attrs["shape"] {
+		case "rect", "box", "square", "plaintext", "none":
+		case "point":
+			n.width = 8.0
+			n.height = 8.0
+		default:
+			// Ellipses need extra room for their label.
+			n.width = n.width * 1.25
+		}
+
+		lookup[name] = n
+		l.nodes = append(l.nodes, n)
+
+		return n
+	}
+
+	for _, node := range dotGraph.Nodes.Nodes {
+
+		// Nodes named like a DOT keyword are used
+		// to set attributes of the whole graph.
+		if (node.Name == "graph") || (node.Name == "node") || (node.Name == "edge") {
+
+			if node.Name == "graph" {
+				for key, value := range unquoteAttrs(node.Attrs) {
+					l.attrs[key] = value
+				}
+			}
+
+			continue
+		}
+
+		addNode(node.Name, node.Attrs)
+	}
+
+	for _, edge := range dotGraph.Edges.Edges {
+
+		e := &layoutEdge{
+			from:  addNode(edge.Src, nil),
+			to:    addNode(edge.Dst, nil),
+			attrs: unquoteAttrs(edge.Attrs),
+		}
+
+		// Self-loops do not influence the layout.
+		if e.from == e.to {
+			l.loops = append(l.loops, e)
+			continue
+		}
+
+		l.edges = append(l.edges, e)
+	}
+
+	return l
+}
+
+// removeCycles reverses all edges closing a cycle
+// found by a depth-first search in insertion order.
+func (l *layout) removeCycles() {
+
+	out := make(map[*layoutNode][]*layoutEdge)
+	for _, e := range l.edges {
+		out[e.from] = append(out[e.from], e)
+	}
+
+	const (
+		unvisited = iota
+		active
+		done
+	)
+
+	state := make(map[*layoutNode]int)
+
+	var visit func(n *layoutNode)
+	visit = func(n *layoutNode) {
+
+		state[n] = active
+
+		for _, e := range out[n] {
+
+			switch state[e.to] {
+			case unvisited:
+				visit(e.to)
+			case active:
+				e.reversed = true
+			}
+		}
+
+		state[n] = done
+	}
+
+	for _, n := range l.nodes {
+
+		if state[n] == unvisited {
+			visit(n)
+		}
+	}
+
+	for _, e := range l.edges {
+
+		if e.reversed {
+			e.from, e.to = e.to, e.from
+		}
+	}
+}
+
+// assignLayers places every node one layer below
+// its lowest predecessor (longest path layering).
+func (l *layout) assignLayers() {
+
+	in := make(map[*layoutNode][]*layoutNode)
+	out := make(map[*layoutNode][]*layoutNode)
+	indegree := make(map[*layoutNode]int)
+
+	for _, e := range l.edges {
+		in[e.to] = append(in[e.to], e.from)
+		out[e.from] = append(out[e.from], e.to)
+		indegree[e.to]++
+	}
+
+	// Process nodes in topological order.
+	queue := make([]*layoutNode, 0, len(l.nodes))
+	for _, n := range l.nodes {
+
+		if indegree[n] == 0 {
+			queue = append(queue, n)
+		}
+	}
+
+	maxLayer := 0
+
+	for len(queue) > 0 {
+
+		n := queue[0]
+		queue = queue[1:]
+
+		for _, pred := range in[n] {
+
+			if (pred.layer + 1) > n.layer {
+				n.layer = pred.layer + 1
+			}
+		}
+
+		if n.layer > maxLayer {
+			maxLayer = n.layer
+		}
+
+		for _, succ := range out[n] {
+
+			indegree[succ]--
+			if indegree[succ] == 0 {
+				queue = append(queue, succ)
+			}
+		}
+	}
+
+	l.layers = make([][]*layoutNode, (maxLayer + 1))
+	for _, n := range l.nodes {
+		l.layers[n.layer] = append(l.layers[n.layer], n)
+	}
+}
+
+// insertDummies splits edges spanning more than one
+// layer into chains of dummy nodes, one per layer.
+func (l *layout) insertDummies() {
+
+	for _, e := range l.edges {
+
+		e.path = []*layoutNode{e.from}
+		prev := e.from
+
+		for layer := (e.from.layer + 1); layer < e.to.layer; layer++ {
+
+			dummy := &layoutNode{
+				name:  "",
+				dummy: true,
+				layer: layer,
+			}
+
+			l.layers[layer] = append(l.layers[layer], dummy)
+			prev.down = append(prev.down, dummy)
+			dummy.up = append(dummy.up, prev)
+
+			e.path = append(e.path, dummy)
+			prev = dummy
+		}
+
+		prev.down = append(prev.down, e.to)
+		e.to.up = append(e.to.up, prev)
+		e.path = append(e.path, e.to)
+	}
+
+	for _, layer := range l.layers {
+
+		for i, n := range layer {
+			n.order = i
+		}
+	}
+}
+
+// crossings counts the edge crossings
+// between all pairs of adjacent layers.
+func (l *layout) crossings() int {
+
+	count := 0
+
+	for i := 0; i < (len(l.layers) - 1); i++ {
+
+		segments := make([][2]int, 0, len(l.layers[i]))
+		for _, n := range l.layers[i] {
+			for _, succ := range n.down {
+				segments = append(segments, [2]int{n.order, succ.order})
+			}
+		}
+
+		for a := range segments {
+			for b := (a + 1); b < len(segments); b++ {
+
+				if ((segments[a][0] - segments[b][0]) * (segments[a][1] - segments[b][1])) < 0 {
+					count++
+				}
+			}
+		}
+	}
+
+	return count
+}
+
+// sortByBarycenter orders a layer by the mean
+// position of each node's neighbors in the
+// adjacent layer. Nodes without neighbors keep
+// their current position.
+func sortByBarycenter(layer []*layoutNode, downwards bool) {
+
+	barycenter := make(map[*layoutNode]float64, len(layer))
+
+	for _, n := range layer {
+
+		neighbors := n.up
+		if !downwards {
+			neighbors = n.down
+		}
+
+		if len(neighbors) == 0 {
+			barycenter[n] = float64(n.order)
+			continue
+		}
+
+		sum := 0.0
+		for _, neighbor := range neighbors {
+			sum += float64(neighbor.order)
+		}
+		barycenter[n] = sum / float64(len(neighbors))
+	}
+
+	sort.SliceStable(layer, func(i, j int) bool {
+		return barycenter[layer[i]] < barycenter[layer[j]]
+	})
+
+	for i, n := range layer {
+		n.order = i
+	}
+}
+
+// orderLayers reduces edge crossings by alternately
+// sweeping down and up the layers, keeping the best
+// ordering found.
+func (l *layout) orderLayers() {
+
+	snapshot := func() [][]*layoutNode {
+
+		copied := make([][]*layoutNode, len(l.layers))
+		for i := range l.layers {
+			copied[i] = append([]*layoutNode(nil), l.layers[i]...)
+		}
+
+		return copied
+	}
+
+	best := snapshot()
+	bestCrossings := l.crossings()
+
+	for sweep := 0; (sweep < layoutSweeps) && (bestCrossings > 0); sweep++ {
+
+		if (sweep % 2) == 0 {
+
+			for i := 1; i < len(l.layers); i++ {
+				sortByBarycenter(l.layers[i], true)
+			}
+		} else {
+
+			for i := (len(l.layers) - 2); i >= 0; i-- {
+				sortByBarycenter(l.layers[i], false)
+			}
+		}
+
+		if c := l.crossings(); c < bestCrossings {
+			best = snapshot()
+			bestCrossings = c
+		}
+	}
+
+	l.layers = best
+	for _, layer := range l.layers {
+		for i, n := range layer {
+			n.order = i
+		}
+	}
+}
+
+// placeLayer moves the nodes of a layer as close as
+// possible to their desired horizontal positions
+// while keeping order and minimum distances. It
+// averages a left-packed and a right-packed solution.
+func placeLayer(layer []*layoutNode, desired []float64) {
+
+	if len(layer) == 0 {
+		return
+	}
+
+	sep := func(i int) float64 {
+		return ((layer[i-1].width + layer[i].width) / 2.0) + layoutNodeSep
+	}
+
+	left := append([]float64(nil), desired...)
+	for i := 1; i < len(layer); i++ {
+		left[i] = math.Max(left[i], (left[i-1] + sep(i)))
+	}
+
+	right := append([]float64(nil), desired...)
+	for i := (len(layer) - 2); i >= 0; i-- {
+		right[i] = math.Min(right[i], (right[i+1] - sep(i+1)))
+	}
+
+	for i, n := range layer {
+		n.x = (left[i] + right[i]) / 2.0
+	}
+}
+
+// assignCoordinates computes positions of all nodes.
+func (l *layout) assignCoordinates() {
+
+	// Start from nodes packed to the left.
+	for _, layer := range l.layers {
+
+		x := 0.0
+		for i, n := range layer {
+
+			if i > 0 {
+				x += ((layer[i-1].width + n.width) / 2.0) + layoutNodeSep
+			}
+			n.x = x
+		}
+	}
+
+	// Pull nodes towards their neighbors.
+	for sweep := 0; sweep < layoutSweeps; sweep++ {
+
+		downwards := (sweep % 2) == 0
+
+		for k := range l.layers {
+
+			i := k
+			if !downwards {
+				i = len(l.layers) - 1 - k
+			}
+
+			desired := make([]float64, len(l.layers[i]))
+
+			for j, n := range l.layers[i] {
+
+				neighbors := n.up
+				if !downwards {
+					neighbors = n.down
+				}
+
+				desired[j] = n.x
+				if len(neighbors) > 0 {
+
+					sum := 0.0
+					for _, neighbor := range neighbors {
+						sum += neighbor.x
+					}
+					desired[j] = sum / float64(len(neighbors))
+				}
+			}
+
+			placeLayer(l.layers[i], desired)
+		}
+	}
+
+	// Stack layers vertically.
+	minX := math.Inf(1)
+	maxX := math.Inf(-1)
+	y := layoutMargin
+
+	for _, layer := range l.layers {
+
+		height := 0.0
+		for _, n := range layer {
+			height = math.Max(height, n.height)
+		}
+
+		for _, n := range layer {
+			n.y = y + (height / 2.0)
+			minX = math.Min(minX, (n.x - (n.width / 2.0)))
+			maxX = math.Max(maxX, (n.x + (n.width / 2.0)))
+		}
+
+		y += height + layoutRankSep
+	}
+
+	if len(l.nodes) == 0 {
+		minX = 0.0
+		maxX = 0.0
+	}
+
+	for _, layer := range l.layers {
+		for _, n := range layer {
+			n.x = n.x - minX + layoutMargin
+		}
+	}
+
+	l.width = (maxX - minX) + (2.0 * layoutMargin)
+	l.height = y - layoutRankSep + layoutMargin
+}
+
+// computeLayout lays out a DOT graph from top to
+// bottom, or from left to right if rankdir says so.
+func computeLayout(dotGraph *gographviz.Graph) *layout {
+
+	l := newLayout(dotGraph)
+	leftToRight := l.attrs["rankdir"] == "LR"
+
+	// Left-to-right layouts are computed top to
+	// bottom on swapped dimensions and transposed.
+	if leftToRight {
+		for _, n := range l.nodes {
+			n.width, n.height = n.height, n.width
+		}
+	}
+
+	l.removeCycles()
+	l.assignLayers()
+	l.insertDummies()
+	l.orderLayers()
+	l.assignCoordinates()
+
+	if leftToRight {
+
+		for _, layer := range l.layers {
+			for _, n := range layer {
+				n.x, n.y = n.y, n.x
+				n.width, n.height = n.height, n.width
+			}
+		}
+
+		l.width, l.height = l.height, l.width
+	}
+
+	return l
+}
@@ -0,0 +1,602 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"io/ioutil"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/awalterschulze/gographviz"
+	fi "github.com/numbleroot/nemo/faultinjectors"
+)
+
+// Structs.
+
+// Markdown is a Reporter writing a concise summary of
+// the debugging results to report.md, or, if Plain is
+// set, without any markup to report.txt. It does not
+// render figures.
+type Markdown struct {
+	Plain  bool
+	resDir string
+}
+
+// textWriter builds a document either in Markdown
+// or in plain text.
+type textWriter struct {
+	plain bool
+	buf   bytes.Buffer
+}
+
+// failureClass groups failed runs that are
+// missing the same events.
+type failureClass struct {
+	runs []*fi.Run
+}
+
+// Regular expressions converting the HTML snippets
+// of recommendations to Markdown.
+var (
+	htmlBreakRegex = regexp.MustCompile(`<br\s*/?>`)
+	htmlCodeRegex  = regexp.MustCompile(`<(?:code|pre)>(.*?)(?:</(?:code|pre)>|$)`)
+	htmlBoldRegex  = regexp.MustCompile(`<(?:b|strong)>(.*?)</(?:b|strong)>`)
+	htmlArrowRegex = regexp.MustCompile(`<i class = "fas fa-long-arrow-alt-right"></i>`)
+	htmlTagRegex   = regexp.MustCompile(`<[^>]+>`)
+	spacesRegex    = regexp.MustCompile(`\s+`)
+)
+
+// Functions.
+
+// htmlToText converts an HTML snippet as used in
+// recommendations to Markdown or plain text. Line
+// breaks are kept, one returned line per break.
+func htmlToText(snippet string, plain bool) []string {
+
+	lines := htmlBreakRegex.Split(snippet, -1)
+	converted := make([]string, 0, len(lines))
+
+	for _, line := range lines {
+
+		line = htmlArrowRegex.ReplaceAllString(line, "->")
+
+		if plain {
+			line = htmlCodeRegex.ReplaceAllString(line, "$1")
+			line = htmlBoldRegex.ReplaceAllString(line, "$1")
+		} else {
+			line = htmlCodeRegex.ReplaceAllString(line, "`$1`")
+			line = htmlBoldRegex.ReplaceAllString(line, "**$1**")
+		}
+
+		line = htmlTagRegex.ReplaceAllString(line, "")
+		line = strings.Replace(html.UnescapeString(line), "\u00a0", " ", -1)
+		line = strings.TrimSpace(spacesRegex.ReplaceAllString(line, " "))
+
+		if line != "" {
+			converted = append(converted, line)
+		}
+	}
+
+	return converted
+}
+
+// heading adds a heading of the supplied level.
+func (w *textWriter) heading(level int, text string) {
+
+	if w.plain {
+
+		underline := "-"
+		if level == 1 {
+			underline = "="
+		}
+
+		fmt.Fprintf(&w.buf, "%s\n%s\n\n", text, strings.Repeat(underline, len(text)))
+		return
+	}
+
+	fmt.Fprintf(&w.buf, "%s %s\n\n", strings.Repeat("#", level), text)
+}
+
+// paragraph adds a block of text.
+func (w *textWriter) paragraph(text string) {
+	fmt.Fprintf(&w.buf, "%s\n\n", text)
+}
+
+// item adds a list item at the supplied nesting
+// depth, with further lines indented below it.
+func (w *textWriter) item(depth int, lines []string) {
+
+	indent := strings.Repeat("  ", depth)
+
+	for i, line := range lines {
+
+		// Markdown needs two trailing spaces
+		// to keep line breaks within items.
+		end := "\n"
+		if !w.plain && (i < (len(lines) - 1)) {
+			end = "  \n"
+		}
+
+		if i == 0 {
+			fmt.Fprintf(&w.buf, "%s- %s%s", indent, line, end)
+		} else {
+			fmt.Fprintf(&w.buf, "%s    %s%s", indent, line, end)
+		}
+	}
+}
+
+// endList ends a list.
+func (w *textWriter) endList() {
+	fmt.Fprintf(&w.buf, "\n")
+}
+
+// code marks text as code in Markdown.
+func (w *textWriter) code(text string) string {
+
+	if w.plain {
+		return text
+	}
+
+	return fmt.Sprintf("`%s`", text)
+}
+
+// bold emphasizes text in Markdown.
+func (w *textWriter) bold(text string) string {
+
+	if w.plain {
+		return text
+	}
+
+	return fmt.Sprintf("**%s**", text)
+}
+
+// html adds all lines of an HTML snippet as list item.
+func (w *textWriter) html(depth int, snippet string) {
+
+	lines := htmlToText(snippet, w.plain)
+	if len(lines) > 0 {
+		w.item(depth, lines)
+	}
+}
+
+// table adds a table with a header row.
+func (w *textWriter) table(header []string, rows [][]string) {
+
+	if w.plain {
+
+		tw := tabwriter.NewWriter(&w.buf, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "%s\n", strings.Join(header, "\t"))
+		for _, row := range rows {
+			fmt.Fprintf(tw, "%s\n", strings.Join(row, "\t"))
+		}
+		tw.Flush()
+
+		fmt.Fprintf(&w.buf, "\n")
+		return
+	}
+
+	separator := make([]string, len(header))
+	for i := range separator {
+		separator[i] = "---"
+	}
+
+	fmt.Fprintf(&w.buf, "| %s |\n", strings.Join(header, " | "))
+	fmt.Fprintf(&w.buf, "| %s |\n", strings.Join(separator, " | "))
+	for _, row := range rows {
+
+		cells := make([]string, len(row))
+		for i := range row {
+			cells[i] = strings.Replace(row[i], "|", "\\|", -1)
+		}
+
+		fmt.Fprintf(&w.buf, "| %s |\n", strings.Join(cells, " | "))
+	}
+
+	fmt.Fprintf(&w.buf, "\n")
+}
+
+// formatFaults returns the injected crashes and
+// message losses of a run in short notation.
+func formatFaults(spec *fi.FailureSpec) (string, string) {
+
+	crashes := make([]string, 0)
+	omissions := make([]string, 0)
+
+	if spec == nil {
+		return "-", "-"
+	}
+
+	if spec.Crashes != nil {
+		for _, crash := range *spec.Crashes {
+			crashes = append(crashes, fmt.Sprintf("%s@%d", crash.Node, crash.Time))
+		}
+	}
+
+	if spec.Omissions != nil {
+		for _, loss := range *spec.Omissions {
+			omissions = append(omissions, fmt.Sprintf("%s -> %s @ %d", loss.From, loss.To, loss.Time))
+		}
+	}
+
+	if len(crashes) == 0 {
+		crashes = append(crashes, "-")
+	}
+
+	if len(omissions) == 0 {
+		omissions = append(omissions, "-")
+	}
+
+	return strings.Join(crashes, ", "), strings.Join(omissions, ", ")
+}
+
+// formatPasses lists the passes that simplified
+// antecedent and consequent provenance of a run.
+func formatPasses(simplifiedBy map[string][]string) string {
+
+	conds := make([]string, 0, 2)
+	for _, cond := range []string{"pre", "post"} {
+
+		passes := "-"
+		if len(simplifiedBy[cond]) > 0 {
+			passes = strings.Join(simplifiedBy[cond], ", ")
+		}
+
+		conds = append(conds, fmt.Sprintf("%s: %s", cond, passes))
+	}
+
+	return strings.Join(conds, "; ")
+}
+
+// classSignature identifies the failure class of a
+// failed run by its missing events and prototype
+// differences.
+func classSignature(run *fi.Run) string {
+
+	parts := make([]string, 0, len(run.MissingEvents))
+
+	for _, missing := range run.MissingEvents {
+
+		goals := make([]string, len(missing.Goals))
+		for i, goal := range missing.Goals {
+			goals[i] = goal.Label
+		}
+		sort.Strings(goals)
+
+		table := ""
+		if missing.Rule != nil {
+			table = missing.Rule.Table
+		}
+
+		parts = append(parts, fmt.Sprintf("%s:%s", table, strings.Join(goals, ";")))
+	}
+	sort.Strings(parts)
+
+	return fmt.Sprintf("%s|%s|%s", strings.Join(parts, ","), strings.Join(run.InterProtoMissing, ","), strings.Join(run.UnionProtoMissing, ","))
+}
+
+// failureClasses groups all failed runs into
+// classes, in order of their first run.
+func failureClasses(runs []*fi.Run) []*failureClass {
+
+	classes := make([]*failureClass, 0, 3)
+	bySignature := make(map[string]*failureClass)
+
+	for _, run := range runs {
+
+		if (run == nil) || (run.Status == "success") {
+			continue
+		}
+
+		signature := classSignature(run)
+
+		class, found := bySignature[signature]
+		if !found {
+			class = &failureClass{}
+			bySignature[signature] = class
+			classes = append(classes, class)
+		}
+
+		class.runs = append(class.runs, run)
+	}
+
+	return classes
+}
+
+// Prepare creates the directory holding the report.
+func (m *Markdown) Prepare(resDir string) error {
+
+	m.resDir = resDir
+
+	return os.MkdirAll(resDir, 0755)
+}
+
+// GenerateFigure is a no-op, summaries do not contain figures.
+func (m *Markdown) GenerateFigure(fileName string, dotProv *gographviz.Graph) error {
+	return nil
+}
+
+// GenerateFigures is a no-op, summaries do not contain figures.
+func (m *Markdown) GenerateFigures(iters []uint, name string, dotProvs []*gographviz.Graph) error {
+	return nil
+}
+
+// GenerateGraphs is a no-op, summaries do not contain figures.
+func (m *Markdown) GenerateGraphs(iters []uint, name string, provGraphs []*fi.ProvGraph) error {
+	return nil
+}
+
+// Finalize writes the summary of all runs and
+// returns the path of the written file.
+func (m *Markdown) Finalize(runs []*fi.Run) (string, error) {
+
+	w := &textWriter{plain: m.Plain}
+	classes := failureClasses(runs)
+
+	// Corrections and their verification are
+	// the same for all failed runs.
+	var corrections []string
+	var verifications []*fi.Verification
+	if len(classes) > 0 {
+		corrections = classes[0].runs[0].Corrections
+		verifications = classes[0].runs[0].Verifications
+	}
+
+	labels := make(map[string]string, len(verifications))
+	for _, v := range verifications {
+		labels[v.Correction] = v.Label
+	}
+
+	w.heading(1, "Nemo Debugging Report")
+
+	// All runs carry the same recommendation, we take
+	// it from the first one. If it lists corrections,
+	// these follow in their own section below.
+	if (len(runs) > 0) && (runs[0] != nil) && (len(runs[0].Recommendation) > 0) {
+
+		w.heading(2, "Recommendation")
+		w.paragraph(strings.Join(htmlToText(runs[0].Recommendation[0], w.plain), " "))
+
+		if (len(corrections) == 0) && (len(runs[0].Recommendation) > 1) {
+
+			for _, rec := range runs[0].Recommendation[1:] {
+				w.html(0, rec)
+			}
+
+			w.endList()
+		}
+	}
+
+	w.heading(2, "Runs")
+
+	// Only list passes if provenance was simplified.
+	simplified := false
+	for _, run := range runs {
+		simplified = simplified || ((run != nil) && (run.SimplifiedBy != nil))
+	}
+
+	rows := make([][]string, 0, len(runs))
+	for _, run := range runs {
+
+		if run == nil {
+			continue
+		}
+
+		crashes, omissions := formatFaults(run.FailureSpec)
+		row := []string{fmt.Sprintf("%d", run.Iteration), run.Status, crashes, omissions}
+
+		if simplified {
+			row = append(row, formatPasses(run.SimplifiedBy))
+		}
+
+		rows = append(rows, row)
+	}
+
+	header := []string{"Run", "Status", "Crashes", "Message losses"}
+	if simplified {
+		header = append(header, "Simplified by")
+	}
+
+	w.table(header, rows)
+
+	w.heading(2, "Failure Classes")
+
+	if len(classes) == 0 {
+		w.paragraph("No run violated the specification.")
+	} else {
+		failed := 0
+		for _, class := range classes {
+			failed += len(class.runs)
+		}
+
+		w.paragraph(fmt.Sprintf("%d failed run(s) fall into %d class(es) of runs missing the same events.", failed, len(classes)))
+	}
+
+	for i, class := range classes {
+
+		iters := make([]string, len(class.runs))
+		for j, run := range class.runs {
+			iters[j] = fmt.Sprintf("%d", run.Iteration)
+		}
+
+		w.heading(3, fmt.Sprintf("Class %d: run(s) %s", (i+1), strings.Join(iters, ", ")))
+
+		// Runs of one class share everything we report below.
+		run := class.runs[0]
+
+		if len(run.MissingEvents) > 0 {
+
+			w.paragraph(w.bold("Missing events") + " compared to the successful run:")
+
+			for _, missing := range run.MissingEvents {
+
+				table := "?"
+				if missing.Rule != nil {
+					table = missing.Rule.Table
+				}
+
+				w.item(0, []string{fmt.Sprintf("Rule %s needs to fire to achieve success, but the following events are not taking place:", w.code(table))})
+
+				for _, goal := range missing.Goals {
+					w.item(1, []string{w.code(fmt.Sprintf("%s @ %s", goal.Label, goal.Time))})
+				}
+			}
+
+			w.endList()
+		}
+
+		if len(run.RootCauses) > 0 {
+
+			w.paragraph(w.bold("Likely root causes") + fmt.Sprintf(" of run %d, most likely first:", run.Iteration))
+
+			rows := make([][]string, len(run.RootCauses))
+			for j, cause := range run.RootCauses {
+				rows[j] = []string{fmt.Sprintf("%d", (j + 1)), w.code(fmt.Sprintf("%s @ %s", cause.Event, cause.Time)), fmt.Sprintf("%.2f", cause.Score), strings.Join(cause.Reasons, "; ")}
+			}
+
+			w.table([]string{"Rank", "Event", "Score", "Reasons"}, rows)
+		}
+
+		// Runs of one class may diverge at different times.
+		diverged := make([]*fi.Run, 0, len(class.runs))
+		for _, r := range class.runs {
+
+			if (r.Divergence != nil) && (r.Divergence.Time >= 0) {
+				diverged = append(diverged, r)
+			}
+		}
+
+		if len(diverged) > 0 {
+
+			w.paragraph(w.bold("Divergence") + fmt.Sprintf(" from good run %d:", diverged[0].Divergence.GoodRun))
+
+			for _, r := range diverged {
+
+				div := r.Divergence
+				lines := []string{fmt.Sprintf("Run %d first derived different facts at time %d.", r.Iteration, div.Time)}
+
+				for _, fact := range div.Missing {
+					lines = append(lines, fmt.Sprintf("Missing: %s", w.code(fact)))
+				}
+
+				for _, fact := range div.Extra {
+					lines = append(lines, fmt.Sprintf("Extra: %s", w.code(fact)))
+				}
+
+				for _, fault := range div.Faults {
+					lines = append(lines, fmt.Sprintf("Preceded by: %s", w.code(fault)))
+				}
+
+				w.item(0, lines)
+			}
+
+			w.endList()
+		}
+
+		if len(run.InterProtoMissing) > 0 {
+
+			w.paragraph(w.bold("Certainly missing rules") + " (part of all successful runs):")
+
+			for _, rule := range run.InterProtoMissing {
+				w.html(0, rule)
+			}
+
+			w.endList()
+		}
+
+		if len(run.UnionProtoMissing) > 0 {
+
+			w.paragraph(w.bold("Possibly missing rules") + " (part of some successful run):")
+
+			for _, rule := range run.UnionProtoMissing {
+				w.html(0, rule)
+			}
+
+			w.endList()
+		}
+	}
+
+	if len(corrections) > 0 {
+
+		w.heading(2, "Correction Suggestions")
+
+		for _, corr := range corrections {
+
+			lines := htmlToText(corr, w.plain)
+			if label, found := labels[corr]; found {
+				lines = append(lines, fmt.Sprintf("Verification: %s", label))
+			}
+
+			w.item(0, lines)
+		}
+
+		w.endList()
+	}
+
+	// Patches passed in were verified as well.
+	patches := make([]*fi.Verification, 0, len(verifications))
+	for _, v := range verifications {
+
+		if (v.Patch != "") && strings.HasPrefix(v.Correction, "Patch ") {
+			patches = append(patches, v)
+		}
+	}
+
+	if len(patches) > 0 {
+
+		w.heading(2, "Patch Verification")
+
+		for _, v := range patches {
+
+			lines := []string{w.bold(v.Correction) + ": " + v.Label}
+			for _, rule := range strings.Split(v.Patch, "\n") {
+				lines = append(lines, w.code(rule))
+			}
+
+			w.item(0, lines)
+		}
+
+		w.endList()
+	}
+
+	if (len(runs) > 0) && (runs[0] != nil) && (len(runs[0].InterProto) > 0) {
+
+		w.heading(2, "Prototypes")
+		w.paragraph("Rules taking place in all successful runs (intersection):")
+
+		for _, rule := range runs[0].InterProto {
+			w.html(0, rule)
+		}
+
+		w.endList()
+
+		w.paragraph("Rules taking place in any successful run (union):")
+
+		for _, rule := range runs[0].UnionProto {
+			w.html(0, rule)
+		}
+
+		w.endList()
+	}
+
+	fileName := "report.md"
+	if m.Plain {
+		fileName = "report.txt"
+	}
+
+	reportPath := filepath.Join(m.resDir, fileName)
+
+	content := append(bytes.TrimRight(w.buf.Bytes(), "\n"), '\n')
+
+	err := ioutil.WriteFile(reportPath, content, 0644)
+	if err != nil {
+		return "", fmt.Errorf("Error writing out %s: %v", fileName, err)
+	}
+
+	return reportPath, nil
+}
@@ -0,0 +1,310 @@
+package report
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/awalterschulze/gographviz"
+	"github.com/numbleroot/nemo/dedalus"
+	fi "github.com/numbleroot/nemo/faultinjectors"
+)
+
+// Structs.
+
+// SARIF is a Reporter writing all specification
+// violations, corrections, and extensions to nemo.sarif
+// for consumption by CI systems. If Program is set,
+// results point at the rules of the analyzed program,
+// violations at the rule of consequent table PostTable.
+type SARIF struct {
+	Program   *dedalus.Program
+	PostTable string
+	resDir    string
+}
+
+// sarifLog is the top-level object of a SARIF 2.1.0 file.
+type sarifLog struct {
+	Schema  string      `json:"$schema"`
+	Version string      `json:"version"`
+	Runs    []*sarifRun `json:"runs"`
+}
+
+// sarifRun holds the results of one invocation
+// of Nemo, not to be confused with a fault
+// injection run.
+type sarifRun struct {
+	Tool    *sarifTool     `json:"tool"`
+	Results []*sarifResult `json:"results"`
+}
+
+// sarifTool describes Nemo and the rules
+// results refer to.
+type sarifTool struct {
+	Driver *sarifDriver `json:"driver"`
+}
+
+// sarifDriver
+type sarifDriver struct {
+	Name           string       `json:"name"`
+	InformationURI string       `json:"informationUri"`
+	Rules          []*sarifRule `json:"rules"`
+}
+
+// sarifRule
+type sarifRule struct {
+	ID               string        `json:"id"`
+	ShortDescription *sarifMessage `json:"shortDescription"`
+	DefaultLevel     *sarifLevel   `json:"defaultConfiguration"`
+}
+
+// sarifLevel
+type sarifLevel struct {
+	Level string `json:"level"`
+}
+
+// sarifMessage
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+// sarifResult
+type sarifResult struct {
+	RuleID     string                 `json:"ruleId"`
+	Level      string                 `json:"level"`
+	Message    *sarifMessage          `json:"message"`
+	Locations  []*sarifLocation       `json:"locations,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+// sarifLocation
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+// sarifPhysicalLocation
+type sarifPhysicalLocation struct {
+	ArtifactLocation *sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion           `json:"region"`
+}
+
+// sarifArtifactLocation
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// sarifRegion
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// Kinds of results Nemo reports.
+var sarifRules = []*sarifRule{
+	{
+		ID:               "nemo/violation",
+		ShortDescription: &sarifMessage{Text: "A fault injection run violated the specification."},
+		DefaultLevel:     &sarifLevel{Level: "error"},
+	},
+	{
+		ID:               "nemo/correction",
+		ShortDescription: &sarifMessage{Text: "Suggested change to make the protocol correct."},
+		DefaultLevel:     &sarifLevel{Level: "warning"},
+	},
+	{
+		ID:               "nemo/extension",
+		ShortDescription: &sarifMessage{Text: "Rule whose fault tolerance should be double-checked."},
+		DefaultLevel:     &sarifLevel{Level: "note"},
+	},
+}
+
+// snippetTableRegex matches the tables referenced
+// in code snippets of recommendations.
+var snippetTableRegex = regexp.MustCompile(`([a-z][A-Za-z0-9_]*)\(`)
+
+// Functions.
+
+// locateRule returns the rule of the program a
+// recommendation refers to. Suggested new rules
+// (ack_, buffer_) are located at the rules they
+// are derived from.
+func locateRule(prog *dedalus.Program, rec string) *dedalus.Rule {
+
+	if prog == nil {
+		return nil
+	}
+
+	for _, code := range htmlCodeRegex.FindAllStringSubmatch(rec, -1) {
+
+		for _, table := range snippetTableRegex.FindAllStringSubmatch(code[1], -1) {
+
+			candidates := []string{table[1]}
+			for _, prefix := range []string{"ack_", "buffer_"} {
+
+				if strings.HasPrefix(table[1], prefix) {
+					candidates = append(candidates, strings.TrimPrefix(table[1], prefix))
+				}
+			}
+
+			for _, candidate := range candidates {
+
+				rules := prog.RulesFor(candidate)
+				if len(rules) > 0 {
+					return rules[0]
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// locations returns the SARIF location of
+// rule, or none if rule is unknown.
+func (s *SARIF) locations(rule *dedalus.Rule) []*sarifLocation {
+
+	if rule == nil {
+		return nil
+	}
+
+	return []*sarifLocation{
+		{
+			PhysicalLocation: &sarifPhysicalLocation{
+				ArtifactLocation: &sarifArtifactLocation{URI: filepath.ToSlash(s.Program.File)},
+				Region:           &sarifRegion{StartLine: rule.Line},
+			},
+		},
+	}
+}
+
+// Prepare creates the directory holding the report.
+func (s *SARIF) Prepare(resDir string) error {
+
+	s.resDir = resDir
+
+	return os.MkdirAll(resDir, 0755)
+}
+
+// GenerateFigure is a no-op, SARIF does not contain figures.
+func (s *SARIF) GenerateFigure(fileName string, dotProv *gographviz.Graph) error {
+	return nil
+}
+
+// GenerateFigures is a no-op, SARIF does not contain figures.
+func (s *SARIF) GenerateFigures(iters []uint, name string, dotProvs []*gographviz.Graph) error {
+	return nil
+}
+
+// GenerateGraphs is a no-op, SARIF does not contain figures.
+func (s *SARIF) GenerateGraphs(iters []uint, name string, provGraphs []*fi.ProvGraph) error {
+	return nil
+}
+
+// Finalize writes one result per failed run and per
+// correction and extension, and returns the path of
+// the written file.
+func (s *SARIF) Finalize(runs []*fi.Run) (string, error) {
+
+	results := make([]*sarifResult, 0, len(runs))
+
+	// Violations point at the consequent.
+	var post *dedalus.Rule
+	if s.Program != nil {
+
+		rules := s.Program.RulesFor(s.PostTable)
+		if len(rules) > 0 {
+			post = rules[0]
+		}
+	}
+
+	var corrections []string
+	var extensions []string
+
+	for _, run := range runs {
+
+		if run == nil {
+			continue
+		}
+
+		// Corrections and extensions are the same
+		// for all runs they are attached to.
+		if len(run.Corrections) > 0 {
+			corrections = run.Corrections
+		}
+
+		if len(run.Extensions) > 0 {
+			extensions = run.Extensions
+		}
+
+		if run.Status == "success" {
+			continue
+		}
+
+		crashes, omissions := formatFaults(run.FailureSpec)
+
+		results = append(results, &sarifResult{
+			RuleID:    "nemo/violation",
+			Level:     "error",
+			Message:   &sarifMessage{Text: fmt.Sprintf("Run %d violated the specification (crashes: %s; message losses: %s).", run.Iteration, crashes, omissions)},
+			Locations: s.locations(post),
+			Properties: map[string]interface{}{
+				"iteration": run.Iteration,
+			},
+		})
+	}
+
+	for _, correction := range corrections {
+
+		results = append(results, &sarifResult{
+			RuleID:    "nemo/correction",
+			Level:     "warning",
+			Message:   &sarifMessage{Text: strings.Join(htmlToText(correction, true), "\n")},
+			Locations: s.locations(locateRule(s.Program, correction)),
+		})
+	}
+
+	for _, extension := range extensions {
+
+		results = append(results, &sarifResult{
+			RuleID:    "nemo/extension",
+			Level:     "note",
+			Message:   &sarifMessage{Text: fmt.Sprintf("Double-check the fault tolerance of: %s", strings.Join(htmlToText(extension, true), " "))},
+			Locations: s.locations(locateRule(s.Program, extension)),
+		})
+	}
+
+	log := &sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []*sarifRun{
+			{
+				Tool: &sarifTool{
+					Driver: &sarifDriver{
+						Name:           "Nemo",
+						InformationURI: "https://github.com/numbleroot/nemo",
+						Rules:          sarifRules,
+					},
+				},
+				Results: results,
+			},
+		},
+	}
+
+	data, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Failed to marshal SARIF log: %v", err)
+	}
+
+	sarifPath := filepath.Join(s.resDir, "nemo.sarif")
+
+	err = ioutil.WriteFile(sarifPath, data, 0644)
+	if err != nil {
+		return "", fmt.Errorf("Failed to write SARIF log: %v", err)
+	}
+
+	return sarifPath, nil
+}
@@ -0,0 +1,273 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+
+	"encoding/xml"
+
+	"github.com/awalterschulze/gographviz"
+)
+
+// Structs.
+
+// point is a position in an SVG drawing.
+type point struct {
+	x float64
+	y float64
+}
+
+// Functions.
+
+// escape returns text safe for use in SVG content.
+func escape(text string) string {
+
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(text))
+
+	return buf.String()
+}
+
+// svgColor maps a DOT color to an SVG color.
+func svgColor(color string, fallback string) string {
+
+	if color == "" {
+		return fallback
+	}
+
+	if color == "transparent" {
+		return "none"
+	}
+
+	return escape(color)
+}
+
+// strokeAttrs returns the SVG attributes
+// drawing a line in the style of a DOT object.
+func strokeAttrs(attrs map[string]string) string {
+
+	stroke := fmt.Sprintf("stroke=\"%s\"", svgColor(attrs["color"], "black"))
+
+	if hasStyle(attrs, "bold") {
+		stroke += " stroke-width=\"2\""
+	}
+
+	if hasStyle(attrs, "dashed") {
+		stroke += " stroke-dasharray=\"5,2\""
+	} else if hasStyle(attrs, "dotted") {
+		stroke += " stroke-dasharray=\"1,5\""
+	}
+
+	return stroke
+}
+
+// clip returns the point on the boundary of a node
+// in direction of the supplied point. Edges start
+// and end there instead of in the node's center.
+func clip(n *layoutNode, towards point) point {
+
+	dx := towards.x - n.x
+	dy := towards.y - n.y
+
+	if n.dummy || ((dx == 0) && (dy == 0)) {
+		return point{n.x, n.y}
+	}
+
+	w := n.width / 2.0
+	h := n.height / 2.0
+
+	switch n.attrs["shape"] {
+	case "rect", "box", "square", "plaintext", "none":
+
+		scale := math.Min((w / math.Abs(dx)), (h / math.Abs(dy)))
+		return point{(n.x + (dx * scale)), (n.y + (dy * scale))}
+	}
+
+	scale := 1.0 / math.Sqrt(((dx*dx)/(w*w))+((dy*dy)/(h*h)))
+
+	return point{(n.x + (dx * scale)), (n.y + (dy * scale))}
+}
+
+// writeText writes a possibly multi-line
+// label centered at the supplied position.
+func writeText(buf *bytes.Buffer, lines []string, x float64, y float64, color string) {
+
+	top := y - ((float64(len(lines)-1) * (layoutFontSize + 4.0)) / 2.0)
+
+	for i, line := range lines {
+		fmt.Fprintf(buf, "<text x=\"%.2f\" y=\"%.2f\" text-anchor=\"middle\" dominant-baseline=\"central\" fill=\"%s\">%s</text>\n",
+			x, (top + (float64(i) * (layoutFontSize + 4.0))), color, escape(line))
+	}
+}
+
+// writeNode draws a node with its label.
+func writeNode(buf *bytes.Buffer, n *layoutNode) {
+
+	fill := "none"
+	if hasStyle(n.attrs, "filled") {
+		fill = svgColor(n.attrs["fillcolor"], svgColor(n.attrs["color"], "lightgrey"))
+	}
+
+	shape := fmt.Sprintf("fill=\"%s\" %s", fill, strokeAttrs(n.attrs))
+
+	switch n.attrs["shape"] {
+	case "rect", "box", "square":
+		fmt.Fprintf(buf, "<rect x=\"%.2f\" y=\"%.2f\" width=\"%.2f\" height=\"%.2f\" %s/>\n",
+			(n.x - (n.width / 2.0)), (n.y - (n.height / 2.0)), n.width, n.height, shape)
+	case "plaintext", "none":
+	case "point":
+		fmt.Fprintf(buf, "<circle cx=\"%.2f\" cy=\"%.2f\" r=\"%.2f\" fill=\"%s\" %s/>\n",
+			n.x, n.y, (n.width / 2.0), svgColor(n.attrs["color"], "black"), strokeAttrs(n.attrs))
+		return
+	default:
+		fmt.Fprintf(buf, "<ellipse cx=\"%.2f\" cy=\"%.2f\" rx=\"%.2f\" ry=\"%.2f\" %s/>\n",
+			n.x, n.y, (n.width / 2.0), (n.height / 2.0), shape)
+	}
+
+	writeText(buf, nodeLabel(n), n.x, n.y, svgColor(n.attrs["fontcolor"], "black"))
+}
+
+// writeArrowhead draws an arrowhead ending in tip
+// pointing away from from. It returns the point
+// the line of the edge has to end in.
+func writeArrowhead(buf *bytes.Buffer, from point, tip point, color string) point {
+
+	const length = 10.0
+	const halfWidth = 3.5
+
+	dx := tip.x - from.x
+	dy := tip.y - from.y
+	dist := math.Sqrt((dx * dx) + (dy * dy))
+
+	if dist == 0 {
+		return tip
+	}
+
+	ux := dx / dist
+	uy := dy / dist
+
+	base := point{(tip.x - (ux * length)), (tip.y - (uy * length))}
+
+	fmt.Fprintf(buf, "<polygon points=\"%.2f,%.2f %.2f,%.2f %.2f,%.2f\" fill=\"%s\" stroke=\"%s\"/>\n",
+		tip.x, tip.y, (base.x - (uy * halfWidth)), (base.y + (ux * halfWidth)),
+		(base.x + (uy * halfWidth)), (base.y - (ux * halfWidth)), color, color)
+
+	return base
+}
+
+// writeEdge draws an edge along the
+// path computed for it during layout.
+func writeEdge(buf *bytes.Buffer, e *layoutEdge, directed bool) {
+
+	path := e.path
+	if e.reversed {
+
+		path = make([]*layoutNode, len(e.path))
+		for i := range e.path {
+			path[i] = e.path[(len(e.path) - 1 - i)]
+		}
+	}
+
+	points := make([]point, len(path))
+	for i := range path {
+		points[i] = point{path[i].x, path[i].y}
+	}
+
+	last := len(points) - 1
+	points[0] = clip(path[0], points[1])
+	points[last] = clip(path[last], points[(last-1)])
+
+	color := svgColor(e.attrs["color"], "black")
+
+	fmt.Fprintf(buf, "<g class=\"edge\">\n")
+
+	if directed && (e.attrs["dir"] != "none") {
+		points[last] = writeArrowhead(buf, points[(last-1)], points[last], color)
+	}
+
+	var d strings.Builder
+	for i, p := range points {
+
+		if i == 0 {
+			fmt.Fprintf(&d, "M%.2f,%.2f", p.x, p.y)
+		} else {
+			fmt.Fprintf(&d, " L%.2f,%.2f", p.x, p.y)
+		}
+	}
+
+	fmt.Fprintf(buf, "<path d=\"%s\" fill=\"none\" %s/>\n", d.String(), strokeAttrs(e.attrs))
+
+	// Place labels next to the middle of the edge.
+	if label, found := e.attrs["label"]; found && (label != "") {
+
+		mid := len(points) / 2
+		x := (points[(mid-1)].x + points[mid].x) / 2.0
+		y := (points[(mid-1)].y + points[mid].y) / 2.0
+
+		writeText(buf, strings.Split(label, "\\n"), (x + 4.0), y, svgColor(e.attrs["fontcolor"], "black"))
+	}
+
+	fmt.Fprintf(buf, "</g>\n")
+}
+
+// writeLoop draws an edge from a node to itself
+// as a curve on the right side of the node.
+func writeLoop(buf *bytes.Buffer, e *layoutEdge) {
+
+	n := e.from
+	right := n.x + (n.width / 2.0)
+	top := n.y - (n.height / 4.0)
+	bottom := n.y + (n.height / 4.0)
+
+	fmt.Fprintf(buf, "<path d=\"M%.2f,%.2f C%.2f,%.2f %.2f,%.2f %.2f,%.2f\" fill=\"none\" %s/>\n",
+		(right - 4.0), top, (right + 24.0), (top - 12.0), (right + 24.0), (bottom + 12.0), (right - 4.0), bottom, strokeAttrs(e.attrs))
+}
+
+// renderSVG lays out a DOT graph and returns it as
+// SVG document. Invisible nodes and edges are part
+// of the layout but not drawn, so that graphs sharing
+// all (partly invisible) objects can be overlaid.
+func renderSVG(dotGraph *gographviz.Graph) []byte {
+
+	l := computeLayout(dotGraph)
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "<?xml version=\"1.0\" encoding=\"UTF-8\" standalone=\"no\"?>\n")
+	fmt.Fprintf(&buf, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%.0fpt\" height=\"%.0fpt\" viewBox=\"0.00 0.00 %.2f %.2f\">\n",
+		math.Ceil(l.width), math.Ceil(l.height), l.width, l.height)
+	fmt.Fprintf(&buf, "<g font-family=\"Times,serif\" font-size=\"%.0f\">\n", layoutFontSize)
+
+	if bgcolor := svgColor(l.attrs["bgcolor"], "none"); bgcolor != "none" {
+		fmt.Fprintf(&buf, "<rect width=\"100%%\" height=\"100%%\" fill=\"%s\"/>\n", bgcolor)
+	}
+
+	for _, e := range l.edges {
+
+		if !hasStyle(e.attrs, "invis") {
+			writeEdge(&buf, e, l.directed)
+		}
+	}
+
+	for _, e := range l.loops {
+
+		if !hasStyle(e.attrs, "invis") {
+			writeLoop(&buf, e)
+		}
+	}
+
+	for _, n := range l.nodes {
+
+		if !hasStyle(n.attrs, "invis") {
+			fmt.Fprintf(&buf, "<g class=\"node\">\n")
+			writeNode(&buf, n)
+			fmt.Fprintf(&buf, "</g>\n")
+		}
+	}
+
+	fmt.Fprintf(&buf, "</g>\n</svg>\n")
+
+	return buf.Bytes()
+}
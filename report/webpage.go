@@ -5,51 +5,125 @@ import (
 	"os"
 	"strings"
 
+	"encoding/json"
+	"io/fs"
 	"io/ioutil"
 	"os/exec"
 	"path/filepath"
 
 	"github.com/awalterschulze/gographviz"
+	fi "github.com/numbleroot/nemo/faultinjectors"
 )
 
 // Structs.
 
 // Report
 type Report struct {
+	Renderer   string
 	resDir     string
 	figuresDir string
 }
 
 // Functions.
 
-// Prepare
-func (r *Report) Prepare(wrkDir string, allResDir string, thisResDir string) error {
+// Prepare creates the directory holding
+// the report and its figures.
+func (r *Report) Prepare(resDir string) error {
 
-	r.resDir = thisResDir
-	r.figuresDir = filepath.Join(thisResDir, "figures")
+	r.resDir = resDir
+	r.figuresDir = filepath.Join(resDir, "figures")
 
-	// Copy webpage template to result directory.
-	err := copyDir(filepath.Join(wrkDir, "report", "assets"), allResDir)
+	// Create directory to hold diagrams.
+	err := os.MkdirAll(r.figuresDir, 0755)
 	if err != nil {
 		return err
 	}
 
-	// Rename to final results directory name.
-	err = os.Rename(filepath.Join(allResDir, "assets"), thisResDir)
+	return nil
+}
+
+// Finalize writes the debugging information of all
+// runs to debugging.json and assembles the report
+// into one self-contained file index.html, with all
+// data, figures, scripts, and styles inlined. It
+// returns the path to that file.
+func (r *Report) Finalize(runs []*fi.Run) (string, error) {
+
+	// Marshal collected debugging information to JSON.
+	debuggingJSON, err := json.Marshal(runs)
 	if err != nil {
-		return err
+		return "", fmt.Errorf("Failed to marshal debugging information to JSON: %v", err)
 	}
 
-	// Create directory to hold diagrams.
-	err = os.MkdirAll(r.figuresDir, 0755)
+	// Write debugging JSON to file 'debugging.json'.
+	err = ioutil.WriteFile(filepath.Join(r.resDir, "debugging.json"), debuggingJSON, 0644)
 	if err != nil {
-		return err
+		return "", fmt.Errorf("Error writing out debugging.json: %v", err)
 	}
 
-	return nil
+	// Collect all files the webpage reads, keyed
+	// by their path relative to the report.
+	files := map[string]json.RawMessage{
+		"debugging.json": debuggingJSON,
+	}
+
+	figures, err := ioutil.ReadDir(r.figuresDir)
+	if err != nil {
+		return "", err
+	}
+
+	for _, figure := range figures {
+
+		name := fmt.Sprintf("figures/%s", figure.Name())
+
+		content, err := ioutil.ReadFile(filepath.Join(r.figuresDir, figure.Name()))
+		if err != nil {
+			return "", err
+		}
+
+		switch filepath.Ext(figure.Name()) {
+		case ".json":
+			files[name] = content
+		case ".svg":
+
+			// Figures are embedded as strings.
+			files[name], err = json.Marshal(string(content))
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
+	filesJSON, err := json.Marshal(files)
+	if err != nil {
+		return "", fmt.Errorf("Failed to marshal report files to JSON: %v", err)
+	}
+
+	template, err := fs.ReadFile(assets, "assets/index.html")
+	if err != nil {
+		return "", err
+	}
+
+	page, err := inlineAssets(string(template))
+	if err != nil {
+		return "", fmt.Errorf("Failed to inline assets into report: %v", err)
+	}
+
+	page = strings.Replace(page, "var nemoFiles = {};", fmt.Sprintf("var nemoFiles = %s;", filesJSON), 1)
+
+	pagePath := filepath.Join(r.resDir, "index.html")
+
+	err = ioutil.WriteFile(pagePath, []byte(page), 0644)
+	if err != nil {
+		return "", fmt.Errorf("Error writing out report: %v", err)
+	}
+
+	return pagePath, nil
 }
 
-// GenerateFigure renders a supplied dot graph.
+// GenerateFigure writes out a supplied dot graph and
+// renders it to SVG, either with the built-in layout
+// or with Graphviz' dot if Renderer is set to "dot".
 func (r *Report) GenerateFigure(fileName string, dotProv *gographviz.Graph) error {
 
 	dotFilePath := filepath.Join(r.figuresDir, fmt.Sprintf("%s.dot", fileName))
@@ -61,15 +135,30 @@ func (r *Report) GenerateFigure(fileName string, dotProv *gographviz.Graph) erro
 		return err
 	}
 
-	// Run SVG generator on DOT file.
-	cmd := exec.Command("dot", "-Tsvg", "-o", svgFilePath, dotFilePath)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return err
-	}
+	switch r.Renderer {
+	case "", "builtin":
+
+		// Lay out and draw graph ourselves.
+		err = ioutil.WriteFile(svgFilePath, renderSVG(dotProv), 0644)
+		if err != nil {
+			return err
+		}
+
+	case "dot":
+
+		// Run SVG generator on DOT file.
+		cmd := exec.Command("dot", "-Tsvg", "-o", svgFilePath, dotFilePath)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return err
+		}
+
+		if strings.TrimSpace(string(out)) != "" {
+			return fmt.Errorf("Wrong return value from SVG generation command: %s", out)
+		}
 
-	if strings.TrimSpace(string(out)) != "" {
-		return fmt.Errorf("Wrong return value from SVG generation command: %s", out)
+	default:
+		return fmt.Errorf("Unknown renderer '%s', choose 'builtin' or 'dot'", r.Renderer)
 	}
 
 	return nil
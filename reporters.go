@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/awalterschulze/gographviz"
+	"github.com/numbleroot/nemo/dedalus"
+	fi "github.com/numbleroot/nemo/faultinjectors"
+	re "github.com/numbleroot/nemo/report"
+)
+
+// Structs.
+
+// reporters passes all calls on to
+// each of the contained Reporters.
+type reporters []Reporter
+
+// Functions.
+
+// newReporter returns the Reporter writing all
+// requested kinds of reports, a comma-separated list
+// of 'html', 'markdown', 'text', 'sarif', and 'junit'.
+// CI reports are named after the analyzed output and
+// point at the rules of prog, if available, violations
+// at the rule of consequent table postTable.
+func newReporter(kinds string, renderer string, prog *dedalus.Program, postTable string, name string) (Reporter, error) {
+
+	reps := make(reporters, 0, 5)
+
+	for _, kind := range strings.Split(kinds, ",") {
+
+		switch strings.TrimSpace(kind) {
+		case "html":
+			reps = append(reps, &re.Report{Renderer: renderer})
+		case "markdown":
+			reps = append(reps, &re.Markdown{})
+		case "text":
+			reps = append(reps, &re.Markdown{Plain: true})
+		case "sarif":
+			reps = append(reps, &re.SARIF{Program: prog, PostTable: postTable})
+		case "junit":
+			reps = append(reps, &re.JUnit{Name: name})
+		default:
+			return nil, fmt.Errorf("Unknown report '%s', choose from 'html', 'markdown', 'text', 'sarif', and 'junit'", kind)
+		}
+	}
+
+	return reps, nil
+}
+
+// Prepare
+func (reps reporters) Prepare(resDir string) error {
+
+	for _, rep := range reps {
+
+		err := rep.Prepare(resDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GenerateFigure
+func (reps reporters) GenerateFigure(fileName string, dotProv *gographviz.Graph) error {
+
+	for _, rep := range reps {
+
+		err := rep.GenerateFigure(fileName, dotProv)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GenerateFigures
+func (reps reporters) GenerateFigures(iters []uint, name string, dotProvs []*gographviz.Graph) error {
+
+	for _, rep := range reps {
+
+		err := rep.GenerateFigures(iters, name, dotProvs)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GenerateGraphs
+func (reps reporters) GenerateGraphs(iters []uint, name string, provGraphs []*fi.ProvGraph) error {
+
+	for _, rep := range reps {
+
+		err := rep.GenerateGraphs(iters, name, provGraphs)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Finalize returns the paths of all written
+// reports, separated by commas.
+func (reps reporters) Finalize(runs []*fi.Run) (string, error) {
+
+	paths := make([]string, 0, len(reps))
+
+	for _, rep := range reps {
+
+		path, err := rep.Finalize(runs)
+		if err != nil {
+			return "", err
+		}
+
+		paths = append(paths, path)
+	}
+
+	return strings.Join(paths, ", "), nil
+}
@@ -0,0 +1,861 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+
+	cf "github.com/numbleroot/nemo/config"
+	fi "github.com/numbleroot/nemo/faultinjectors"
+)
+
+// Structs.
+
+// server hosts the reports of all analyzed fault
+// injector outputs in one results directory and
+// answers queries about them via a JSON API.
+type server struct {
+	resultsDir  string
+	uploadsDir  string
+	maxUpload   int64
+	graphDBConn string
+	renderer    string
+	report      string
+	cfg         *cf.Config
+
+	// All analyses share one graph database,
+	// thus only one may run at a time.
+	analysisLock sync.Mutex
+
+	jobsLock sync.Mutex
+	jobs     []*job
+}
+
+// job is an analysis of an uploaded fault injector
+// output, triggered via the API.
+type job struct {
+	ID        int    `json:"id"`
+	Execution string `json:"execution"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	Report    string `json:"report,omitempty"`
+}
+
+// execution summarizes one analyzed fault injector
+// output, i.e., one Molly execution. Executions
+// analyzed for several invariants are listed once
+// per invariant, named <execution>:<invariant>.
+type execution struct {
+	Name       string `json:"name"`
+	Invariant  string `json:"invariant,omitempty"`
+	Runs       int    `json:"runs"`
+	FailedRuns int    `json:"failedRuns"`
+	Report     string `json:"report"`
+}
+
+// runSummary is the short form of a run in listings.
+type runSummary struct {
+	Iteration   uint            `json:"iteration"`
+	Status      string          `json:"status"`
+	FailureSpec *fi.FailureSpec `json:"failureSpec"`
+}
+
+// recommendations bundles all suggestions Nemo made
+// for one execution.
+type recommendations struct {
+	Recommendation []string `json:"recommendation"`
+	Corrections    []string `json:"corrections"`
+	Extensions     []string `json:"extensions"`
+}
+
+// prototypes lists the rules of all successful runs
+// (intersection) and any successful run (union), and
+// which of them each failed run is missing.
+type prototypes struct {
+	InterProto []string               `json:"interProto"`
+	UnionProto []string               `json:"unionProto"`
+	Missing    map[uint]*protoMissing `json:"missing"`
+}
+
+// protoMissing
+type protoMissing struct {
+	InterProtoMissing []string `json:"interProtoMissing"`
+	UnionProtoMissing []string `json:"unionProtoMissing"`
+}
+
+// Provenance graphs served, by variant and condition.
+var provVariants = map[string]bool{
+	"raw/pre":    true,
+	"raw/post":   true,
+	"clean/pre":  true,
+	"clean/post": true,
+	"diff/post":  true,
+}
+
+// Functions.
+
+// writeJSON responds with v encoded as JSON.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Printf("Failed to write response: %v", err)
+	}
+}
+
+// writeError responds with an error message as JSON.
+func writeError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	writeJSON(w, status, map[string]string{"error": fmt.Sprintf(format, args...)})
+}
+
+// executionDir returns the results directory of
+// the named execution, if it has been analyzed.
+func (s *server) executionDir(name string) (string, bool) {
+
+	dir := s.resultsDir
+
+	// Each invariant has its own directory.
+	for _, part := range strings.SplitN(name, ":", 2) {
+
+		if (part == "") || (part == ".") || (part == "..") || (filepath.Base(part) != part) {
+			return "", false
+		}
+
+		dir = filepath.Join(dir, part)
+	}
+
+	_, err := os.Stat(filepath.Join(dir, "stages", "output.json"))
+	if err != nil {
+		return "", false
+	}
+
+	return dir, true
+}
+
+// loadRuns reads all runs of the named execution as
+// stored by the analysis. With results, it enriches
+// them with the stored results of all stages, which
+// it returns as well.
+func (s *server) loadRuns(name string, withResults bool) ([]*fi.Run, *stageResults, error) {
+
+	dir, found := s.executionDir(name)
+	if !found {
+		return nil, nil, os.ErrNotExist
+	}
+
+	debugRun := &DebugRun{thisResultsDir: dir}
+
+	output, found, err := debugRun.restoreOutput()
+	if err != nil {
+		return nil, nil, err
+	} else if !found {
+		return nil, nil, os.ErrNotExist
+	}
+
+	results := &stageResults{}
+	if !withResults {
+		return output.Runs, results, nil
+	}
+
+	err = debugRun.restoreAll(results)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	iters, failedIters := runsIters(output.Runs)
+	enrichRuns(output.Runs, iters, failedIters, results, s.cfg.TopCauses)
+
+	return output.Runs, results, nil
+}
+
+// runsIters returns the iterations of all runs and
+// of those that violated the specification.
+func runsIters(runs []*fi.Run) ([]uint, []uint) {
+
+	iters := make([]uint, 0, len(runs))
+	failedIters := make([]uint, 0, len(runs))
+
+	for _, run := range runs {
+
+		if run == nil {
+			continue
+		}
+
+		iters = append(iters, run.Iteration)
+		if run.Status != "success" {
+			failedIters = append(failedIters, run.Iteration)
+		}
+	}
+
+	return iters, failedIters
+}
+
+// findRun returns the run with the given iteration.
+func findRun(runs []*fi.Run, iteration string) *fi.Run {
+
+	iter, err := strconv.ParseUint(iteration, 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	for i := range runs {
+
+		if (runs[i] != nil) && (runs[i].Iteration == uint(iter)) {
+			return runs[i]
+		}
+	}
+
+	return nil
+}
+
+// executions lists all analyzed executions.
+func (s *server) executions() ([]*execution, error) {
+
+	entries, err := ioutil.ReadDir(s.resultsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*execution{}, nil
+		}
+		return nil, err
+	}
+
+	execs := make([]*execution, 0, len(entries))
+
+	for _, entry := range entries {
+
+		if !entry.IsDir() {
+			continue
+		}
+
+		names := []string{entry.Name()}
+
+		// Without runs of its own, an execution
+		// holds the results of several invariants.
+		if _, found := s.executionDir(entry.Name()); !found {
+
+			subEntries, err := ioutil.ReadDir(filepath.Join(s.resultsDir, entry.Name()))
+			if err != nil {
+				continue
+			}
+
+			names = make([]string, 0, len(subEntries))
+			for _, subEntry := range subEntries {
+
+				if subEntry.IsDir() {
+					names = append(names, fmt.Sprintf("%s:%s", entry.Name(), subEntry.Name()))
+				}
+			}
+		}
+
+		for _, name := range names {
+
+			runs, _, err := s.loadRuns(name, false)
+			if err != nil {
+				continue
+			}
+
+			exec := &execution{
+				Name:   name,
+				Report: fmt.Sprintf("/reports/%s/", strings.Replace(name, ":", "/", 1)),
+			}
+
+			if parts := strings.SplitN(name, ":", 2); len(parts) == 2 {
+				exec.Invariant = parts[1]
+			}
+
+			for i := range runs {
+
+				if runs[i] == nil {
+					continue
+				}
+
+				exec.Runs++
+				if runs[i].Status != "success" {
+					exec.FailedRuns++
+				}
+			}
+
+			execs = append(execs, exec)
+		}
+	}
+
+	return execs, nil
+}
+
+// handleIndex lists links to the reports of all executions.
+func (s *server) handleIndex(w http.ResponseWriter, r *http.Request) {
+
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	execs, err := s.executions()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head><meta charset = \"utf-8\" /><title>Nemo</title></head>\n<body>\n<h1>Nemo Debugging Reports</h1>\n<ul>\n")
+	for _, exec := range execs {
+		fmt.Fprintf(w, "<li><a href = \"%s\">%s</a> (%d runs, %d failed)</li>\n", html.EscapeString(exec.Report), html.EscapeString(exec.Name), exec.Runs, exec.FailedRuns)
+	}
+	fmt.Fprintf(w, "</ul>\n</body>\n</html>\n")
+}
+
+// handleExecutions lists all analyzed executions on
+// GET and accepts new fault injector output on POST.
+func (s *server) handleExecutions(w http.ResponseWriter, r *http.Request) {
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		s.handleUpload(w, r)
+		return
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method %s not allowed", r.Method)
+		return
+	}
+
+	execs, err := s.executions()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to list executions: %v", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, execs)
+}
+
+// handleExecution routes requests below /api/executions/{name}
+// to the handlers for the runs of that execution.
+func (s *server) handleExecution(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method %s not allowed", r.Method)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/executions/"), "/"), "/")
+	name := parts[0]
+
+	runs, results, err := s.loadRuns(name, true)
+	if os.IsNotExist(err) {
+		writeError(w, http.StatusNotFound, "Unknown execution '%s'", name)
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+
+	switch {
+	case (len(parts) == 2) && (parts[1] == "runs"):
+		s.handleRuns(w, runs)
+		return
+	case (len(parts) == 2) && (parts[1] == "recommendations"):
+		s.handleRecommendations(w, runs)
+		return
+	case (len(parts) == 2) && (parts[1] == "prototypes"):
+		s.handlePrototypes(w, runs)
+		return
+	case ((len(parts) == 3) || (len(parts) == 4)) && (parts[1] == "runs"):
+
+		run := findRun(runs, parts[2])
+		if run == nil {
+			writeError(w, http.StatusNotFound, "Unknown run '%s'", parts[2])
+			return
+		}
+
+		if len(parts) == 3 {
+			writeJSON(w, http.StatusOK, run)
+			return
+		}
+
+		switch parts[3] {
+		case "missing":
+			s.handleMissing(w, run)
+			return
+		case "provenance":
+			s.handleProvenance(w, r, runs, results, run)
+			return
+		}
+	}
+
+	writeError(w, http.StatusNotFound, "Unknown resource '%s'", r.URL.Path)
+}
+
+// handleRuns lists all runs of an execution.
+func (s *server) handleRuns(w http.ResponseWriter, runs []*fi.Run) {
+
+	summaries := make([]*runSummary, 0, len(runs))
+
+	for i := range runs {
+
+		if runs[i] == nil {
+			continue
+		}
+
+		summaries = append(summaries, &runSummary{
+			Iteration:   runs[i].Iteration,
+			Status:      runs[i].Status,
+			FailureSpec: runs[i].FailureSpec,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, summaries)
+}
+
+// handleMissing returns the events missing from a failed run.
+func (s *server) handleMissing(w http.ResponseWriter, run *fi.Run) {
+
+	missing := run.MissingEvents
+	if missing == nil {
+		missing = []*fi.Missing{}
+	}
+
+	writeJSON(w, http.StatusOK, missing)
+}
+
+// handleRecommendations returns the recommendation,
+// corrections, and extensions of an execution.
+func (s *server) handleRecommendations(w http.ResponseWriter, runs []*fi.Run) {
+
+	recs := &recommendations{
+		Recommendation: []string{},
+		Corrections:    []string{},
+		Extensions:     []string{},
+	}
+
+	for i := range runs {
+
+		if runs[i] == nil {
+			continue
+		}
+
+		// All runs carry the same suggestions.
+		if len(runs[i].Recommendation) > 0 {
+			recs.Recommendation = runs[i].Recommendation
+		}
+
+		if len(runs[i].Corrections) > 0 {
+			recs.Corrections = runs[i].Corrections
+		}
+
+		if len(runs[i].Extensions) > 0 {
+			recs.Extensions = runs[i].Extensions
+		}
+	}
+
+	writeJSON(w, http.StatusOK, recs)
+}
+
+// handlePrototypes returns the prototypes of an
+// execution and what failed runs are missing.
+func (s *server) handlePrototypes(w http.ResponseWriter, runs []*fi.Run) {
+
+	protos := &prototypes{
+		InterProto: []string{},
+		UnionProto: []string{},
+		Missing:    make(map[uint]*protoMissing),
+	}
+
+	for i := range runs {
+
+		if runs[i] == nil {
+			continue
+		}
+
+		if runs[i].InterProto != nil {
+			protos.InterProto = runs[i].InterProto
+		}
+
+		if runs[i].UnionProto != nil {
+			protos.UnionProto = runs[i].UnionProto
+		}
+
+		if runs[i].Status != "success" {
+
+			protos.Missing[runs[i].Iteration] = &protoMissing{
+				InterProtoMissing: runs[i].InterProtoMissing,
+				UnionProtoMissing: runs[i].UnionProtoMissing,
+			}
+		}
+	}
+
+	writeJSON(w, http.StatusOK, protos)
+}
+
+// handleProvenance returns one provenance graph of a
+// run, selected by variant ('raw', 'clean', 'diff')
+// and condition ('pre', 'post') query parameters.
+func (s *server) handleProvenance(w http.ResponseWriter, r *http.Request, runs []*fi.Run, results *stageResults, run *fi.Run) {
+
+	variant := r.URL.Query().Get("variant")
+	if variant == "" {
+		variant = "clean"
+	}
+
+	condition := r.URL.Query().Get("condition")
+	if condition == "" {
+		condition = "post"
+	}
+
+	key := fmt.Sprintf("%s/%s", variant, condition)
+	if !provVariants[key] {
+		writeError(w, http.StatusBadRequest, "Unknown provenance graph '%s' of condition '%s', choose variant 'raw', 'clean', or 'diff' (post only)", variant, condition)
+		return
+	}
+
+	// Serve the graphs stored by stages provenance and
+	// diff, in the order of all respectively failed runs.
+	iters, failedIters := runsIters(runs)
+
+	var graphs []*fi.ProvGraph
+	if key == "diff/post" {
+
+		iters = failedIters
+		if results.diff != nil {
+			graphs = results.diff.DiffGraphs
+		}
+	} else if results.provenance != nil {
+
+		graphs = map[string][]*fi.ProvGraph{
+			"raw/pre":    results.provenance.PreGraphs,
+			"raw/post":   results.provenance.PostGraphs,
+			"clean/pre":  results.provenance.PreCleanGraphs,
+			"clean/post": results.provenance.PostCleanGraphs,
+		}[key]
+	}
+
+	for i := range iters {
+
+		if (iters[i] == run.Iteration) && (i < len(graphs)) && (graphs[i] != nil) {
+			writeJSON(w, http.StatusOK, graphs[i])
+			return
+		}
+	}
+
+	writeError(w, http.StatusNotFound, "No %s provenance graph of condition '%s' for run %d", variant, condition, run.Iteration)
+}
+
+// handleJobs lists all analyses triggered via the
+// API, or the one below /api/jobs/{id}.
+func (s *server) handleJobs(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method %s not allowed", r.Method)
+		return
+	}
+
+	s.jobsLock.Lock()
+	defer s.jobsLock.Unlock()
+
+	idRaw := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/jobs"), "/")
+	if idRaw == "" {
+
+		jobs := make([]job, len(s.jobs))
+		for i := range s.jobs {
+			jobs[i] = *s.jobs[i]
+		}
+
+		writeJSON(w, http.StatusOK, jobs)
+		return
+	}
+
+	id, err := strconv.Atoi(idRaw)
+	if (err != nil) || (id < 0) || (id >= len(s.jobs)) {
+		writeError(w, http.StatusNotFound, "Unknown job '%s'", idRaw)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, *s.jobs[id])
+}
+
+// updateJob changes the state of j under lock.
+func (s *server) updateJob(j *job, status string, report string, err error) {
+
+	s.jobsLock.Lock()
+	defer s.jobsLock.Unlock()
+
+	j.Status = status
+	j.Report = report
+	if err != nil {
+		j.Error = err.Error()
+	}
+}
+
+// handleUpload stores an uploaded archive of fault
+// injector output (form field 'output') below the
+// directory of a new job and starts its analysis
+// in the background.
+func (s *server) handleUpload(w http.ResponseWriter, r *http.Request) {
+
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxUpload)
+
+	file, header, err := r.FormFile("output")
+	if err != nil {
+
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "Upload exceeds the limit of %d bytes", tooLarge.Limit)
+			return
+		}
+
+		writeError(w, http.StatusBadRequest, "Please upload fault injector output as form file 'output': %v", err)
+		return
+	}
+	defer file.Close()
+
+	fileName := filepath.Base(header.Filename)
+	if !strings.HasSuffix(fileName, ".tar.gz") && !strings.HasSuffix(fileName, ".tgz") && !strings.HasSuffix(fileName, ".zip") {
+		writeError(w, http.StatusBadRequest, "Upload fault injector output as .tar.gz, .tgz, or .zip archive")
+		return
+	}
+
+	format := r.FormValue("faultInj")
+	if format == "" {
+		format = "molly"
+	}
+
+	s.jobsLock.Lock()
+	j := &job{
+		ID:     len(s.jobs),
+		Status: "uploading",
+	}
+	s.jobs = append(s.jobs, j)
+	s.jobsLock.Unlock()
+
+	// Each job has its own results and its own copy of
+	// the upload, thus uploads of the same name do not
+	// interfere.
+	execution, err := s.reserveExecution(fi.OutputName(fileName), j.ID)
+	if err != nil {
+		s.updateJob(j, "failed", "", err)
+		writeError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+
+	s.jobsLock.Lock()
+	j.Execution = execution
+	s.jobsLock.Unlock()
+
+	archivePath, err := s.storeUpload(execution, fileName, file)
+	if err != nil {
+		s.updateJob(j, "failed", "", err)
+		writeError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+
+	s.updateJob(j, "queued", "", nil)
+
+	s.jobsLock.Lock()
+	queued := *j
+	s.jobsLock.Unlock()
+
+	go s.runJob(j, archivePath, format)
+
+	writeJSON(w, http.StatusAccepted, queued)
+}
+
+// reserveExecution creates the results directory of
+// a job analyzing output name, named <name>-<n> for
+// the first n from id on not taken by earlier jobs,
+// possibly of an earlier server, and returns its name.
+func (s *server) reserveExecution(name string, id int) (string, error) {
+
+	err := os.MkdirAll(s.resultsDir, 0755)
+	if err != nil {
+		return "", fmt.Errorf("Could not ensure results directory exists: %v", err)
+	}
+
+	for n := id; ; n++ {
+
+		execution := fmt.Sprintf("%s-%d", name, n)
+
+		err := os.Mkdir(filepath.Join(s.resultsDir, execution), 0755)
+		if err == nil {
+			return execution, nil
+		} else if !os.IsExist(err) {
+			return "", fmt.Errorf("Could not create results directory of job: %v", err)
+		}
+	}
+}
+
+// storeUpload writes an uploaded archive to
+// <uploads>/<execution>/<fileName> and returns its path.
+func (s *server) storeUpload(execution string, fileName string, file io.Reader) (string, error) {
+
+	jobDir := filepath.Join(s.uploadsDir, execution)
+
+	err := os.MkdirAll(jobDir, 0755)
+	if err != nil {
+		return "", fmt.Errorf("Could not ensure uploads directory exists: %v", err)
+	}
+
+	archivePath := filepath.Join(jobDir, fileName)
+
+	archive, err := os.Create(archivePath)
+	if err != nil {
+		return "", fmt.Errorf("Failed to store upload: %v", err)
+	}
+
+	_, err = io.Copy(archive, file)
+	archive.Close()
+	if err != nil {
+		return "", fmt.Errorf("Failed to store upload: %v", err)
+	}
+
+	return archivePath, nil
+}
+
+// runJob analyzes an uploaded fault injector output
+// once the graph database is available.
+func (s *server) runJob(j *job, archivePath string, format string) {
+
+	s.analysisLock.Lock()
+	defer s.analysisLock.Unlock()
+
+	s.updateJob(j, "running", "", nil)
+
+	faultInjFS, closer, err := fi.OpenOutput(archivePath)
+	if err != nil {
+		s.updateJob(j, "failed", "", err)
+		return
+	}
+	defer closer.Close()
+
+	execDir := filepath.Join(s.resultsDir, j.Execution)
+
+	outcomes, err := runInvariants(s.cfg.AllInvariants(), s.cfg, allStages, execDir, func(invCfg *cf.Config, dir string, inv *cf.Invariant) (*DebugRun, error) {
+
+		faultInj, err := newFaultInjector(format, archivePath, faultInjFS, 0, invCfg)
+		if err != nil {
+			return nil, err
+		}
+
+		reporter, err := newReporter(s.report, s.renderer, nil, invCfg.PostTable, j.Execution)
+		if err != nil {
+			return nil, err
+		}
+
+		return &DebugRun{
+			workDir:        s.resultsDir,
+			allResultsDir:  s.resultsDir,
+			thisResultsDir: dir,
+			faultInj:       faultInj,
+			faultInjFS:     faultInjFS,
+			graphDB:        newGraphDB(invCfg),
+			graphDBConn:    s.graphDBConn,
+			goodRun:        invCfg.GoodRun,
+			topCauses:      invCfg.TopCauses,
+			preTable:       invCfg.PreTable,
+			postTable:      invCfg.PostTable,
+			reporter:       reporter,
+		}, nil
+	})
+	if err != nil {
+		s.updateJob(j, "failed", "", err)
+		return
+	}
+
+	reportPath := outcomes[0].reportPath
+	if len(outcomes) > 1 {
+
+		reportPath, err = writeInvariantsIndex(execDir, outcomes)
+		if err != nil {
+			s.updateJob(j, "failed", "", err)
+			return
+		}
+	}
+
+	s.updateJob(j, "done", reportPath, nil)
+}
+
+// serve hosts reports and the JSON API over HTTP.
+func serve(args []string) {
+
+	serveFlags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addrFlag := serveFlags.String("addr", "127.0.0.1:8080", "Specify address to listen on.")
+	resultsFlag := serveFlags.String("results", "results", "Specify directory holding the results of all analyses.")
+	uploadsFlag := serveFlags.String("uploads", "uploads", "Specify directory to store uploaded fault injector outputs in.")
+	maxUploadFlag := serveFlags.Int64("maxUpload", 1024, "Specify maximum size of uploaded fault injector outputs in MiB.")
+	graphDBConnFlag := serveFlags.String("graphDBConn", "bolt://127.0.0.1:7687", "Supply connection URI to dockerized graph database.")
+	rendererFlag := serveFlags.String("renderer", "builtin", "Specify how to render figures: 'builtin' or 'dot' (requires Graphviz).")
+	reportFlag := serveFlags.String("report", "html", "Specify comma-separated kinds of reports to write for uploaded outputs.")
+	configFlag := serveFlags.String("config", "", "Specify config file for analyses of uploaded outputs (default: nemo.yaml, nemo.yml, or nemo.toml in the current directory, if present).")
+	serveFlags.Parse(args)
+
+	set := setFlags(serveFlags)
+
+	cfg, err := loadConfig(*configFlag, ".")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if set["graphDBConn"] {
+		cfg.URI = *graphDBConnFlag
+	}
+
+	if set["results"] {
+		cfg.ResultsDir = *resultsFlag
+	}
+
+	graphDBConn, err := cfg.GraphDBConn()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	resultsDir, err := filepath.Abs(cfg.ResultsDir)
+	if err != nil {
+		log.Fatalf("Failed obtaining absolute results directory: %v", err)
+	}
+
+	uploadsDir, err := filepath.Abs(*uploadsFlag)
+	if err != nil {
+		log.Fatalf("Failed obtaining absolute uploads directory: %v", err)
+	}
+
+	// Fail early on unknown kinds of reports.
+	_, err = newReporter(*reportFlag, *rendererFlag, nil, "", "")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	s := &server{
+		resultsDir:  resultsDir,
+		uploadsDir:  uploadsDir,
+		maxUpload:   (*maxUploadFlag << 20),
+		graphDBConn: graphDBConn,
+		renderer:    *rendererFlag,
+		report:      *reportFlag,
+		cfg:         cfg,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleIndex)
+	mux.Handle("/reports/", http.StripPrefix("/reports/", http.FileServer(http.Dir(resultsDir))))
+	mux.HandleFunc("/api/executions", s.handleExecutions)
+	mux.HandleFunc("/api/executions/", s.handleExecution)
+	mux.HandleFunc("/api/jobs", s.handleJobs)
+	mux.HandleFunc("/api/jobs/", s.handleJobs)
+
+	fmt.Printf("Serving results in %s on http://%s/\n", resultsDir, *addrFlag)
+
+	log.Fatal(http.ListenAndServe(*addrFlag, mux))
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/numbleroot/nemo/dedalus"
+	fi "github.com/numbleroot/nemo/faultinjectors"
+	"github.com/numbleroot/nemo/simulator"
+)
+
+// Functions.
+
+// readSpecs reads the failure specifications in file,
+// a JSON array like the failureSpec of runs in runs.json.
+// Settings a spec leaves out are taken from defaults.
+func readSpecs(file string, defaults *fi.FailureSpec) ([]*fi.FailureSpec, error) {
+
+	specsJSON, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read failure specifications: %v", err)
+	}
+
+	var specs []*fi.FailureSpec
+
+	err = json.Unmarshal(specsJSON, &specs)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal failure specifications: %v", err)
+	}
+
+	for i, spec := range specs {
+
+		if spec == nil {
+			return nil, fmt.Errorf("Failure specification at index %d is null", i)
+		}
+
+		if spec.EOT == 0 {
+			spec.EOT = defaults.EOT
+		}
+
+		if spec.EFF == 0 {
+			spec.EFF = defaults.EFF
+		}
+
+		if spec.MaxCrashes == 0 {
+			spec.MaxCrashes = defaults.MaxCrashes
+		}
+
+		if spec.Nodes == nil {
+			spec.Nodes = defaults.Nodes
+		}
+
+		if spec.Crashes == nil {
+			spec.Crashes = &[]fi.CrashFailure{}
+		}
+
+		if spec.Omissions == nil {
+			spec.Omissions = &[]fi.MessageLoss{}
+		}
+	}
+
+	return specs, nil
+}
+
+// simulatorFlags
+type simulatorFlags struct {
+	program *string
+	eot     *uint
+	eff     *uint
+	crashes *uint
+	nodes   *string
+	config  *string
+}
+
+// addSimulatorFlags defines the flags shared by subcommands
+// running Dedalus programs with the built-in evaluator.
+func addSimulatorFlags(flags *flag.FlagSet) *simulatorFlags {
+
+	return &simulatorFlags{
+		program: flags.String("program", "", "Specify the Dedalus program (.ded) to run."),
+		eot:     flags.Uint("EOT", 0, "Specify end of time (default: --EOT in the header of the program)."),
+		eff:     flags.Uint("EFF", 0, "Specify time until which messages may be omitted (default: --EFF in the header of the program)."),
+		crashes: flags.Uint("crashes", 0, "Specify maximum number of crashes (default: --crashes in the header of the program)."),
+		nodes:   flags.String("nodes", "", "Specify comma-separated nodes (default: --nodes in the header of the program)."),
+		config:  flags.String("config", "", "Specify config file (default: nemo.yaml, nemo.yml, or nemo.toml next to the program or in the current directory, if present)."),
+	}
+}
+
+// newSimulator parses the program and returns a simulator
+// for it along with the fault-free failure specification,
+// taken from the header of the program and flags.
+func newSimulator(flags *flag.FlagSet, sf *simulatorFlags) (*simulator.Simulator, *fi.FailureSpec) {
+
+	cfg, err := loadConfig(*sf.config, filepath.Dir(*sf.program), ".")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	src, err := ioutil.ReadFile(*sf.program)
+	if err != nil {
+		log.Fatalf("Failed to read Dedalus program: %v", err)
+	}
+
+	prog, err := dedalus.Parse(*sf.program, string(src))
+	if err != nil {
+		log.Fatalf("Failed to parse Dedalus program: %v", err)
+	}
+
+	// Flags take precedence over the header.
+	spec := simulator.HeaderSpec(string(src))
+	set := setFlags(flags)
+
+	if set["EOT"] {
+		spec.EOT = *sf.eot
+	}
+
+	if set["EFF"] {
+		spec.EFF = *sf.eff
+	}
+
+	if set["crashes"] {
+		spec.MaxCrashes = *sf.crashes
+	}
+
+	if set["nodes"] {
+		nodes := strings.Split(*sf.nodes, ",")
+		spec.Nodes = &nodes
+	}
+
+	if (spec.EOT == 0) || (spec.Nodes == nil) {
+		log.Fatal("Please provide end of time and nodes, either via -EOT and -nodes or in the header of the program.")
+	}
+
+	sim := &simulator.Simulator{
+		Program:   prog,
+		PreTable:  cfg.PreTable,
+		PostTable: cfg.PostTable,
+	}
+
+	for _, inv := range cfg.AllInvariants() {
+		sim.Tables = append(sim.Tables, inv.Pre, inv.Post)
+	}
+
+	return sim, spec
+}
+
+// failedRuns returns the number of runs of ex
+// the invariant does not hold in.
+func failedRuns(ex *simulator.Execution) int {
+
+	failed := 0
+	for _, run := range ex.Runs {
+
+		if run.Status != "success" {
+			failed++
+		}
+	}
+
+	return failed
+}
+
+// simulate runs a Dedalus program with the built-in
+// evaluator and writes runs, provenance, and space-time
+// diagrams in the output format of Molly.
+func simulate(args []string) {
+
+	simFlags := flag.NewFlagSet("simulate", flag.ExitOnError)
+	sf := addSimulatorFlags(simFlags)
+	outFlag := simFlags.String("out", "", "Specify directory to write the output in the format of Molly to.")
+	specsFlag := simFlags.String("specs", "", "Optionally specify a JSON file of failure specifications to run after the fault-free run.")
+	simFlags.Parse(args)
+
+	if (*sf.program == "") || (*outFlag == "") {
+		log.Fatal("Please provide a Dedalus program and a target directory.")
+	}
+
+	sim, spec := newSimulator(simFlags, sf)
+	specs := []*fi.FailureSpec{spec}
+
+	if *specsFlag != "" {
+
+		faulty, err := readSpecs(*specsFlag, spec)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		specs = append(specs, faulty...)
+	}
+
+	ex, err := sim.Simulate(specs)
+	if err != nil {
+		log.Fatalf("Failed to simulate: %v", err)
+	}
+
+	err = ex.Write(*outFlag)
+	if err != nil {
+		log.Fatalf("Failed to write simulation: %v", err)
+	}
+
+	fmt.Printf("Simulated %d runs, %d of them failed. Wrote output to %s.\n", len(ex.Runs), failedRuns(ex), *outFlag)
+}
+
+// ldfi searches for failure specifications violating the
+// invariant of a Dedalus program, guided by the provenance
+// of successful runs, and writes all runs as a trace.
+func ldfi(args []string) {
+
+	ldfiFlags := flag.NewFlagSet("ldfi", flag.ExitOnError)
+	sf := addSimulatorFlags(ldfiFlags)
+	outFlag := ldfiFlags.String("out", "", "Specify directory to write trace.json and space-time diagrams to.")
+	maxRunsFlag := ldfiFlags.Int("maxRuns", 1000, "Specify maximum number of runs to simulate.")
+	ldfiFlags.Parse(args)
+
+	if (*sf.program == "") || (*outFlag == "") {
+		log.Fatal("Please provide a Dedalus program and a target directory.")
+	}
+
+	if *maxRunsFlag < 1 {
+		log.Fatal("Please allow at least one run via -maxRuns.")
+	}
+
+	sim, spec := newSimulator(ldfiFlags, sf)
+
+	ex, err := sim.LDFI(spec, *maxRunsFlag)
+	if err != nil {
+		log.Fatalf("Failed to search for faults: %v", err)
+	}
+
+	err = ex.WriteTrace(*outFlag)
+	if err != nil {
+		log.Fatalf("Failed to write trace: %v", err)
+	}
+
+	fmt.Printf("Simulated %d runs, %d of them failed. Wrote trace to %s.\n", len(ex.Runs), failedRuns(ex), filepath.Join(*outFlag, "trace.json"))
+}
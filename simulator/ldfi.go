@@ -0,0 +1,355 @@
+package simulator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/awalterschulze/gographviz"
+	"github.com/numbleroot/nemo/dedalus"
+	fi "github.com/numbleroot/nemo/faultinjectors"
+)
+
+// maxCuts bounds the number of cuts kept per goal,
+// smallest first, so that the search stays tractable.
+const maxCuts = 64
+
+// Structs.
+
+// fault is an omission of the messages from node to
+// another node sent at time, or a crash of node at time.
+type fault struct {
+	crash bool
+	node  string
+	to    string
+	time  uint
+}
+
+// cut is a set of faults, sorted by key.
+type cut []*fault
+
+// lineage finds the cuts of the provenance of one run:
+// sets of faults that falsify a goal if injected.
+type lineage struct {
+	model  *dedalus.Model
+	spec   *fi.FailureSpec
+	nodes  map[string]bool
+	cuts   map[*dedalus.Tuple][]cut
+	active map[*dedalus.Tuple]bool
+}
+
+// Functions.
+
+// key identifies the fault.
+func (f *fault) key() string {
+
+	if f.crash {
+		return fmt.Sprintf("crash %s @%d", f.node, f.time)
+	}
+
+	return fmt.Sprintf("omission %s -> %s @%d", f.node, f.to, f.time)
+}
+
+// key identifies the cut.
+func (c cut) key() string {
+
+	keys := make([]string, len(c))
+	for i := range c {
+		keys[i] = c[i].key()
+	}
+
+	return strings.Join(keys, ", ")
+}
+
+// union returns the faults of c and other, sorted and
+// without duplicates. It reports false if the union
+// crashes a node twice or more nodes than spec allows.
+func (c cut) union(other cut, spec *fi.FailureSpec) (cut, bool) {
+
+	byKey := make(map[string]*fault, (len(c) + len(other)))
+	for _, f := range append(append(cut{}, c...), other...) {
+		byKey[f.key()] = f
+	}
+
+	united := make(cut, 0, len(byKey))
+	crashed := make(map[string]bool)
+
+	for _, f := range byKey {
+
+		if f.crash {
+
+			if crashed[f.node] {
+				return nil, false
+			}
+			crashed[f.node] = true
+		}
+
+		united = append(united, f)
+	}
+
+	if uint(len(crashed)) > spec.MaxCrashes {
+		return nil, false
+	}
+
+	sort.Slice(united, func(i, j int) bool {
+		return united[i].key() < united[j].key()
+	})
+
+	return united, true
+}
+
+// contains reports whether c holds all faults of other.
+func (c cut) contains(other cut) bool {
+
+	keys := make(map[string]bool, len(c))
+	for _, f := range c {
+		keys[f.key()] = true
+	}
+
+	for _, f := range other {
+
+		if !keys[f.key()] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// minimize drops duplicate cuts and cuts containing
+// another one, and keeps at most maxCuts, smallest first.
+func minimize(cuts []cut) []cut {
+
+	sort.SliceStable(cuts, func(i, j int) bool {
+
+		if len(cuts[i]) != len(cuts[j]) {
+			return len(cuts[i]) < len(cuts[j])
+		}
+
+		return cuts[i].key() < cuts[j].key()
+	})
+
+	minimal := make([]cut, 0, len(cuts))
+	for _, c := range cuts {
+
+		redundant := false
+		for _, m := range minimal {
+
+			if c.contains(m) {
+				redundant = true
+				break
+			}
+		}
+
+		if !redundant {
+			minimal = append(minimal, c)
+		}
+
+		if len(minimal) == maxCuts {
+			break
+		}
+	}
+
+	return minimal
+}
+
+// goalCuts returns the cuts falsifying tuple: every
+// derivation of it has to be cut. Dropping a message
+// cuts its clock tuple, crashing a node cuts all rule
+// firings at it. Tuples without derivations, such as
+// facts, cannot be cut.
+func (l *lineage) goalCuts(tuple *dedalus.Tuple) []cut {
+
+	if cuts, found := l.cuts[tuple]; found {
+		return cuts
+	}
+
+	// A goal cannot support itself. Cutting all other
+	// derivations of a goal on a cycle suffices.
+	if l.active[tuple] {
+		return []cut{{}}
+	}
+
+	l.active[tuple] = true
+	defer delete(l.active, tuple)
+
+	cuts := make([]cut, 0, 4)
+
+	if (tuple.Table == "clock") && (len(tuple.Args) == 4) && (tuple.Args[0] != tuple.Args[1]) {
+
+		if tuple.Time < l.spec.EFF {
+			cuts = append(cuts, cut{{node: tuple.Args[0], to: tuple.Args[1], time: tuple.Time}})
+		}
+
+		if (l.spec.MaxCrashes > 0) && l.nodes[tuple.Args[1]] && ((tuple.Time + 1) <= l.spec.EOT) {
+			cuts = append(cuts, cut{{crash: true, node: tuple.Args[1], time: (tuple.Time + 1)}})
+		}
+
+		l.cuts[tuple] = cuts
+
+		return cuts
+	}
+
+	derivations := l.model.Derivations(tuple)
+	if len(derivations) == 0 {
+		l.cuts[tuple] = cuts
+		return cuts
+	}
+
+	// Start with the empty cut and extend it by
+	// one way to cut each derivation in turn.
+	cuts = append(cuts, cut{})
+	for _, derivation := range derivations {
+
+		options := make([]cut, 0, 4)
+		for _, body := range derivation.Body {
+			options = append(options, l.goalCuts(body)...)
+		}
+
+		if (l.spec.MaxCrashes > 0) && (len(derivation.Body) > 0) && (len(derivation.Body[0].Args) > 0) {
+
+			first := derivation.Body[0]
+			if l.nodes[first.Args[0]] {
+				options = append(options, cut{{crash: true, node: first.Args[0], time: first.Time}})
+			}
+		}
+
+		extended := make([]cut, 0, (len(cuts) * len(options)))
+		for _, c := range cuts {
+
+			for _, option := range options {
+
+				if united, ok := c.union(option, l.spec); ok {
+					extended = append(extended, united)
+				}
+			}
+		}
+
+		cuts = minimize(extended)
+		if len(cuts) == 0 {
+			break
+		}
+	}
+
+	l.cuts[tuple] = cuts
+
+	return cuts
+}
+
+// invariantCuts returns the cuts falsifying any row of the
+// consequent that an antecedent row relies on at the end
+// of time, smallest first.
+func (s *Simulator) invariantCuts(model *dedalus.Model, spec *fi.FailureSpec) []cut {
+
+	l := &lineage{
+		model:  model,
+		spec:   spec,
+		nodes:  make(map[string]bool),
+		cuts:   make(map[*dedalus.Tuple][]cut),
+		active: make(map[*dedalus.Tuple]bool),
+	}
+
+	if spec.Nodes != nil {
+
+		for _, node := range *spec.Nodes {
+			l.nodes[node] = true
+		}
+	}
+
+	cuts := make([]cut, 0, 8)
+	for _, tuple := range model.Tuples(s.PostTable, spec.EOT) {
+
+		if model.Holds(s.PreTable, tuple.Args, spec.EOT) {
+			cuts = append(cuts, l.goalCuts(tuple)...)
+		}
+	}
+
+	return minimize(cuts)
+}
+
+// specWith returns a copy of base injecting faults.
+func specWith(base *fi.FailureSpec, faults cut) *fi.FailureSpec {
+
+	crashes := make([]fi.CrashFailure, 0, len(faults))
+	omissions := make([]fi.MessageLoss, 0, len(faults))
+
+	for _, f := range faults {
+
+		if f.crash {
+			crashes = append(crashes, fi.CrashFailure{Node: f.node, Time: f.time})
+		} else {
+			omissions = append(omissions, fi.MessageLoss{From: f.node, To: f.to, Time: f.time})
+		}
+	}
+
+	return &fi.FailureSpec{
+		EOT:        base.EOT,
+		EFF:        base.EFF,
+		MaxCrashes: base.MaxCrashes,
+		Nodes:      base.Nodes,
+		Crashes:    &crashes,
+		Omissions:  &omissions,
+	}
+}
+
+// LDFI searches for faults violating the invariant within
+// the bounds of base, in the manner of lineage-driven fault
+// injection. Starting from the fault-free run, each run the
+// invariant holds in is followed by runs that additionally
+// inject one minimal cut of its consequent provenance, fewest
+// faults first. The search ends once no new cuts are found
+// or maxRuns runs were simulated.
+func (s *Simulator) LDFI(base *fi.FailureSpec, maxRuns int) (*Execution, error) {
+
+	ex := &Execution{
+		Runs:       make([]*fi.Run, 0, 16),
+		Provenance: make([]map[string]*fi.ProvData, 0, 16),
+		SpaceTimes: make([]*gographviz.Graph, 0, 16),
+	}
+
+	queue := []cut{{}}
+	seen := map[string]bool{"": true}
+
+	for (len(queue) > 0) && (len(ex.Runs) < maxRuns) {
+
+		faults := queue[0]
+		queue = queue[1:]
+
+		spec := specWith(base, faults)
+		iter := uint(len(ex.Runs))
+
+		model, err := s.Program.Evaluate(Scenario(spec))
+		if err != nil {
+			return nil, fmt.Errorf("Run %d: %v", iter, err)
+		}
+
+		run, provs, spaceTime, err := s.record(iter, spec, model)
+		if err != nil {
+			return nil, err
+		}
+
+		ex.Runs = append(ex.Runs, run)
+		ex.Provenance = append(ex.Provenance, provs)
+		ex.SpaceTimes = append(ex.SpaceTimes, spaceTime)
+
+		if run.Status != "success" {
+			continue
+		}
+
+		for _, c := range s.invariantCuts(model, spec) {
+
+			next, ok := faults.union(c, spec)
+			if ok && !seen[next.key()] {
+				seen[next.key()] = true
+				queue = append(queue, next)
+			}
+		}
+
+		// Explore fewer faults first.
+		sort.SliceStable(queue, func(i, j int) bool {
+			return len(queue[i]) < len(queue[j])
+		})
+	}
+
+	return ex, nil
+}
@@ -0,0 +1,91 @@
+package simulator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/numbleroot/nemo/dedalus"
+	fi "github.com/numbleroot/nemo/faultinjectors"
+)
+
+// Functions.
+
+// goalLabel returns the label of tuple in provenance.
+// Clock tuples already end in the times they relate.
+func goalLabel(tuple *dedalus.Tuple) string {
+
+	if tuple.Table == "clock" {
+		return fmt.Sprintf("clock(%s)", strings.Join(tuple.Args, ", "))
+	}
+
+	return tuple.String()
+}
+
+// provenance collects how all tuples of table in model
+// were derived, at any time. Each tuple becomes one goal,
+// each firing one rule pointing at the goals it used.
+// Negated atoms do not contribute goals.
+func provenance(model *dedalus.Model, table string) *fi.ProvData {
+
+	prov := &fi.ProvData{
+		Goals: make([]fi.Goal, 0, 16),
+		Rules: make([]fi.Rule, 0, 16),
+		Edges: make([]fi.Edge, 0, 32),
+	}
+
+	goals := make(map[*dedalus.Tuple]string)
+	queue := make([]*dedalus.Tuple, 0, 16)
+
+	goal := func(tuple *dedalus.Tuple) string {
+
+		if id, found := goals[tuple]; found {
+			return id
+		}
+
+		id := fmt.Sprintf("goal%d", (len(goals) + 1))
+		goals[tuple] = id
+
+		prov.Goals = append(prov.Goals, fi.Goal{
+			ID:    id,
+			Label: goalLabel(tuple),
+			Table: tuple.Table,
+			Time:  fmt.Sprintf("%d", tuple.Time),
+		})
+		queue = append(queue, tuple)
+
+		return id
+	}
+
+	for t := uint(1); t <= model.EOT; t++ {
+
+		for _, tuple := range model.Tuples(table, t) {
+			goal(tuple)
+		}
+	}
+
+	for len(queue) > 0 {
+
+		tuple := queue[0]
+		queue = queue[1:]
+
+		for _, derivation := range model.Derivations(tuple) {
+
+			id := fmt.Sprintf("rule%d", (len(prov.Rules) + 1))
+
+			prov.Rules = append(prov.Rules, fi.Rule{
+				ID:    id,
+				Label: derivation.Rule.Head.Table,
+				Table: derivation.Rule.Head.Table,
+				Type:  derivation.Rule.Time,
+			})
+
+			prov.Edges = append(prov.Edges, fi.Edge{From: goals[tuple], To: id})
+
+			for _, body := range derivation.Body {
+				prov.Edges = append(prov.Edges, fi.Edge{From: id, To: goal(body)})
+			}
+		}
+	}
+
+	return prov
+}
@@ -0,0 +1,365 @@
+package simulator
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/awalterschulze/gographviz"
+	"github.com/numbleroot/nemo/dedalus"
+	fi "github.com/numbleroot/nemo/faultinjectors"
+)
+
+// Structs.
+
+// Simulator runs a Dedalus program under failure
+// specifications in place of Molly. Provenance is
+// captured for the antecedent and consequent tables
+// and any further tables listed in Tables.
+type Simulator struct {
+	Program   *dedalus.Program
+	PreTable  string
+	PostTable string
+	Tables    []string
+}
+
+// Execution holds all runs of one simulation, each
+// with the provenance of its tables and its space-time
+// diagram, as Molly would have written them.
+type Execution struct {
+	Runs       []*fi.Run
+	Provenance []map[string]*fi.ProvData
+	SpaceTimes []*gographviz.Graph
+}
+
+// Options of Molly as given in the header of the
+// case studies, e.g., --EOT 6 --EFF 4 --crashes 1.
+var (
+	eotOption     = regexp.MustCompile(`--EOT (\d+)`)
+	effOption     = regexp.MustCompile(`--EFF (\d+)`)
+	crashesOption = regexp.MustCompile(`--crashes (\d+)`)
+	nodesOption   = regexp.MustCompile(`--nodes ([^\s"]+)`)
+)
+
+// Functions.
+
+// HeaderSpec reads the failure specification Molly is
+// invoked with from the comments of a Dedalus program.
+// Options missing from src are left unset.
+func HeaderSpec(src string) *fi.FailureSpec {
+
+	spec := &fi.FailureSpec{
+		Crashes:   &[]fi.CrashFailure{},
+		Omissions: &[]fi.MessageLoss{},
+	}
+
+	number := func(option *regexp.Regexp) uint {
+
+		matches := option.FindStringSubmatch(src)
+		if len(matches) < 2 {
+			return 0
+		}
+
+		n, _ := strconv.Atoi(matches[1])
+		return uint(n)
+	}
+
+	spec.EOT = number(eotOption)
+	spec.EFF = number(effOption)
+	spec.MaxCrashes = number(crashesOption)
+
+	if matches := nodesOption.FindStringSubmatch(src); len(matches) > 1 {
+		nodes := strings.Split(matches[1], ",")
+		spec.Nodes = &nodes
+	}
+
+	return spec
+}
+
+// Scenario returns the failure scenario the
+// evaluator runs for spec.
+func Scenario(spec *fi.FailureSpec) *dedalus.Scenario {
+
+	scenario := &dedalus.Scenario{
+		EOT: spec.EOT,
+		EFF: spec.EFF,
+	}
+
+	if spec.Nodes != nil {
+		scenario.Nodes = *spec.Nodes
+	}
+
+	if spec.Crashes != nil {
+
+		for _, c := range *spec.Crashes {
+			scenario.Crashes = append(scenario.Crashes, &dedalus.Crash{Node: c.Node, Time: c.Time})
+		}
+	}
+
+	if spec.Omissions != nil {
+
+		for _, o := range *spec.Omissions {
+			scenario.Omissions = append(scenario.Omissions, &dedalus.Omission{From: o.From, To: o.To, Time: o.Time})
+		}
+	}
+
+	return scenario
+}
+
+// tables returns the tables to capture provenance
+// for, antecedent and consequent first.
+func (s *Simulator) tables() []string {
+
+	tables := []string{s.PreTable, s.PostTable}
+	seen := map[string]bool{s.PreTable: true, s.PostTable: true}
+
+	for _, table := range s.Tables {
+
+		if !seen[table] {
+			seen[table] = true
+			tables = append(tables, table)
+		}
+	}
+
+	return tables
+}
+
+// programTables returns all tables the program refers
+// to and all tables holding rows in model, sorted.
+func (s *Simulator) programTables(model *dedalus.Model) []string {
+
+	tables := model.Tables()
+
+	seen := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		seen[table] = true
+	}
+
+	atoms := make([]*dedalus.Atom, 0, (2 * len(s.Program.Rules)))
+	for _, rule := range s.Program.Rules {
+		atoms = append(append(atoms, rule.Head), rule.Body...)
+	}
+
+	for _, fact := range s.Program.Facts {
+		atoms = append(atoms, fact.Atom)
+	}
+
+	for _, atom := range atoms {
+
+		if !seen[atom.Table] {
+			seen[atom.Table] = true
+			tables = append(tables, atom.Table)
+		}
+	}
+
+	sort.Strings(tables)
+
+	return tables
+}
+
+// Run evaluates the program under spec as run iter.
+// The run succeeds if the antecedent implies the
+// consequent at the end of time.
+func (s *Simulator) Run(iter uint, spec *fi.FailureSpec) (*fi.Run, map[string]*fi.ProvData, *gographviz.Graph, error) {
+
+	model, err := s.Program.Evaluate(Scenario(spec))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Run %d: %v", iter, err)
+	}
+
+	return s.record(iter, spec, model)
+}
+
+// record turns model, evaluated under spec, into run
+// iter along with its provenance and space-time diagram.
+func (s *Simulator) record(iter uint, spec *fi.FailureSpec, model *dedalus.Model) (*fi.Run, map[string]*fi.ProvData, *gographviz.Graph, error) {
+
+	run := &fi.Run{
+		Iteration:   iter,
+		Status:      "failure",
+		FailureSpec: spec,
+		Model:       &fi.Model{Tables: make(map[string][][]string)},
+		Messages:    make([]*fi.Message, 0, len(model.Messages())),
+	}
+
+	if model.Implies(s.PreTable, s.PostTable, spec.EOT) {
+		run.Status = "success"
+	}
+
+	// Like Molly, list all tables of the program, even
+	// if empty. Rows end in the time they hold at.
+	for _, table := range s.programTables(model) {
+
+		rows := make([][]string, 0, 8)
+		for t := uint(1); t <= spec.EOT; t++ {
+
+			for _, row := range model.Rows(table, t) {
+				rows = append(rows, append(append([]string{}, row...), fmt.Sprintf("%d", t)))
+			}
+		}
+
+		run.Model.Tables[table] = rows
+	}
+
+	for _, msg := range model.Messages() {
+
+		if msg.Delivered {
+
+			run.Messages = append(run.Messages, &fi.Message{
+				Content:  msg.Table,
+				SendNode: msg.From,
+				RecvNode: msg.To,
+				SendTime: msg.Time,
+				RecvTime: (msg.Time + 1),
+			})
+		}
+	}
+
+	provs := make(map[string]*fi.ProvData)
+	for _, table := range s.tables() {
+		provs[table] = provenance(model, table)
+	}
+
+	spaceTime, err := createSpaceTimeDOT(spec, model)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return run, provs, spaceTime, nil
+}
+
+// Simulate runs the program once per spec, numbering
+// runs in order of specs.
+func (s *Simulator) Simulate(specs []*fi.FailureSpec) (*Execution, error) {
+
+	ex := &Execution{
+		Runs:       make([]*fi.Run, len(specs)),
+		Provenance: make([]map[string]*fi.ProvData, len(specs)),
+		SpaceTimes: make([]*gographviz.Graph, len(specs)),
+	}
+
+	for i := range specs {
+
+		run, provs, spaceTime, err := s.Run(uint(i), specs[i])
+		if err != nil {
+			return nil, err
+		}
+
+		ex.Runs[i] = run
+		ex.Provenance[i] = provs
+		ex.SpaceTimes[i] = spaceTime
+	}
+
+	return ex, nil
+}
+
+// Write stores ex in dir in the format of Molly:
+// runs.json, run_<N>_<table>_provenance.json,
+// and run_<N>_spacetime.dot.
+func (ex *Execution) Write(dir string) error {
+
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	runsJSON, err := json.Marshal(ex.Runs)
+	if err != nil {
+		return fmt.Errorf("Failed to marshal runs to JSON: %v", err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, "runs.json"), runsJSON, 0644)
+	if err != nil {
+		return fmt.Errorf("Error writing out runs.json: %v", err)
+	}
+
+	for i, run := range ex.Runs {
+
+		for table, prov := range ex.Provenance[i] {
+
+			provJSON, err := json.Marshal(prov)
+			if err != nil {
+				return fmt.Errorf("Failed to marshal provenance to JSON: %v", err)
+			}
+
+			err = ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("run_%d_%s_provenance.json", run.Iteration, table)), provJSON, 0644)
+			if err != nil {
+				return fmt.Errorf("Error writing out provenance of run %d: %v", run.Iteration, err)
+			}
+		}
+
+		err = ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("run_%d_spacetime.dot", run.Iteration)), []byte(ex.SpaceTimes[i].String()), 0644)
+		if err != nil {
+			return fmt.Errorf("Error writing out space-time diagram of run %d: %v", run.Iteration, err)
+		}
+	}
+
+	return nil
+}
+
+// WriteTrace stores ex in dir in the generic trace format
+// of Nemo: trace.json and run_<N>_spacetime.dot.
+func (ex *Execution) WriteTrace(dir string) error {
+
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	trace := &fi.Trace{
+		Version:  fi.TraceVersion,
+		Injector: "nemo",
+		Runs:     make([]*fi.Run, len(ex.Runs)),
+	}
+
+	for i := range ex.Runs {
+
+		// Runs of ex stay without provenance.
+		run := *ex.Runs[i]
+
+		for table, prov := range ex.Provenance[i] {
+
+			switch table {
+			case "pre":
+				run.PreProv = prov
+			case "post":
+				run.PostProv = prov
+			default:
+
+				if run.Provenance == nil {
+					run.Provenance = make(map[string]*fi.ProvData)
+				}
+				run.Provenance[table] = prov
+			}
+		}
+
+		trace.Runs[i] = &run
+	}
+
+	traceJSON, err := json.Marshal(trace)
+	if err != nil {
+		return fmt.Errorf("Failed to marshal trace to JSON: %v", err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, "trace.json"), traceJSON, 0644)
+	if err != nil {
+		return fmt.Errorf("Error writing out trace.json: %v", err)
+	}
+
+	for i, run := range ex.Runs {
+
+		err = ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("run_%d_spacetime.dot", run.Iteration)), []byte(ex.SpaceTimes[i].String()), 0644)
+		if err != nil {
+			return fmt.Errorf("Error writing out space-time diagram of run %d: %v", run.Iteration, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,166 @@
+package simulator
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/numbleroot/nemo/dedalus"
+)
+
+// update regenerates the fixtures instead of comparing against them.
+var update = flag.Bool("update", false, "Regenerate fixtures in testdata/case-studies.")
+
+// fixtureRuns bounds the runs simulated per case study.
+// Every case study fails within that many runs.
+const fixtureRuns = 10
+
+// Functions.
+
+// fileNames returns the names of the files in dir, sorted.
+func fileNames(t *testing.T, dir string) []string {
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make([]string, 0, len(infos))
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func TestHeaderSpec(t *testing.T) {
+
+	src, err := ioutil.ReadFile(filepath.Join("..", "case-studies", "pb_asynchronous.ded"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spec := HeaderSpec(string(src))
+
+	if (spec.EOT != 6) || (spec.EFF != 4) || (spec.MaxCrashes != 1) {
+		t.Errorf("Expected EOT 6, EFF 4, crashes 1, got %d, %d, %d", spec.EOT, spec.EFF, spec.MaxCrashes)
+	}
+
+	if (spec.Nodes == nil) || (strings.Join(*spec.Nodes, ",") != "C,a,b,c") {
+		t.Errorf("Expected nodes C,a,b,c, got %v", spec.Nodes)
+	}
+}
+
+// TestCaseStudyFixtures searches for failures in every
+// case study and compares the output to the fixtures
+// in testdata, which the pipeline tests run on.
+func TestCaseStudyFixtures(t *testing.T) {
+
+	programs, err := filepath.Glob(filepath.Join("..", "case-studies", "*.ded"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(programs) == 0 {
+		t.Fatal("No case studies found")
+	}
+
+	for _, program := range programs {
+
+		name := strings.TrimSuffix(filepath.Base(program), ".ded")
+
+		t.Run(name, func(t *testing.T) {
+
+			src, err := ioutil.ReadFile(program)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			prog, err := dedalus.Parse(program, string(src))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sim := &Simulator{Program: prog, PreTable: "pre", PostTable: "post"}
+
+			ex, err := sim.LDFI(HeaderSpec(string(src)), fixtureRuns)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			failed := 0
+			for _, run := range ex.Runs {
+
+				if run.Status != "success" {
+					failed++
+				}
+			}
+
+			if failed == 0 {
+				t.Errorf("Expected a failed run within %d runs", fixtureRuns)
+			}
+
+			out := t.TempDir()
+
+			err = ex.Write(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fixture := filepath.Join("..", "testdata", "case-studies", name)
+
+			if *update {
+
+				err = os.RemoveAll(fixture)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				err = os.MkdirAll(fixture, 0755)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			names := fileNames(t, out)
+			for _, file := range names {
+
+				got, err := ioutil.ReadFile(filepath.Join(out, file))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if *update {
+
+					err = ioutil.WriteFile(filepath.Join(fixture, file), got, 0644)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					continue
+				}
+
+				want, err := ioutil.ReadFile(filepath.Join(fixture, file))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !bytes.Equal(got, want) {
+					t.Errorf("%s differs from fixture, rerun with -update if intended", file)
+				}
+			}
+
+			if fixtures := fileNames(t, fixture); strings.Join(fixtures, ",") != strings.Join(names, ",") {
+				t.Errorf("Expected files %v, fixture has %v", names, fixtures)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package simulator
+
+import (
+	"fmt"
+
+	"github.com/awalterschulze/gographviz"
+	"github.com/numbleroot/nemo/dedalus"
+	fi "github.com/numbleroot/nemo/faultinjectors"
+)
+
+// Functions.
+
+// createSpaceTimeDOT draws the timeline of every node
+// of spec and the messages sent between them. Steps
+// after a node crashed and dropped messages are dashed.
+func createSpaceTimeDOT(spec *fi.FailureSpec, model *dedalus.Model) (*gographviz.Graph, error) {
+
+	dotGraph := gographviz.NewGraph()
+
+	err := dotGraph.SetName("spacetime")
+	if err != nil {
+		return nil, err
+	}
+
+	err = dotGraph.SetDir(true)
+	if err != nil {
+		return nil, err
+	}
+
+	crashedAt := make(map[string]uint)
+	if spec.Crashes != nil {
+
+		for _, c := range *spec.Crashes {
+
+			if at, found := crashedAt[c.Node]; !found || (c.Time < at) {
+				crashedAt[c.Node] = c.Time
+			}
+		}
+	}
+
+	nodes := make([]string, 0, 4)
+	if spec.Nodes != nil {
+		nodes = *spec.Nodes
+	}
+
+	// Node names end in the timestep they represent,
+	// which the hazard analysis relies on.
+	nodeName := func(node string, time uint) string {
+		return fmt.Sprintf("\"%s_%d\"", node, time)
+	}
+
+	for _, node := range nodes {
+
+		for t := uint(1); t <= spec.EOT; t++ {
+
+			attrs := map[string]string{
+				"label": fmt.Sprintf("\"%s @ %d\"", node, t),
+			}
+
+			if at, found := crashedAt[node]; found && (at <= t) {
+				attrs["label"] = fmt.Sprintf("\"%s @ %d (crashed)\"", node, t)
+				attrs["style"] = "dashed"
+			}
+
+			err := dotGraph.AddNode("spacetime", nodeName(node, t), attrs)
+			if err != nil {
+				return nil, err
+			}
+
+			if t > 1 {
+
+				err := dotGraph.AddEdge(nodeName(node, (t-1)), nodeName(node, t), true, nil)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	for _, msg := range model.Messages() {
+
+		attrs := map[string]string{
+			"label": fmt.Sprintf("\"%s\"", msg.Table),
+		}
+
+		if !msg.Delivered {
+			attrs["label"] = fmt.Sprintf("\"%s (dropped)\"", msg.Table)
+			attrs["style"] = "dashed"
+		}
+
+		err := dotGraph.AddEdge(nodeName(msg.From, msg.Time), nodeName(msg.To, (msg.Time+1)), true, attrs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return dotGraph, nil
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"path/filepath"
+
+	"github.com/numbleroot/nemo/dedalus"
+	fi "github.com/numbleroot/nemo/faultinjectors"
+	"github.com/numbleroot/nemo/simulator"
+)
+
+// Structs.
+
+// verifyResult
+type verifyResult struct {
+	Verifications []*fi.Verification `json:"verifications"`
+}
+
+// Patterns to pick apart correction suggestions.
+var (
+	codePattern = regexp.MustCompile(`<code>(.*?)</code>`)
+	atomPattern = regexp.MustCompile(`(\w+)\(`)
+)
+
+// Functions.
+
+// arity returns the number of arguments of table
+// in prog, or -1 if prog does not define it.
+func arity(prog *dedalus.Program, table string) int {
+
+	for _, rule := range prog.Rules {
+
+		if rule.Head.Table == table {
+			return len(rule.Head.Args)
+		}
+	}
+
+	for _, fact := range prog.Facts {
+
+		if fact.Atom.Table == table {
+			return len(fact.Atom.Args)
+		}
+	}
+
+	return -1
+}
+
+// concretize turns a suggestion to change the rules of
+// the antecedent into a patch of prog. It adds an atom
+// for each suggested table, at the node of the rule's
+// first trigger, to each rule the trigger fires, which
+// the patch replaces. It returns why if it cannot do so.
+func concretize(prog *dedalus.Program, correction string) (*dedalus.Program, string) {
+
+	codes := codePattern.FindAllStringSubmatch(correction, -1)
+	if !strings.HasPrefix(correction, "Change:") || (len(codes) != 2) {
+		return nil, "part of the change suggested after it"
+	}
+
+	before := atomPattern.FindAllStringSubmatch(codes[0][1], -1)
+	after := atomPattern.FindAllStringSubmatch(codes[1][1], -1)
+	if len(before) < 2 {
+		return nil, "not a rule"
+	}
+
+	head, trigger := before[0][1], before[1][1]
+
+	existing := make(map[string]bool, len(before))
+	for _, atom := range before {
+		existing[atom[1]] = true
+	}
+
+	added := make([]string, 0, 2)
+	for _, atom := range after {
+
+		if existing[atom[1]] {
+			continue
+		}
+
+		if arity(prog, atom[1]) < 0 {
+			return nil, fmt.Sprintf("needs rules for new table %s written first, verify them via -patch", atom[1])
+		}
+		added = append(added, atom[1])
+	}
+
+	if len(added) == 0 {
+		return nil, "suggests no new subgoal"
+	}
+
+	patch := &dedalus.Program{File: "correction"}
+
+	for _, rule := range prog.RulesFor(head) {
+
+		// Find the node the trigger happens at.
+		var node *dedalus.Term
+		for _, atom := range rule.Body {
+
+			if (atom.Table == trigger) && !atom.Negated && (len(atom.Args) > 0) {
+				node = atom.Args[0]
+			}
+		}
+
+		if node == nil {
+			continue
+		}
+
+		patched := *rule
+		patched.Body = append([]*dedalus.Atom{}, rule.Body...)
+		patched.Replaces = rule
+
+		for _, table := range added {
+
+			atom := &dedalus.Atom{Table: table, Args: []*dedalus.Term{node}}
+			for len(atom.Args) < arity(prog, table) {
+				atom.Args = append(atom.Args, &dedalus.Term{Kind: dedalus.Wildcard})
+			}
+
+			patched.Body = append(patched.Body, atom)
+		}
+
+		patch.Rules = append(patch.Rules, &patched)
+	}
+
+	if len(patch.Rules) == 0 {
+		return nil, fmt.Sprintf("no rule for %s triggered by %s in the program", head, trigger)
+	}
+
+	return patch, ""
+}
+
+// keepsRows reports whether every row of table holding
+// at time in model also holds at time in patched.
+func keepsRows(model *dedalus.Model, patched *dedalus.Model, table string, time uint) bool {
+
+	for _, row := range model.Rows(table, time) {
+
+		if !patched.Holds(table, row, time) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// verifyPatch replays the failure scenarios of runs against
+// prog patched with patch. Scenarios in which the unpatched
+// program does not violate the invariant either are not
+// counted as evidence. Neither is a patch that makes the
+// invariant hold by no longer deriving rows of pre.
+func verifyPatch(prog *dedalus.Program, patch *dedalus.Program, runs []*fi.Run, pre string, post string) (*fi.Verification, error) {
+
+	patched := prog.Apply(patch)
+
+	rules := make([]string, len(patch.Rules))
+	for i := range patch.Rules {
+		rules[i] = patch.Rules[i].String()
+	}
+
+	v := &fi.Verification{Patch: strings.Join(rules, "\n")}
+
+	for _, run := range runs {
+
+		if (run == nil) || (run.FailureSpec == nil) {
+			continue
+		}
+
+		v.Scenarios++
+		scenario := simulator.Scenario(run.FailureSpec)
+
+		model, err := prog.Evaluate(scenario)
+		if err != nil {
+			return nil, err
+		}
+
+		if model.Implies(pre, post, scenario.EOT) {
+			continue
+		}
+		v.Reproduced++
+
+		patchedModel, err := patched.Evaluate(scenario)
+		if err != nil {
+			return nil, fmt.Errorf("Patched program: %v", err)
+		}
+
+		if keepsRows(model, patchedModel, pre, scenario.EOT) && patchedModel.Implies(pre, post, scenario.EOT) {
+			v.Holds++
+		}
+	}
+
+	switch {
+	case v.Reproduced == 0:
+		v.Label = fmt.Sprintf("unverified: none of %d failure scenario(s) reproduced", v.Scenarios)
+	case v.Holds == v.Reproduced:
+		v.Label = fmt.Sprintf("verified against %d failure scenario(s)", v.Reproduced)
+	default:
+		v.Label = fmt.Sprintf("refuted: invariant holds in only %d of %d failure scenario(s)", v.Holds, v.Reproduced)
+	}
+
+	return v, nil
+}
+
+// verify applies each correction suggestion and, if given,
+// the patch to the program and reports for each whether the
+// invariant holds when replaying all failed runs.
+func (debugRun *DebugRun) verify(corrections *correctionsResult) (*verifyResult, error) {
+
+	if debugRun.program == nil {
+		return nil, fmt.Errorf("Stage verify needs the analyzed Dedalus program, please pass -program")
+	}
+
+	runs := debugRun.faultInj.GetOutput()
+	failedIters := debugRun.faultInj.GetFailedRunsIters()
+
+	failedRuns := make([]*fi.Run, len(failedIters))
+	for i := range failedIters {
+		failedRuns[i] = runs[failedIters[i]]
+	}
+
+	result := &verifyResult{Verifications: make([]*fi.Verification, 0, 4)}
+
+	if corrections != nil {
+
+		for _, correction := range corrections.Corrections {
+
+			patch, reason := concretize(debugRun.program, correction)
+			if patch == nil {
+
+				result.Verifications = append(result.Verifications, &fi.Verification{
+					Correction: correction,
+					Label:      fmt.Sprintf("unverified: %s", reason),
+				})
+
+				continue
+			}
+
+			v, err := verifyPatch(debugRun.program, patch, failedRuns, debugRun.preTable, debugRun.postTable)
+			if err != nil {
+				return nil, fmt.Errorf("Could not verify correction: %v", err)
+			}
+			v.Correction = correction
+
+			result.Verifications = append(result.Verifications, v)
+		}
+	}
+
+	if debugRun.patch != nil {
+
+		v, err := verifyPatch(debugRun.program, debugRun.patch, failedRuns, debugRun.preTable, debugRun.postTable)
+		if err != nil {
+			return nil, fmt.Errorf("Could not verify patch: %v", err)
+		}
+		v.Correction = fmt.Sprintf("Patch %s", filepath.Base(debugRun.patch.File))
+
+		result.Verifications = append(result.Verifications, v)
+	}
+
+	return result, nil
+}
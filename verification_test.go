@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"path/filepath"
+
+	"github.com/numbleroot/nemo/dedalus"
+	fi "github.com/numbleroot/nemo/faultinjectors"
+)
+
+// Functions.
+
+// TestConcretize checks that patches of corrections replace
+// only the rules they rewrite, and that corrections adding
+// no subgoal are not turned into patches.
+func TestConcretize(t *testing.T) {
+
+	prog, err := dedalus.ParseFile(filepath.Join("case-studies", "pb_asynchronous.ded"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, reason := concretize(prog, "Change: <code>log(a, ...) :- request(a, ...);</code> -> <code>log(a, ...) :- request(a, ...), log(a, ...);</code>")
+	if reason != "suggests no new subgoal" {
+		t.Errorf("Expected correction adding no subgoal to be left unverified, got: '%s'", reason)
+	}
+
+	patch, reason := concretize(prog, "Change: <code>log(a, ...) :- request(a, ...);</code> -> <code>log(a, ...) :- request(a, ...), primary(a, ...);</code>")
+	if patch == nil {
+		t.Fatalf("Expected a patch, got: '%s'", reason)
+	}
+
+	rules := prog.Apply(patch).RulesFor("log")
+	if len(rules) != len(prog.RulesFor("log")) {
+		t.Fatalf("Expected %d rules for log after patching, got %d", len(prog.RulesFor("log")), len(rules))
+	}
+
+	patched := 0
+	for _, rule := range rules {
+
+		if strings.Contains(rule.String(), "primary(") {
+			patched++
+		}
+	}
+
+	if patched != 1 {
+		t.Errorf("Expected 1 patched rule for log, got %d", patched)
+	}
+}
+
+// TestVerifyPatchNeedsAntecedent checks that a patch making
+// the invariant hold by no longer deriving the antecedent
+// does not count as verified.
+func TestVerifyPatchNeedsAntecedent(t *testing.T) {
+
+	debugRun := newTestRun(t, filepath.Join("testdata", "case-studies", "pb_asynchronous"))
+
+	err := debugRun.loadOutput()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runs := debugRun.faultInj.GetOutput()
+	failed := make([]*fi.Run, 0, len(runs))
+	for _, iter := range debugRun.faultInj.GetFailedRunsIters() {
+		failed = append(failed, runs[iter])
+	}
+
+	patch, err := dedalus.Parse("patch.ded", "pre(Pload) :- acked(Cli, Prim, Pload), crash(Cli, Cli, _);")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := verifyPatch(debugRun.program, patch, failed, "pre", "post")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if (v.Reproduced == 0) || (v.Holds != 0) {
+		t.Errorf("Expected patch dropping the antecedent to hold in none of the reproduced scenarios, got %d of %d", v.Holds, v.Reproduced)
+	}
+}